@@ -0,0 +1,556 @@
+// annotations.go
+// package editml defines the public API for parsing and transforming EditML documents.
+package editml
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/verkaro/editml-go/model"
+	"github.com/verkaro/editml-go/parser"
+	"github.com/verkaro/editml-go/transformer"
+)
+
+// Codes of the issues reported when importing annotations. An annotation
+// with one of these problems is not imported; the others still are.
+const (
+	// IssueAnchorNotFound: the quote of an annotation is not in the Clean
+	// View, even approximately.
+	IssueAnchorNotFound IssueCode = "anchor-not-found"
+	// IssueAnchorAmbiguous: the quote is found in several places, and its
+	// prefix and suffix do not tell them apart.
+	IssueAnchorAmbiguous IssueCode = "anchor-ambiguous"
+	// IssueAnchorOverlap: the quoted passage is not plain text of the source
+	// (it crosses or lies inside EditML markup), or overlaps the passage of
+	// another annotation without being the same.
+	IssueAnchorOverlap IssueCode = "anchor-overlap"
+	// IssueInvalidAnnotations: the annotations are not valid JSON.
+	IssueInvalidAnnotations IssueCode = "invalid-annotations"
+)
+
+// AnchorTolerance is the largest share of the characters of a quote that
+// may differ from the text it is anchored to, as an edit distance. Quotes
+// copied from PDFs and web pages often differ from the source by a
+// hyphenation or a typographic character.
+const AnchorTolerance = 0.1
+
+// Annotation is a review comment made outside EditML, such as in a PDF
+// viewer or a web annotation tool, anchored to a passage by quoting it. The
+// JSON field names are those of the export formats of such tools.
+type Annotation struct {
+	Quote   string `json:"quote"`  // The passage commented on, as the reviewer saw it.
+	Prefix  string `json:"prefix"` // Text just before the passage, if known.
+	Suffix  string `json:"suffix"` // Text just after the passage, if known.
+	Comment string `json:"comment"`
+	Author  string `json:"author"`
+}
+
+// AnnotationOptions configures ImportAnnotations.
+type AnnotationOptions struct {
+	// Editors maps author names to EditML editor IDs. Authors without an
+	// entry are used as editor IDs if they are valid ones; the comments of
+	// other authors, and of authors mapped to invalid IDs, are left
+	// unattributed, with a warning.
+	Editors map[string]string
+}
+
+// ParseAnnotations reads annotations from a JSON array of objects with the
+// fields of Annotation.
+func ParseAnnotations(data []byte) ([]Annotation, error) {
+	var annotations []Annotation
+	if err := json.Unmarshal(data, &annotations); err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
+
+// ImportAnnotationsJSON is ImportAnnotations for annotations in JSON, as
+// read by ParseAnnotations.
+func ImportAnnotationsJSON(source string, data []byte, opts AnnotationOptions) (outputText string, issues []Issue) {
+	annotations, err := ParseAnnotations(data)
+	if err != nil {
+		return source, []Issue{{
+			Message:  fmt.Sprintf("Annotation error: %v", err),
+			Severity: SeverityError,
+			Code:     IssueInvalidAnnotations,
+		}}
+	}
+	return ImportAnnotations(source, annotations, opts)
+}
+
+// ImportAnnotations adds review comments made outside EditML to source, an
+// EditML document. The passage each annotation quotes is looked up in the
+// Clean View of source, which is what reviewers read, and traced back to the
+// source through the Clean View source map. There it is wrapped in a
+// highlight followed by the annotation's comment, attributed to its author
+// (see AnnotationOptions). Annotations on the same passage share its
+// highlight.
+//
+// Quotes are matched ignoring case, differences in whitespace and
+// typographic quotes, dashes and ligatures, and, if they are not found
+// as such, with up to AnchorTolerance of their characters different. When a
+// quote is found in several places the one whose surroundings best match
+// the annotation's prefix and suffix is used. Annotations are never placed
+// at a guess: those whose quote is not found, is found in several places
+// equally well, or does not map to plain text of the source are skipped and
+// reported as errors with code IssueAnchorNotFound, IssueAnchorAmbiguous or
+// IssueAnchorOverlap.
+func ImportAnnotations(source string, annotations []Annotation, opts AnnotationOptions) (outputText string, issues []Issue) {
+	doc, currentIssues := ProcessDocument(source)
+	if doc == nil {
+		return source, currentIssues
+	}
+	resolved, err := transformer.ResolveDocument(doc)
+	if err != nil {
+		currentIssues = append(currentIssues, errorIssues(err)...)
+		return source, currentIssues
+	}
+	clean, sourceMap, cleanIssues := TransformCleanViewWithSourceMap(doc, CleanViewOptions{})
+	for _, issue := range cleanIssues {
+		if issue.Severity == SeverityError {
+			currentIssues = append(currentIssues, issue)
+			return source, currentIssues
+		}
+	}
+
+	text := newAnchorText(clean)
+	plain := plainTextRanges(resolved, false, nil)
+	lines := parser.NewLineIndex(source)
+	editors := make(map[string]string)
+	var anchors []annotationAnchor
+	for i, a := range annotations {
+		name := fmt.Sprintf("annotation %d (%q)", i+1, excerpt(a.Quote))
+		candidates, code, reason := locateAnnotation(text, a)
+		if code != "" {
+			currentIssues = append(currentIssues, Issue{
+				Message:  fmt.Sprintf("Annotation error: %s %s", name, reason),
+				Severity: SeverityError,
+				Code:     code,
+			})
+			continue
+		}
+		// Text shown more than once, such as copied text, is one passage
+		// of the source, so candidates are told apart by where they are in
+		// the source. Those that are not plain text are never chosen.
+		var places []textRange // Distinct source ranges, with end -1 if not plain text.
+		for _, c := range candidates {
+			start, end, ok := sourceRange(sourceMap, c[0], c[1])
+			if !ok {
+				start, _ = sourceMap.SourceOffset(c[0])
+			}
+			place, inPlain := enclosingRange(plain, start, end)
+			place.start, place.end = start, end
+			if !ok || !inPlain {
+				place.end = -1
+			}
+			if !containsRange(places, place) {
+				places = append(places, place)
+			}
+		}
+		if len(places) > 1 {
+			currentIssues = append(currentIssues, Issue{
+				Message:  fmt.Sprintf("Annotation error: %s is found in %d places that its prefix and suffix do not tell apart", name, len(places)),
+				Severity: SeverityError,
+				Code:     IssueAnchorAmbiguous,
+			})
+			continue
+		}
+		place := places[0]
+		if place.end < 0 {
+			pos := lines.Position(place.start)
+			currentIssues = append(currentIssues, Issue{
+				Message:  fmt.Sprintf("Annotation error: %s quotes text that is not plain text of the source; add the comment by hand", name),
+				Line:     pos.Line,
+				Column:   pos.Column,
+				Severity: SeverityError,
+				Code:     IssueAnchorOverlap,
+			})
+			continue
+		}
+
+		editorID, known := editors[a.Author]
+		if !known {
+			editorID = annotationEditor(a.Author, opts)
+			if id, mapped := opts.Editors[a.Author]; mapped && editorID == "" {
+				currentIssues = append(currentIssues, Issue{
+					Message:  fmt.Sprintf("Annotation warning: the editor table maps author %q to %q, which is not a valid editor ID (1-5 letters or digits); comments left unattributed", a.Author, id),
+					Severity: SeverityWarning,
+					Code:     IssueInvalidEditorID,
+				})
+			} else if editorID == "" && a.Author != "" {
+				currentIssues = append(currentIssues, Issue{
+					Message:  fmt.Sprintf("Annotation warning: author %q is not a valid editor ID (1-5 letters or digits) and has no entry in the editor table; comments left unattributed", a.Author),
+					Severity: SeverityWarning,
+					Code:     IssueUnknownEditor,
+				})
+			}
+			editors[a.Author] = editorID
+		}
+		anchors = append(anchors, annotationAnchor{
+			start: place.start, end: place.end, inBlock: place.inBlock, name: name,
+			comment: transformer.EditMarkup(model.EditTypeComment, a.Comment, editorID),
+		})
+	}
+
+	// Anchors on the same passage share a highlight; anchors overlapping an
+	// earlier one otherwise are skipped.
+	sort.SliceStable(anchors, func(i, j int) bool { return anchors[i].start < anchors[j].start })
+	var sb strings.Builder
+	cursor := 0 // Offset in source up to which the output is written.
+	for i := 0; i < len(anchors); {
+		a := anchors[i]
+		comments := a.comment
+		j := i + 1
+		for ; j < len(anchors) && anchors[j].start < a.end; j++ {
+			if anchors[j].start == a.start && anchors[j].end == a.end {
+				comments += anchors[j].comment
+				continue
+			}
+			pos := lines.Position(anchors[j].start)
+			currentIssues = append(currentIssues, Issue{
+				Message:  fmt.Sprintf("Annotation error: %s overlaps the passage of %s; add the comment by hand", anchors[j].name, a.name),
+				Line:     pos.Line,
+				Column:   pos.Column,
+				Severity: SeverityError,
+				Code:     IssueAnchorOverlap,
+			})
+		}
+		markup := transformer.InlineMarkup(model.InlineEditNode{EditType: model.EditTypeHighlight, Content: source[a.start:a.end]}) + comments
+		if a.inBlock {
+			markup = transformer.EscapeBlock(markup)
+		}
+		sb.WriteString(source[cursor:a.start])
+		sb.WriteString(markup)
+		cursor = a.end
+		i = j
+	}
+	sb.WriteString(source[cursor:])
+	return sb.String(), currentIssues
+}
+
+// annotationAnchor is the passage of the source an annotation is placed on,
+// with the markup of its comment.
+type annotationAnchor struct {
+	start, end int
+	inBlock    bool // Whether the passage is in the block content of a structural source.
+	name       string
+	comment    string
+}
+
+// annotationEditor returns the editor ID of an annotation author, or "" if
+// there is none or the editor table maps the author to an invalid one.
+func annotationEditor(author string, opts AnnotationOptions) string {
+	if id, ok := opts.Editors[author]; ok {
+		if !parser.IsEditorID(id) {
+			return ""
+		}
+		return id
+	}
+	if parser.IsEditorID(author) {
+		return author
+	}
+	return ""
+}
+
+// excerpt returns the start of a quote, for messages.
+func excerpt(quote string) string {
+	runes := []rune(strings.Join(strings.Fields(quote), " "))
+	if len(runes) > 40 {
+		return string(runes[:40]) + "…"
+	}
+	return string(runes)
+}
+
+// anchorText is a text normalized for matching quotes: lower case, with
+// whitespace runs as single spaces and typographic characters replaced by
+// their plain equivalents. Each rune records the byte range of the original
+// text it stands for.
+type anchorText struct {
+	runes      []rune
+	starts     []int // Offset in the original text of the start of each rune.
+	ends       []int // Offset in the original text of the end of each rune.
+	whitespace []bool
+}
+
+// anchorFolding replaces typographic characters that quotes often differ
+// in; soft hyphens are dropped.
+var anchorFolding = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "′", "'",
+	"“", `"`, "”", `"`, "„", `"`, "″", `"`,
+	"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "-", "−", "-",
+	"…", "...", "ﬀ", "ff", "ﬁ", "fi", "ﬂ", "fl", "ﬃ", "ffi", "ﬄ", "ffl",
+	"­", "",
+)
+
+// newAnchorText normalizes text for matching.
+func newAnchorText(text string) *anchorText {
+	t := &anchorText{}
+	for i, r := range text {
+		end := i + len(string(r))
+		if unicode.IsSpace(r) {
+			if n := len(t.runes); n > 0 && t.whitespace[n-1] {
+				t.ends[n-1] = end
+				continue
+			}
+			t.add(' ', i, end, true)
+			continue
+		}
+		for _, f := range anchorFolding.Replace(string(r)) {
+			t.add(unicode.ToLower(f), i, end, false)
+		}
+	}
+	return t
+}
+
+// add appends a normalized rune.
+func (t *anchorText) add(r rune, start, end int, whitespace bool) {
+	t.runes = append(t.runes, r)
+	t.starts = append(t.starts, start)
+	t.ends = append(t.ends, end)
+	t.whitespace = append(t.whitespace, whitespace)
+}
+
+// normalizeQuote normalizes a quote, prefix or suffix like newAnchorText.
+func normalizeQuote(s string) []rune {
+	return newAnchorText(s).runes
+}
+
+// anchorMatch is a range of runes of an anchorText matched by a quote.
+type anchorMatch struct{ start, end int }
+
+// locateAnnotation finds the quote of an annotation in text. It returns the
+// byte ranges of the original text of the matches that fit the annotation
+// best, or the code of the issue and a reason if there are none.
+func locateAnnotation(text *anchorText, a Annotation) (candidates [][2]int, code IssueCode, reason string) {
+	quote := []rune(strings.TrimSpace(string(normalizeQuote(a.Quote))))
+	if len(quote) == 0 {
+		return nil, IssueAnchorNotFound, "has no quote"
+	}
+	matches := exactMatches(text.runes, quote)
+	if len(matches) == 0 {
+		matches = approximateMatches(text.runes, quote, int(float64(len(quote))*AnchorTolerance))
+	}
+	if len(matches) == 0 {
+		return nil, IssueAnchorNotFound, "is not found in the Clean View"
+	}
+	if len(matches) > 1 {
+		matches = bestInContext(text, matches, a)
+	}
+	for _, m := range matches {
+		// Leave whitespace at the ends of the match outside the highlight.
+		for m.start < m.end && text.whitespace[m.start] {
+			m.start++
+		}
+		for m.end > m.start && text.whitespace[m.end-1] {
+			m.end--
+		}
+		candidates = append(candidates, [2]int{text.starts[m.start], text.ends[m.end-1]})
+	}
+	return candidates, "", ""
+}
+
+// exactMatches returns the places where quote occurs in text, skipping
+// occurrences overlapping an earlier one.
+func exactMatches(text, quote []rune) []anchorMatch {
+	var matches []anchorMatch
+	for i := 0; i+len(quote) <= len(text); i++ {
+		if text[i] != quote[0] || !equalRunes(text[i:i+len(quote)], quote) {
+			continue
+		}
+		matches = append(matches, anchorMatch{i, i + len(quote)})
+		i += len(quote) - 1
+	}
+	return matches
+}
+
+// equalRunes reports whether a and b are equal.
+func equalRunes(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// approximateMatches returns the places where text is closest to quote, by
+// edit distance, if that distance is at most maxErrors. Of overlapping
+// places equally close, the one with the length nearest to that of quote is
+// kept.
+//
+// This is Sellers' algorithm: an edit distance computation in which a match
+// may start anywhere in text, each cell also recording where its match
+// starts.
+func approximateMatches(text, quote []rune, maxErrors int) []anchorMatch {
+	if maxErrors == 0 {
+		return nil
+	}
+	m := len(quote)
+	prev, cur := make([]int, m+1), make([]int, m+1)
+	prevStart, curStart := make([]int, m+1), make([]int, m+1)
+	for i := range prev {
+		prev[i] = i
+	}
+	best := maxErrors + 1
+	var ends []anchorMatch
+	for j := 1; j <= len(text); j++ {
+		cur[0], curStart[0] = 0, j
+		for i := 1; i <= m; i++ {
+			cost := 1
+			if quote[i-1] == text[j-1] {
+				cost = 0
+			}
+			cur[i], curStart[i] = prev[i-1]+cost, prevStart[i-1]
+			if d := cur[i-1] + 1; d < cur[i] {
+				cur[i], curStart[i] = d, curStart[i-1]
+			}
+			if d := prev[i] + 1; d < cur[i] {
+				cur[i], curStart[i] = d, prevStart[i]
+			}
+		}
+		switch d := cur[m]; {
+		case d < best:
+			best = d
+			ends = []anchorMatch{{curStart[m], j}}
+		case d == best && d <= maxErrors:
+			ends = append(ends, anchorMatch{curStart[m], j})
+		}
+		prev, cur = cur, prev
+		prevStart, curStart = curStart, prevStart
+	}
+
+	var matches []anchorMatch
+	for _, e := range ends {
+		if n := len(matches); n > 0 && e.start < matches[n-1].end {
+			if absInt(e.end-e.start-m) < absInt(matches[n-1].end-matches[n-1].start-m) {
+				matches[n-1] = e
+			}
+			continue
+		}
+		matches = append(matches, e)
+	}
+	return matches
+}
+
+// bestInContext returns the matches whose surroundings in text agree best
+// with the prefix and suffix of an annotation: the most characters just
+// before the match equal to the end of the prefix, plus the most characters
+// just after it equal to the start of the suffix.
+func bestInContext(text *anchorText, matches []anchorMatch, a Annotation) []anchorMatch {
+	prefix := []rune(strings.TrimSpace(string(normalizeQuote(a.Prefix))))
+	suffix := []rune(strings.TrimSpace(string(normalizeQuote(a.Suffix))))
+	var best []anchorMatch
+	bestScore := -1
+	for _, m := range matches {
+		score := 0
+		for i, k := len(prefix)-1, trimSpaceBack(text.runes, m.start); i >= 0 && k > 0 && prefix[i] == text.runes[k-1]; i, k = i-1, k-1 {
+			score++
+		}
+		for i, k := 0, trimSpaceFront(text.runes, m.end); i < len(suffix) && k < len(text.runes) && suffix[i] == text.runes[k]; i, k = i+1, k+1 {
+			score++
+		}
+		switch {
+		case score > bestScore:
+			bestScore = score
+			best = []anchorMatch{m}
+		case score == bestScore:
+			best = append(best, m)
+		}
+	}
+	return best
+}
+
+// trimSpaceBack returns end moved back over a space before it.
+func trimSpaceBack(runes []rune, end int) int {
+	if end > 0 && runes[end-1] == ' ' {
+		end--
+	}
+	return end
+}
+
+// trimSpaceFront returns start moved over a space at it.
+func trimSpaceFront(runes []rune, start int) int {
+	if start < len(runes) && runes[start] == ' ' {
+		start++
+	}
+	return start
+}
+
+// sourceRange maps the range of output bytes from start to end back to the
+// source through m. It reports false unless the range is copied from a
+// single contiguous range of the source.
+func sourceRange(m SourceMap, start, end int) (srcStart, srcEnd int, ok bool) {
+	i := sort.Search(len(m), func(i int) bool { return m[i].OutEnd > start })
+	pos := start
+	for ; i < len(m) && pos < end; i++ {
+		seg := m[i]
+		if seg.Generated || seg.OutStart > pos {
+			return 0, 0, false
+		}
+		from := seg.SrcStart + pos - seg.OutStart
+		if pos == start {
+			srcStart = from
+		} else if from != srcEnd {
+			return 0, 0, false
+		}
+		pos = end
+		if seg.OutEnd < end {
+			pos = seg.OutEnd
+		}
+		srcEnd = seg.SrcStart + pos - seg.OutStart
+	}
+	return srcStart, srcEnd, pos == end
+}
+
+// containsRange reports whether ranges contains r.
+func containsRange(ranges []textRange, r textRange) bool {
+	for _, other := range ranges {
+		if other == r {
+			return true
+		}
+	}
+	return false
+}
+
+// textRange is the input range of a text node.
+type textRange struct {
+	start, end int
+	inBlock    bool // Whether the text is in the block content of a structural source.
+}
+
+// plainTextRanges appends the input ranges of the text nodes of rd,
+// including those in the blocks of its structural sources, to ranges.
+func plainTextRanges(rd *transformer.ResolvedDocument, inBlock bool, ranges []textRange) []textRange {
+	for i, n := range rd.Nodes {
+		span, ok := rd.Span(i)
+		if !ok {
+			continue
+		}
+		switch n := n.(type) {
+		case model.TextNode:
+			ranges = append(ranges, textRange{span.Start.Offset, span.End.Offset, inBlock})
+		case model.StructuralSourceNode:
+			if src := rd.Source(n.Tag); src != nil && src.Block != nil {
+				ranges = plainTextRanges(src.Block, true, ranges)
+			}
+		}
+	}
+	return ranges
+}
+
+// enclosingRange returns the text range containing the input range from
+// start to end, if any.
+func enclosingRange(ranges []textRange, start, end int) (textRange, bool) {
+	for _, r := range ranges {
+		if r.start <= start && end <= r.end {
+			return r, true
+		}
+	}
+	return textRange{}, false
+}
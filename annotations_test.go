@@ -0,0 +1,121 @@
+// annotations_test.go
+// package editml_test contains unit tests for importing review comments anchored by quote.
+package editml
+
+import (
+	"testing"
+)
+
+// cleanViewOf returns the Clean View of an EditML source, failing the test on issues.
+func cleanViewOf(t *testing.T, source string) string {
+	t.Helper()
+	doc, issues := ProcessDocument(source)
+	if len(issues) > 0 {
+		t.Fatalf("ProcessDocument(%q): unexpected issues: %v", source, issues)
+	}
+	clean, issues := TransformDocument(doc, ProfileCleanView, nil)
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument(%q): unexpected issues: %v", source, issues)
+	}
+	return clean
+}
+
+// TestImportAnnotations tests that quoted passages are highlighted and commented, with authors mapped to editor IDs.
+func TestImportAnnotations(t *testing.T) {
+	source := "The {-old-ws}{+new+ws} plan is “bold”.\nIt starts   on\nMonday.\n"
+	data := []byte(`[
+		{"quote": "\"bold\"", "comment": "Too strong?", "author": "Jane Doe"},
+		{"quote": "starts on Monday", "comment": "Which one?", "author": "ws"},
+		{"quote": "\"bold\"", "comment": "Agreed.", "author": "ws"}
+	]`)
+	output, issues := ImportAnnotationsJSON(source, data, AnnotationOptions{Editors: map[string]string{"Jane Doe": "jd"}})
+	if len(issues) > 0 {
+		t.Fatalf("ImportAnnotationsJSON: unexpected issues: %v", issues)
+	}
+	expected := "The {-old-ws}{+new+ws} plan is {=“bold”=}{>Too strong?<jd}{>Agreed.<ws}.\nIt {=starts   on\nMonday=}{>Which one?<ws}.\n"
+	if output != expected {
+		t.Errorf("ImportAnnotationsJSON:\nExpected: %q\nGot:      %q", expected, output)
+	}
+	if got, want := cleanViewOf(t, output), cleanViewOf(t, source); got != want {
+		t.Errorf("Clean View changed:\nExpected: %q\nGot:      %q", want, got)
+	}
+}
+
+// TestImportAnnotationsContext tests that the prefix and suffix choose between matches, and that approximate quotes are found.
+func TestImportAnnotationsContext(t *testing.T) {
+	source := "He said no. Then he said no again.\nThe colour of money.\n"
+	annotations := []Annotation{
+		{Quote: "said no", Prefix: "Then he ", Suffix: " again", Comment: "Second time"},
+		{Quote: "The color of money", Comment: "Spelling"},
+	}
+	output, issues := ImportAnnotations(source, annotations, AnnotationOptions{})
+	if len(issues) > 0 {
+		t.Fatalf("ImportAnnotations: unexpected issues: %v", issues)
+	}
+	expected := "He said no. Then he {=said no=}{>Second time<} again.\n{=The colour of money=}{>Spelling<}.\n"
+	if output != expected {
+		t.Errorf("ImportAnnotations:\nExpected: %q\nGot:      %q", expected, output)
+	}
+}
+
+// TestImportAnnotationsBlocks tests that passages in structural blocks are annotated inside the block, once for copied text.
+func TestImportAnnotationsBlocks(t *testing.T) {
+	source := "{move~Moved ~ text.~m1} Start. {copy~Shared words~c1}\nEnd {copy:c1} and {move:m1}\n"
+	annotations := []Annotation{
+		{Quote: "moved ~ text", Comment: "Why ~?", Author: "ws"},
+		{Quote: "Shared words", Comment: "Copied twice", Author: "ws"},
+	}
+	output, issues := ImportAnnotations(source, annotations, AnnotationOptions{})
+	if len(issues) > 0 {
+		t.Fatalf("ImportAnnotations: unexpected issues: %v", issues)
+	}
+	expected := "{move~{=Moved \\~ text=}{>Why \\~?<ws}.~m1} Start. {copy~{=Shared words=}{>Copied twice<ws}~c1}\nEnd {copy:c1} and {move:m1}\n"
+	if output != expected {
+		t.Errorf("ImportAnnotations:\nExpected: %q\nGot:      %q", expected, output)
+	}
+	if got, want := cleanViewOf(t, output), cleanViewOf(t, source); got != want {
+		t.Errorf("Clean View changed:\nExpected: %q\nGot:      %q", want, got)
+	}
+}
+
+// TestImportAnnotationsIssues tests that anchors that are not found, ambiguous or in markup are reported and not inserted.
+func TestImportAnnotationsIssues(t *testing.T) {
+	source := "A cat. A cat.\nKeep {+this text+ws} here.\n"
+	annotations := []Annotation{
+		{Quote: "a cat", Comment: "Which?"},
+		{Quote: "a dog barks loudly", Comment: "Missing"},
+		{Quote: "this text here", Comment: "Crosses markup"},
+		{Quote: "Keep this", Comment: "Also crosses markup"},
+		{Quote: "", Comment: "Empty"},
+		{Quote: "cat. A cat", Comment: "Fine", Author: "Jane Doe"},
+		{Quote: "A cat", Comment: "Overlapping"},
+	}
+	output, issues := ImportAnnotations(source, annotations, AnnotationOptions{})
+	expected := "A {=cat. A cat=}{>Fine<}.\nKeep {+this text+ws} here.\n"
+	if output != expected {
+		t.Errorf("ImportAnnotations:\nExpected: %q\nGot:      %q", expected, output)
+	}
+	codes := []IssueCode{IssueAnchorAmbiguous, IssueAnchorNotFound, IssueAnchorOverlap, IssueAnchorOverlap, IssueAnchorNotFound, IssueUnknownEditor, IssueAnchorAmbiguous}
+	if len(issues) != len(codes) {
+		t.Fatalf("ImportAnnotations: expected %d issues, got %v", len(codes), issues)
+	}
+	for i, code := range codes {
+		if issues[i].Code != code {
+			t.Errorf("issue %d: expected code %q, got %q (%s)", i, code, issues[i].Code, issues[i].Message)
+		}
+	}
+
+	if _, issues := ImportAnnotationsJSON(source, []byte(`{"quote": "x"}`), AnnotationOptions{}); len(issues) != 1 || issues[0].Code != IssueInvalidAnnotations {
+		t.Errorf("ImportAnnotationsJSON: expected an invalid-annotations issue, got %v", issues)
+	}
+
+	// An invalid ID in the editor table leaves the comment unattributed.
+	annotations = []Annotation{{Quote: "here", Comment: "Fine", Author: "Jane"}}
+	output, issues = ImportAnnotations(source, annotations, AnnotationOptions{Editors: map[string]string{"Jane": "Jane Doe"}})
+	if expected := "A cat. A cat.\nKeep {+this text+ws} {=here=}{>Fine<}.\n"; output != expected {
+		t.Errorf("ImportAnnotations with an invalid editor ID:\nExpected: %q\nGot:      %q", expected, output)
+	}
+	if len(issues) != 1 || issues[0].Code != IssueInvalidEditorID {
+		t.Errorf("ImportAnnotations: expected one %s issue, got %v", IssueInvalidEditorID, issues)
+	}
+}
@@ -0,0 +1,63 @@
+// ansi_test.go
+// package editml_test contains unit tests for the terminal profile.
+package editml
+
+import (
+	"testing"
+)
+
+// TestTerminalProfileColor tests the ANSI styling of each edit type.
+func TestTerminalProfileColor(t *testing.T) {
+	inputText := "A {+new\nline+ws} {-old-} {>why<xy} {=key=}. {mv~blk~T}{mv:T}"
+	expectedOutput := "A \x1b[32;4mnew\x1b[0m\n\x1b[32;4mline\x1b[0m \x1b[31;9mold\x1b[0m \x1b[2m[# xy: why]\x1b[0m \x1b[7mkey\x1b[0m. " +
+		"\x1b[36m[moved to T]\x1b[0m\x1b[36m[moved from T]\x1b[0mblk"
+
+	doc, _ := ProcessDocument(inputText)
+	output, issues := TransformDocument(doc, ProfileTerminal, ProfileOptions{"color": "always"})
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	if output != expectedOutput {
+		t.Errorf("terminal profile (color): output = %q, want %q", output, expectedOutput)
+	}
+}
+
+// TestTerminalProfileNoColor tests the plain-text markers used without color.
+func TestTerminalProfileNoColor(t *testing.T) {
+	inputText := "A {+new+ws} {-old-} {>why<} {=key=} {cp:Q}"
+	expectedOutput := "A [+new+] [-old-] [# why] [=key=] {copy:Q}"
+
+	doc, _ := ProcessDocument(inputText)
+	output, _ := TransformDocument(doc, ProfileTerminal, ProfileOptions{"color": "never"})
+	if output != expectedOutput {
+		t.Errorf("terminal profile (no color): output = %q, want %q", output, expectedOutput)
+	}
+}
+
+// TestTerminalProfileSanitizes tests that control characters in the document cannot inject escape sequences.
+func TestTerminalProfileSanitizes(t *testing.T) {
+	inputText := "Text\x1b]0;title\x07 {+evil\x1b[2J\u009b+ws}"
+	for mode, expectedOutput := range map[string]string{
+		"always": "Text^[]0;title^G \x1b[32;4mevil^[[2J\uFFFD\x1b[0m",
+		"never":  "Text^[]0;title^G [+evil^[[2J\uFFFD+]",
+	} {
+		doc, _ := ProcessDocument(inputText)
+		output, _ := TransformDocument(doc, ProfileTerminal, ProfileOptions{"color": mode})
+		if output != expectedOutput {
+			t.Errorf("terminal profile (color=%s): output = %q, want %q", mode, output, expectedOutput)
+		}
+	}
+}
+
+// TestTerminalProfileColorOption tests that the terminal profile does not color by default and leaves auto to the caller.
+func TestTerminalProfileColorOption(t *testing.T) {
+	doc, _ := ProcessDocument("a {+b+}")
+	if output, issues := TransformDocument(doc, ProfileTerminal, nil); len(issues) > 0 || output != "a [+b+]" {
+		t.Errorf("terminal profile (default): output = %q, issues %v; want %q", output, issues, "a [+b+]")
+	}
+	for _, mode := range []string{"auto", "sometimes"} {
+		if _, issues := TransformDocument(doc, ProfileTerminal, ProfileOptions{"color": mode}); len(issues) == 0 {
+			t.Errorf("terminal profile (color=%s): expected an issue", mode)
+		}
+	}
+}
@@ -3,7 +3,9 @@
 package editml
 
 import (
+	"errors"
 	"fmt"
+
 	"github.com/verkaro/editml-go/model"
 	"github.com/verkaro/editml-go/parser"
 	"github.com/verkaro/editml-go/transformer"
@@ -19,12 +21,81 @@ const (
 	SeverityWarning IssueSeverity = "warning"
 )
 
+// IssueCode is a machine-readable identifier for the kind of an issue.
+type IssueCode string
+
+// Codes of the issues reported for structural constructs that cannot be
+// transformed as written.
+const (
+	IssueBlockParseError   = IssueCode(transformer.CodeBlockParseError)
+	IssueBlockResolveError = IssueCode(transformer.CodeBlockResolveError)
+	IssueOperationMismatch = IssueCode(transformer.CodeOperationMismatch)
+	// Conflicts that abort the transformation, reported at every occurrence
+	// of the tag (Spec 3.4.3).
+	IssueDuplicateSource     = IssueCode(transformer.CodeDuplicateSource)
+	IssueMultipleMoveTargets = IssueCode(transformer.CodeMultipleMoveTargets)
+)
+
+// Codes of the warnings reported when converting between EditML and other
+// formats.
+const (
+	IssueUnsupportedConstruct = IssueCode(transformer.CodeUnsupportedConstruct)
+	IssueMalformedMarkup      = IssueCode(parser.WarningMalformedMarkup)
+	IssueUnknownEditor        = IssueCode(parser.WarningUnknownEditor)
+	IssueUnpairedMove         = IssueCode(parser.WarningUnpairedMove)
+)
+
 // Issue represents an error or warning encountered during processing.
 type Issue struct {
 	Message  string        // A human-readable description of the issue.
 	Line     int           // The line number where the issue occurred (1-based, if available).
 	Column   int           // The column number where the issue occurred (1-based, if available, optional).
 	Severity IssueSeverity // The severity of the issue (error or warning).
+
+	Code      IssueCode // Machine-readable kind of the issue, if known.
+	Tag       string    // Structural tag the issue refers to, if any.
+	EndLine   int       // Line where the affected construct ends (1-based, if available).
+	EndColumn int       // Column just past the end of the affected construct (1-based, if available).
+	Passage   string    // Name of the Twee passage the issue occurred in, if any (see ProcessTwee).
+}
+
+// diagnosticIssues converts the problems found by the transformer and its
+// renderers into issues. Positions are filled in for documents parsed with
+// positions.
+func diagnosticIssues(diagnostics []transformer.Diagnostic) []Issue {
+	var issues []Issue
+	for _, d := range diagnostics {
+		issue := Issue{
+			Message:  fmt.Sprintf("Transformation error: %s", d.Message),
+			Severity: SeverityError,
+			Code:     IssueCode(d.Code),
+			Tag:      d.Tag,
+		}
+		if d.Warning {
+			issue.Message = fmt.Sprintf("Transformation warning: %s", d.Message)
+			issue.Severity = SeverityWarning
+		}
+		if d.HasSpan {
+			issue.Line, issue.Column = d.Span.Start.Line, d.Span.Start.Column
+			issue.EndLine, issue.EndColumn = d.Span.End.Line, d.Span.End.Column
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// errorIssues converts an error that stopped a transformation into issues: a
+// structural conflict gives one issue per occurrence of the conflicting tags,
+// other errors a single issue without a code.
+func errorIssues(err error) []Issue {
+	var conflict *transformer.ConflictError
+	if errors.As(err, &conflict) {
+		return diagnosticIssues(conflict.Diagnostics)
+	}
+	return []Issue{{
+		Message:  fmt.Sprintf("Transformation error: %v", err),
+		Severity: SeverityError,
+	}}
 }
 
 // Parse processes the input EditML string and returns a slice of nodes
@@ -64,21 +135,97 @@ func Parse(inputText string) (nodes []model.Node, issues []Issue) {
 // For the MVP, the implementation is adapted from the Backburner POC's transformer.
 func TransformCleanView(nodes []model.Node) (outputText string, issues []Issue) {
 	// Call the internal transformation logic.
-	transformedText, err := transformer.TransformToCleanView(nodes) // [cite: editML-code/transformer/transformer.go] (concept)
+	transformedText, diagnostics, err := transformer.Transform(&model.Document{Nodes: nodes}, transformer.ProfileCleanView, nil)
+
+	// Constructs that could not be transformed were rendered as literal markup.
+	currentIssues := append([]Issue{}, diagnosticIssues(diagnostics)...)
+	if err != nil {
+		// A structural conflict gives an issue per occurrence of its tag;
+		// other critical errors become a single Issue.
+		currentIssues = append(currentIssues, errorIssues(err)...)
+		// Even if there's an error, we might have partially transformed text (e.g. with error messages embedded).
+		// Or, if the error is fatal (like duplicate source tag), transformedText might be empty.
+		return transformedText, currentIssues
+	}
+
+	return transformedText, currentIssues
+}
+
+// ProcessDocument parses the input EditML string into a model.Document
+// (Spec 5.2). It produces the same nodes as Parse, and additionally records
+// the span of every node in inputText, so that transformations can report
+// positions and build source maps.
+func ProcessDocument(inputText string) (doc *model.Document, issues []Issue) {
+	textWithoutDebugComments, offsets := parser.SkipDebugCommentsWithMap(inputText)
+	parsedNodes, spans, err := parser.ParseEditMLToNodesWithSpans(textWithoutDebugComments)
 
 	currentIssues := []Issue{}
 	if err != nil {
-		// For MVP, a critical error from TransformToCleanView becomes a single Issue.
 		currentIssues = append(currentIssues, Issue{
-			Message:  fmt.Sprintf("Transformation error: %v", err),
-			Line:     0, // Placeholder, transformation errors are often structural, not line-specific
+			Message:  fmt.Sprintf("Parsing error: %v", err),
+			Line:     0, // Placeholder
 			Column:   0, // Placeholder
 			Severity: SeverityError,
 		})
-		// Even if there's an error, we might have partially transformed text (e.g. with error messages embedded).
-		// Or, if the error is fatal (like duplicate source tag), transformedText might be empty.
-		return transformedText, currentIssues
+	}
+	doc = &model.Document{
+		Nodes:  parsedNodes,
+		Spans:  parser.RemapSpans(spans, offsets, inputText),
+		Source: inputText,
+	}
+	return doc, currentIssues
+}
+
+// TransformDocument applies the named transformation profile to doc and
+// returns the rendered output (Spec 5.2). Profiles are looked up in the
+// profile registry; see RegisterProfile and Profiles. opts carries
+// profile-specific settings and may be nil.
+func TransformDocument(doc *model.Document, profile string, opts ProfileOptions) (outputText string, issues []Issue) {
+	currentIssues := []Issue{}
+	if doc == nil {
+		currentIssues = append(currentIssues, Issue{
+			Message:  "Transformation error: nil document",
+			Severity: SeverityError,
+		})
+		return "", currentIssues
 	}
 
+	transformedText, diagnostics, err := transformer.Transform(doc, profile, transformer.Options(opts))
+	currentIssues = append(currentIssues, diagnosticIssues(diagnostics)...)
+	if err != nil {
+		currentIssues = append(currentIssues, errorIssues(err)...)
+		return transformedText, currentIssues
+	}
 	return transformedText, currentIssues
 }
+
+// CleanViewOptions configures the Clean View transformation; see
+// transformer.CleanViewOptions.
+type CleanViewOptions = transformer.CleanViewOptions
+
+// SourceMap maps ranges of a transformation's output back to the input text.
+type SourceMap = transformer.SourceMap
+
+// TransformCleanViewWithSourceMap produces the Clean View of doc like
+// TransformCleanView, applying opts, and also returns a SourceMap from the
+// output to doc.Source. The map is only populated for documents parsed with
+// positions, such as those returned by ProcessDocument.
+func TransformCleanViewWithSourceMap(doc *model.Document, opts CleanViewOptions) (outputText string, sourceMap SourceMap, issues []Issue) {
+	currentIssues := []Issue{}
+	resolved, err := transformer.ResolveDocument(doc)
+	if err != nil {
+		currentIssues = append(currentIssues, errorIssues(err)...)
+		return "", nil, currentIssues
+	}
+
+	currentIssues = append(currentIssues, diagnosticIssues(resolved.Diagnostics)...)
+	renderer := transformer.NewCleanViewRenderer(opts)
+	transformedText, err := transformer.Render(resolved, renderer)
+	if err != nil {
+		currentIssues = append(currentIssues, Issue{
+			Message:  fmt.Sprintf("Transformation error: %v", err),
+			Severity: SeverityError,
+		})
+	}
+	return transformedText, renderer.SourceMap(), currentIssues
+}
@@ -0,0 +1,120 @@
+// changes_test.go
+// package editml_test contains unit tests for the changes (review sheet) profile.
+package editml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/verkaro/editml-go/transformer"
+)
+
+// TestChangesProfileText tests the plain-text review sheet in document order.
+func TestChangesProfileText(t *testing.T) {
+	inputText := "One two three {+four+ws} five six.\nSeven {-eight-jd} nine {mv~ten~T} eleven {mv:Q}"
+	expectedOutput := `Changes (4)
+
+#1 addition by ws, line 1
+    …two three >>four<< five six.…
+
+#2 deletion by jd, line 2
+    …six. Seven >>eight<< nine ten…
+
+#3 move-source T (unresolved), line 2
+    …Seven nine >>ten<< eleven…
+
+#4 move-target Q (unresolved), line 2
+    …ten eleven >>{move:Q}<<
+`
+
+	doc, _ := ProcessDocument(inputText)
+	output, issues := TransformDocument(doc, ProfileChanges, ProfileOptions{"context": "2"})
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	if output != expectedOutput {
+		t.Errorf("changes profile (text): output =\n%s\nwant\n%s", output, expectedOutput)
+	}
+}
+
+// TestChangesProfileMarkdownByEditor tests the Markdown review sheet grouped by editor.
+func TestChangesProfileMarkdownByEditor(t *testing.T) {
+	inputText := "A {+b+ws} c {-d-jd} e {>f<ws} g {=h=}"
+	expectedOutput := "# Changes (4)\n\n## Editor jd\n\n" +
+		"2. **deletion** by `jd`, line 1  \n   …A c **d** e g…\n" +
+		"\n## Editor ws\n\n" +
+		"1. **addition** by `ws`, line 1  \n   …A **b** c e…\n" +
+		"3. **comment** by `ws`, line 1  \n   …c e **f** g…\n" +
+		"\n## Unattributed\n\n" +
+		"4. **highlight**, line 1  \n   …e g **h**\n"
+
+	doc, _ := ProcessDocument(inputText)
+	output, _ := TransformDocument(doc, ProfileChanges, ProfileOptions{"format": "markdown", "group": "editor", "context": "2"})
+	if output != expectedOutput {
+		t.Errorf("changes profile (markdown): output =\n%s\nwant\n%s", output, expectedOutput)
+	}
+}
+
+// TestChangesRendererEntries tests the structured entries, including edits inside a moved block.
+func TestChangesRendererEntries(t *testing.T) {
+	doc, _ := ProcessDocument("x {mv~in {+y+ws} block~T}\nz {mv:T}")
+	resolved, err := transformer.ResolveDocument(doc)
+	if err != nil {
+		t.Fatalf("ResolveDocument returned error: %v", err)
+	}
+	r, _ := transformer.NewChangesRenderer(transformer.ChangesOptions{})
+	if _, err := transformer.Render(resolved, r); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	entries := r.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Entries() returned %d entries, want 3: %+v", len(entries), entries)
+	}
+	if e := entries[1]; e.Kind != "addition" || e.EditorID != "ws" || e.Content != "y" || e.Line != 1 {
+		t.Errorf("entry inside block = %+v, want addition y by ws on line 1", e)
+	}
+	if e := entries[2]; e.Kind != "move-target" || e.Status != transformer.StatusResolved || e.Line != 2 {
+		t.Errorf("target entry = %+v, want resolved move-target on line 2", e)
+	}
+}
+
+// TestChangesProfileBlockContext tests the context of a source whose block has edits while earlier entries are still pending.
+func TestChangesProfileBlockContext(t *testing.T) {
+	doc, _ := ProcessDocument("a {+b+} c {+d+} {mv~one two three four {+x+}~T} tail {mv:T}")
+	resolved, err := transformer.ResolveDocument(doc)
+	if err != nil {
+		t.Fatalf("ResolveDocument returned error: %v", err)
+	}
+	r, _ := transformer.NewChangesRenderer(transformer.ChangesOptions{ContextWords: 2})
+	if _, err := transformer.Render(resolved, r); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	entries := r.Entries()
+	if len(entries) != 5 {
+		t.Fatalf("Entries() returned %d entries, want 5: %+v", len(entries), entries)
+	}
+	if e := entries[2]; e.Kind != "move-source" || e.After != "tail" {
+		t.Errorf("source entry = %+v, want move-source followed by %q", e, "tail")
+	}
+	if e := entries[3]; e.Kind != "addition" || e.Before != "three four" || e.After != "tail" {
+		t.Errorf("entry inside block = %+v, want addition between %q and %q", e, "three four", "tail")
+	}
+	if _, issues := TransformDocument(doc, ProfileChanges, ProfileOptions{"context": "2"}); len(issues) > 0 {
+		t.Errorf("TransformDocument returned unexpected issues: %v", issues)
+	}
+}
+
+// TestChangesRendererLongContext tests that context is taken from the words next to an edit in a long document.
+func TestChangesRendererLongContext(t *testing.T) {
+	long := strings.Repeat("word ", 10000)
+	doc, _ := ProcessDocument(long + "last words {+x+ws} first words " + long)
+	resolved, _ := transformer.ResolveDocument(doc)
+	r, _ := transformer.NewChangesRenderer(transformer.ChangesOptions{ContextWords: 3})
+	transformer.Render(resolved, r)
+
+	entries := r.Entries()
+	if len(entries) != 1 || entries[0].Before != "word last words" || entries[0].After != "first words word" {
+		t.Errorf("Entries() = %+v, want one entry between \"word last words\" and \"first words word\"", entries)
+	}
+}
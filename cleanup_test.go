@@ -0,0 +1,52 @@
+// cleanup_test.go
+// package editml_test contains unit tests for Clean View cleanup.
+package editml
+
+import (
+	"testing"
+)
+
+// TestCleanViewCleanup tests that cleanup removes artifacts at deletion and move boundaries.
+func TestCleanViewCleanup(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"Hello {+World+}! This is {-not seen-}.", "Hello World! This is."},
+		{"One {-two-} three.", "One three."},
+		{"Keep  double  spaces {-here-} intact.", "Keep  double  spaces intact."},
+		{"Hello, {-world-}, there.", "Hello, there."},
+		{"Hello, {-world-}.", "Hello."},
+		{"Time 3:{-00-}, ok", "Time 3:, ok"},
+		{"a,{-b-}, c", "a, c"},
+		{"A list ({-item-} x)", "A list (x)"},
+		{"Para one.\n\n{-Para two.-}\n\nPara three.", "Para one.\n\nPara three."},
+		{"End of line {-gone-}\nNext line.", "End of line\nNext line."},
+		{"{-Gone-} start.", "start."},
+		{"Note {>a comment<xy} here.", "Note here."},
+		{"Start {mv~moved~T1} end.\nHere: {mv:T1}", "Start end.\nHere: moved"},
+	}
+
+	for _, tc := range testCases {
+		doc, parseIssues := ProcessDocument(tc.input)
+		if len(parseIssues) > 0 {
+			t.Fatalf("ProcessDocument(%q) returned unexpected issues: %v", tc.input, parseIssues)
+		}
+		output, issues := TransformDocument(doc, ProfileCleanView, ProfileOptions{"cleanup": "true"})
+		if len(issues) > 0 {
+			t.Fatalf("TransformDocument(%q) returned unexpected issues: %v", tc.input, issues)
+		}
+		if output != tc.expected {
+			t.Errorf("Clean View with cleanup of %q = %q, want %q", tc.input, output, tc.expected)
+		}
+	}
+}
+
+// TestCleanViewCleanupIsOptIn tests that the default Clean View is unchanged.
+func TestCleanViewCleanupIsOptIn(t *testing.T) {
+	doc, _ := ProcessDocument("This is {-not seen-}.")
+	output, _ := TransformDocument(doc, ProfileCleanView, nil)
+	if output != "This is ." {
+		t.Errorf("Clean View without cleanup = %q, want %q", output, "This is .")
+	}
+}
@@ -14,11 +14,87 @@ import (
 	"github.com/verkaro/editml-go/model" // For printing model.Node details in debug mode
 )
 
+// optionsFlag collects repeated --option key=value flags into profile options.
+type optionsFlag editml.ProfileOptions
+
+func (o optionsFlag) String() string {
+	pairs := make([]string, 0, len(o))
+	for k, v := range o {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (o optionsFlag) Set(value string) error {
+	key, val, found := strings.Cut(value, "=")
+	if !found {
+		val = "true" // A bare key enables a boolean option.
+	}
+	if key == "" {
+		return fmt.Errorf("invalid option %q, want key=value", value)
+	}
+	o[key] = val
+	return nil
+}
+
 func main() {
 	// Define a debug flag
 	debug := flag.Bool("debug", false, "Enable debug output (prints AST and issues)")
+	profile := flag.String("profile", editml.ProfileCleanView, "Transformation profile to apply (one of: "+strings.Join(editml.Profiles(), ", ")+")")
+	options := optionsFlag{}
+	flag.Var(options, "option", "Profile option as key=value (may be repeated)")
+	inputFormat := flag.String("from", "editml", "Input format: editml, markdown, twee, criticmarkup, docx or html (convert to EditML with --profile markup)")
+	editors := optionsFlag{}
+	flag.Var(editors, "editor", "Map an author name to an editor ID as name=id when importing (may be repeated)")
+	templateFile := flag.String("template", "", "Render with the template profile using this template file (.html/.htm files use html/template)")
+	diff := flag.Bool("diff", false, "Generate EditML from two versions of a text: --diff [--editor-id id] original revised")
+	patchFile := flag.String("patch", "", "Apply this patch (diff -u or git diff --word-diff=plain) to the document on stdin as suggestions, and print the EditML")
+	annotationsFile := flag.String("annotations", "", "Add the review comments in this JSON file ({quote, prefix, suffix, comment, author} objects) to the document on stdin, and print the EditML")
+	editorID := flag.String("editor-id", "", "Editor ID to attribute the edits generated by --diff or --patch to")
+	format := flag.String("format", "text", "Format of the issue report: text, sarif or checkstyle")
+	reportFile := flag.String("report", "", "Write the issue report to this file instead of stderr")
+	failOn := flag.String("fail-on", "error", "Exit with status 1 if an issue is at least this severe: error, warning or none")
+	inputPath := flag.String("path", "", "Path of the input file, as named in sarif and checkstyle reports")
 	flag.Parse()
 
+	threshold, err := editml.ParseSeverityThreshold(*failOn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --fail-on: %v\n", err)
+		os.Exit(2)
+	}
+	if *format != "text" && *format != "sarif" && *format != "checkstyle" {
+		fmt.Fprintf(os.Stderr, "Unknown report format %q, want text, sarif or checkstyle\n", *format)
+		os.Exit(2)
+	}
+	rep := reporter{format: *format, file: *reportFile, threshold: threshold, path: *inputPath}
+
+	if *diff {
+		os.Exit(runDiff(flag.Args(), *editorID, rep))
+	}
+	if *patchFile != "" {
+		os.Exit(runPatch(*patchFile, *editorID, rep))
+	}
+	if *annotationsFile != "" {
+		os.Exit(runAnnotations(*annotationsFile, editors, rep))
+	}
+
+	if *templateFile != "" {
+		*profile = editml.ProfileTemplate
+		options["file"] = *templateFile
+	}
+	if *profile == editml.ProfileTerminal {
+		// The terminal profile takes always or never; auto is decided here.
+		color, err := colorEnabled(options["color"])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid color option: %v\n", err)
+			os.Exit(2)
+		}
+		options["color"] = "never"
+		if color {
+			options["color"] = "always"
+		}
+	}
+
 	// Read input from stdin
 	// fmt.Fprintln(os.Stderr, "Enter EditML text (press Ctrl+D to end input):") // Prompt
 	inputBytes, err := io.ReadAll(os.Stdin)
@@ -38,8 +114,29 @@ func main() {
 		fmt.Println()
 	}
 
-	// Call the editml API's Parse function
-	nodes, parseIssues := editml.Parse(inputText)
+	// Call the editml API's ProcessDocument function (Parse plus node positions),
+	// or convert the input from another format.
+	var doc *model.Document
+	var passages []editml.Passage
+	var parseIssues []editml.Issue
+	switch *inputFormat {
+	case "editml":
+		doc, parseIssues = editml.ProcessDocument(inputText)
+	case "markdown":
+		doc, parseIssues = editml.ProcessMarkdown(inputText)
+	case "twee":
+		doc, passages, parseIssues = editml.ProcessTwee(inputText)
+	case "criticmarkup":
+		doc, parseIssues = editml.FromCriticMarkup(inputText, editml.CriticMarkupOptions{Editors: editors})
+	case "docx":
+		doc, parseIssues = editml.ImportDOCX(inputBytes, editml.DOCXImportOptions{Editors: editors})
+	case "html":
+		doc, parseIssues = editml.ImportHTML(inputText, editml.HTMLImportOptions{Editors: editors})
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown input format %q, want editml, markdown, twee, criticmarkup, docx or html\n", *inputFormat)
+		os.Exit(2)
+	}
+	nodes := doc.Nodes
 
 	if *debug {
 		fmt.Println("--- Parsing Results (AST) ---")
@@ -56,7 +153,7 @@ func main() {
 		fmt.Println("--- Parsing Issues ---")
 		if len(parseIssues) > 0 {
 			for _, issue := range parseIssues {
-				fmt.Printf("[%s] L%d:%d %s\n", issue.Severity, issue.Line, issue.Column, issue.Message)
+				printIssue(issue)
 			}
 		} else {
 			fmt.Println("(None)")
@@ -65,14 +162,15 @@ func main() {
 		fmt.Println()
 	}
 
-	// Call the editml API's TransformCleanView function
-	outputText, transformIssues := editml.TransformCleanView(nodes)
+	// Call the editml API's TransformDocument function with the selected profile
+	outputText, transformIssues := editml.TransformDocument(doc, *profile, editml.ProfileOptions(options))
+	transformIssues = editml.PassageIssues(doc, passages, transformIssues)
 
 	if *debug {
 		fmt.Println("--- Transformation Issues ---")
 		if len(transformIssues) > 0 {
 			for _, issue := range transformIssues {
-				fmt.Printf("[%s] L%d:%d %s\n", issue.Severity, issue.Line, issue.Column, issue.Message)
+				printIssue(issue)
 			}
 		} else {
 			fmt.Println("(None)")
@@ -80,7 +178,7 @@ func main() {
 		fmt.Println("--- End Transformation Issues ---")
 		fmt.Println()
 
-		fmt.Println("--- Final Output (Clean View) ---")
+		fmt.Printf("--- Final Output (%s) ---\n", *profile)
 	}
 
 	// Print the final transformed output
@@ -95,29 +193,181 @@ func main() {
 		fmt.Println("--- End Final Output ---")
 	}
 
-	// Exit with an error code if there were any "error" severity issues
-	hasErrors := false
-	for _, issue := range parseIssues {
-		if issue.Severity == editml.SeverityError {
-			hasErrors = true
-			break
+	// Write the report in the structured formats, and in the text format if
+	// --report names a file; otherwise the text format prints issues in
+	// debug mode only.
+	allIssues := append(append([]editml.Issue{}, parseIssues...), transformIssues...)
+	if rep.format != "text" || rep.file != "" {
+		if err := rep.write(rep.pathOr("stdin"), allIssues); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+			os.Exit(1)
 		}
 	}
-	if !hasErrors {
-		for _, issue := range transformIssues {
-			if issue.Severity == editml.SeverityError {
-				hasErrors = true
-				break
+
+	// Exit with an error code if an issue reaches the --fail-on severity
+	if editml.ExceedsThreshold(allIssues, rep.threshold) {
+		if !*debug && rep.format == "text" && rep.file == "" { // If not in debug, issues might not have been printed yet
+			if editml.ExceedsThreshold(allIssues, editml.SeverityError) {
+				fmt.Fprintln(os.Stderr, "Errors occurred during processing. Run with --debug for details.")
+			} else {
+				fmt.Fprintln(os.Stderr, "Warnings occurred during processing. Run with --debug for details.")
 			}
 		}
+		os.Exit(1)
 	}
+}
 
-	if hasErrors {
-		if !*debug { // If not in debug, errors might not have been printed yet
-			fmt.Fprintln(os.Stderr, "Errors occurred during processing. Run with --debug for details.")
+// runDiff prints the EditML generated from the original and revised files
+// named in args, and returns the exit code.
+func runDiff(args []string, editorID string, rep reporter) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: editml-tester --diff [--editor-id id] original revised")
+		return 2
+	}
+	var versions [2]string
+	for i, name := range args {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", name, err)
+			return 1
 		}
-		os.Exit(1)
+		versions[i] = string(data)
+	}
+	outputText, issues := editml.FromDiff(versions[0], versions[1], editorID)
+	fmt.Print(outputText)
+	return rep.report(rep.pathOr(args[1]), issues)
+}
+
+// runPatch prints the document on stdin with the patch in patchFile applied
+// as suggestions, and returns the exit code.
+func runPatch(patchFile, editorID string, rep reporter) int {
+	patch, err := os.ReadFile(patchFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", patchFile, err)
+		return 1
+	}
+	base, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading from stdin: %v\n", err)
+		return 1
+	}
+	outputText, issues := editml.ImportPatch(string(base), string(patch), editorID)
+	fmt.Print(outputText)
+	return rep.report(rep.pathOr(patchFile), issues) // Patch issues refer to the patch.
+}
+
+// runAnnotations prints the document on stdin with the review comments in
+// annotationsFile added, and returns the exit code.
+func runAnnotations(annotationsFile string, editors map[string]string, rep reporter) int {
+	data, err := os.ReadFile(annotationsFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", annotationsFile, err)
+		return 1
+	}
+	source, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading from stdin: %v\n", err)
+		return 1
+	}
+	outputText, issues := editml.ImportAnnotationsJSON(string(source), data, editml.AnnotationOptions{Editors: editors})
+	fmt.Print(outputText)
+	return rep.report(rep.pathOr("stdin"), issues)
+}
+
+// reporter writes issue reports in the format selected with --format and
+// decides the exit code with the --fail-on threshold.
+type reporter struct {
+	format    string // "text", "sarif" or "checkstyle".
+	file      string // File to write the report to; stderr if empty.
+	threshold editml.IssueSeverity
+	path      string // Path of the input given with --path, if any.
+}
+
+// pathOr returns the path of the input given with --path, or def.
+func (r reporter) pathOr(def string) string {
+	if r.path != "" {
+		return r.path
+	}
+	return def
+}
+
+// report writes the report of issues found in the file at path and returns
+// the exit code: 1 if one of them reaches the threshold.
+func (r reporter) report(path string, issues []editml.Issue) int {
+	if err := r.write(path, issues); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+		return 1
+	}
+	if editml.ExceedsThreshold(issues, r.threshold) {
+		return 1
+	}
+	return 0
+}
+
+// write writes the report of issues found in the file at path.
+func (r reporter) write(path string, issues []editml.Issue) error {
+	var data []byte
+	switch r.format {
+	case "sarif", "checkstyle":
+		files := []editml.FileIssues{{Path: path, Issues: issues}}
+		var err error
+		if r.format == "sarif" {
+			data, err = editml.SARIF(files)
+		} else {
+			data, err = editml.Checkstyle(files)
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		var sb strings.Builder
+		for _, issue := range issues {
+			if issue.Line > 0 {
+				fmt.Fprintf(&sb, "[%s] L%d:%d %s\n", issue.Severity, issue.Line, issue.Column, issue.Message)
+			} else {
+				fmt.Fprintf(&sb, "[%s] %s\n", issue.Severity, issue.Message)
+			}
+		}
+		data = []byte(sb.String())
+	}
+	if r.file != "" {
+		return os.WriteFile(r.file, data, 0o644)
+	}
+	_, err := os.Stderr.Write(data)
+	return err
+}
+
+// colorEnabled decides whether terminal output should be colored for the
+// given mode: "always", "never" or "auto" (also used for an empty mode). In
+// auto mode, color is used only if stdout is a terminal, the NO_COLOR
+// environment variable is unset or empty (https://no-color.org), and TERM is
+// not "dumb".
+func colorEnabled(mode string) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "", "auto":
+		if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+			return false, nil
+		}
+		info, err := os.Stdout.Stat()
+		if err != nil {
+			return false, nil
+		}
+		return info.Mode()&os.ModeCharDevice != 0, nil
+	}
+	return false, fmt.Errorf("invalid color mode %q, want auto, always or never", mode)
+}
+
+// printIssue prints an issue in debug mode, with its passage if any.
+func printIssue(issue editml.Issue) {
+	if issue.Passage != "" {
+		fmt.Printf("[%s] L%d:%d (passage %q) %s\n", issue.Severity, issue.Line, issue.Column, issue.Passage, issue.Message)
+		return
 	}
+	fmt.Printf("[%s] L%d:%d %s\n", issue.Severity, issue.Line, issue.Column, issue.Message)
 }
 
 // formatNode provides a string representation of a model.Node for debug printing.
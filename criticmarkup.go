@@ -0,0 +1,47 @@
+// criticmarkup.go
+// package editml defines the public API for parsing and transforming EditML documents.
+package editml
+
+import (
+	"fmt"
+
+	"github.com/verkaro/editml-go/model"
+	"github.com/verkaro/editml-go/parser"
+	"github.com/verkaro/editml-go/transformer"
+)
+
+// CriticMarkupOptions configures FromCriticMarkup; see
+// parser.CriticMarkupOptions.
+type CriticMarkupOptions = parser.CriticMarkupOptions
+
+// FromCriticMarkup converts CriticMarkup text into an EditML document.
+// Substitutions become a deletion followed by an addition, and "@name"
+// attributions in comments become editor IDs, mapped through opts.Editors.
+// Spans and Source refer to the CriticMarkup input, so the document can be
+// transformed, or written as EditML with the markup profile, like a parsed
+// one. Malformed constructs and unknown editors are reported as warnings.
+func FromCriticMarkup(inputText string, opts CriticMarkupOptions) (doc *model.Document, issues []Issue) {
+	nodes, spans, warnings := parser.ParseCriticMarkup(inputText, opts)
+
+	currentIssues := []Issue{}
+	lines := parser.NewLineIndex(inputText)
+	for _, w := range warnings {
+		pos := lines.Position(w.Offset)
+		currentIssues = append(currentIssues, Issue{
+			Message:  fmt.Sprintf("CriticMarkup warning: %s", w.Message),
+			Line:     pos.Line,
+			Column:   pos.Column,
+			Severity: SeverityWarning,
+			Code:     IssueCode(w.Code),
+		})
+	}
+	doc = &model.Document{Nodes: nodes, Spans: spans, Source: inputText}
+	return doc, currentIssues
+}
+
+// ToCriticMarkup exports doc as CriticMarkup, like the criticmarkup profile.
+// Constructs CriticMarkup cannot represent, such as moves and copies, are
+// approximated and reported as warnings with code IssueUnsupportedConstruct.
+func ToCriticMarkup(doc *model.Document) (outputText string, issues []Issue) {
+	return TransformDocument(doc, transformer.ProfileCriticMarkup, nil)
+}
@@ -0,0 +1,111 @@
+// criticmarkup_test.go
+// package editml_test contains unit tests for CriticMarkup import and export.
+package editml
+
+import (
+	"testing"
+)
+
+// TestFromCriticMarkup tests the conversion of every CriticMarkup construct and of attributions.
+func TestFromCriticMarkup(t *testing.T) {
+	inputText := "A {++b++}{>>@ws<<} {~~c~>d~~}{>>@Jane<<} {==e==}{>>@jd: why?<<} {--f--} {>>plain<<}"
+	doc, issues := FromCriticMarkup(inputText, CriticMarkupOptions{Editors: map[string]string{"Jane": "jane"}})
+	if len(issues) > 0 {
+		t.Fatalf("FromCriticMarkup returned unexpected issues: %v", issues)
+	}
+
+	output, _ := TransformDocument(doc, ProfileMarkup, nil)
+	if expected := "A {+b+ws} {-c-jane}{+d+jane} {=e=}{>why?<jd} {-f-} {>plain<}"; output != expected {
+		t.Errorf("FromCriticMarkup: markup = %q, want %q", output, expected)
+	}
+	if len(doc.Spans) != len(doc.Nodes) || doc.Spans[1].End.Offset != len("A {++b++}{>>@ws<<}") {
+		t.Errorf("FromCriticMarkup: spans = %v, want the addition to cover its attribution", doc.Spans)
+	}
+}
+
+// TestFromCriticMarkupWarnings tests that malformed markup and unknown editors are reported.
+func TestFromCriticMarkupWarnings(t *testing.T) {
+	doc, issues := FromCriticMarkup("a {~~b~~} {++c++}{>>@jonathan<<}\n{--open", CriticMarkupOptions{})
+	wantCodes := []IssueCode{IssueMalformedMarkup, IssueUnknownEditor, IssueMalformedMarkup}
+	if len(issues) != len(wantCodes) {
+		t.Fatalf("FromCriticMarkup issues = %v, want %d warnings", issues, len(wantCodes))
+	}
+	for i, issue := range issues {
+		if issue.Code != wantCodes[i] || issue.Severity != SeverityWarning {
+			t.Errorf("issue %d = %+v, want %s warning", i, issue, wantCodes[i])
+		}
+	}
+	if issues[2].Line != 2 || issues[2].Column != 1 {
+		t.Errorf("unclosed markup reported at L%d:%d, want L2:1", issues[2].Line, issues[2].Column)
+	}
+
+	output, _ := TransformDocument(doc, ProfileMarkup, nil)
+	if expected := "a {~~b~~} {+c+}\n{=\\{=}--open"; output != expected {
+		t.Errorf("FromCriticMarkup: markup = %q, want %q", output, expected)
+	}
+
+	// An invalid editor ID in the table drops the attribution.
+	doc, issues = FromCriticMarkup("{++c++}{>>@Jane<<}", CriticMarkupOptions{Editors: map[string]string{"Jane": "Jane Doe"}})
+	if len(issues) != 1 || issues[0].Code != IssueInvalidEditorID {
+		t.Errorf("FromCriticMarkup issues = %v, want one %s warning", issues, IssueInvalidEditorID)
+	}
+	if output, _ := TransformDocument(doc, ProfileMarkup, nil); output != "{+c+}" {
+		t.Errorf("FromCriticMarkup: markup = %q, want %q", output, "{+c+}")
+	}
+}
+
+// TestFromCriticMarkupLiteralText tests that text that would read as EditML is kept as text through markup and reparsing.
+func TestFromCriticMarkupLiteralText(t *testing.T) {
+	inputText := "Code {-x-} here {++ok++}\n%% kept"
+	doc, _ := FromCriticMarkup(inputText, CriticMarkupOptions{})
+
+	output, _ := TransformDocument(doc, ProfileMarkup, nil)
+	if expected := "Code {=\\{=}-x-} here {+ok+}\n{=%%=} kept"; output != expected {
+		t.Errorf("FromCriticMarkup: markup = %q, want %q", output, expected)
+	}
+	reparsed, _ := ProcessDocument(output)
+	clean, _ := TransformDocument(reparsed, ProfileCleanView, nil)
+	if expected := "Code {-x-} here ok\n%% kept"; clean != expected {
+		t.Errorf("Clean View after reparsing = %q, want %q", clean, expected)
+	}
+	if len(doc.Spans) != len(doc.Nodes) || doc.Spans[1].Start.Offset != len("Code ") || doc.Spans[1].End.Offset != len("Code {") {
+		t.Errorf("FromCriticMarkup: spans = %v, want one span per node", doc.Spans)
+	}
+}
+
+// TestToCriticMarkup tests the export of inline edits, substitutions and attributions.
+func TestToCriticMarkup(t *testing.T) {
+	doc, _ := ProcessDocument("A {+b+ws}{-c-ws}{+d+ws} {-e-}{+f+jd} {>note<jd} {=h=}")
+	output, issues := ToCriticMarkup(doc)
+	if len(issues) > 0 {
+		t.Fatalf("ToCriticMarkup returned unexpected issues: %v", issues)
+	}
+	expected := "A {++b++}{>>@ws<<}{~~c~>d~~}{>>@ws<<} {--e--}{++f++}{>>@jd<<} {>>@jd: note<<} {==h==}"
+	if output != expected {
+		t.Errorf("ToCriticMarkup: output = %q, want %q", output, expected)
+	}
+
+	// Converting back yields the same edits.
+	back, _ := FromCriticMarkup(output, CriticMarkupOptions{})
+	markup, _ := TransformDocument(back, ProfileMarkup, nil)
+	if want := "A {+b+ws}{-c-ws}{+d+ws} {-e-}{+f+jd} {>note<jd} {=h=}"; markup != want {
+		t.Errorf("CriticMarkup round trip: markup = %q, want %q", markup, want)
+	}
+}
+
+// TestToCriticMarkupStructural tests that moves and copies are approximated with warnings.
+func TestToCriticMarkupStructural(t *testing.T) {
+	doc, _ := ProcessDocument("{mv~x {+y+}~T} z {mv:T} {cp~k~C}{cp:C} {mv:Q}")
+	output, issues := ToCriticMarkup(doc)
+	if expected := "{--x --} z {++x y++} k{++k++} "; output != expected {
+		t.Errorf("ToCriticMarkup: output = %q, want %q", output, expected)
+	}
+	if len(issues) != 5 {
+		t.Fatalf("ToCriticMarkup issues = %v, want 5 warnings", issues)
+	}
+	for _, issue := range issues {
+		if issue.Code != IssueUnsupportedConstruct || issue.Severity != SeverityWarning || issue.Line != 1 {
+			t.Errorf("issue = %+v, want positioned %s warning", issue, IssueUnsupportedConstruct)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// decisions.go
+// package editml defines the public API for parsing and transforming EditML documents.
+package editml
+
+import (
+	"fmt"
+
+	"github.com/verkaro/editml-go/model"
+	"github.com/verkaro/editml-go/transformer"
+)
+
+// Decision is a reviewer's verdict on one edit: pending, accepted or rejected.
+type Decision = transformer.Decision
+
+// Decisions accepted by ApplyDecisions.
+const (
+	DecisionPending = transformer.DecisionPending
+	DecisionAccept  = transformer.DecisionAccept
+	DecisionReject  = transformer.DecisionReject
+)
+
+// ChangeEntry describes one edit as listed by the changes profile.
+type ChangeEntry = transformer.ChangeEntry
+
+// Decider returns the decision for one edit.
+type Decider = transformer.Decider
+
+// DecideByNumber returns a Decider that looks edits up by their number in the
+// changes profile's review sheet.
+func DecideByNumber(decisions map[int]Decision) Decider {
+	return transformer.DecideByNumber(decisions)
+}
+
+// DecideByEditor returns a Decider that looks edits up by their editor ID.
+func DecideByEditor(decisions map[string]Decision) Decider {
+	return transformer.DecideByEditor(decisions)
+}
+
+// ApplyDecisions resolves the decided edits of doc and returns new EditML
+// source in which accepted and rejected edits have become plain text and
+// pending edits remain as markup, correctly escaped. Moves and copies are
+// resolved or kept as whole units; see transformer.MarkupRenderer for the
+// exact rules. A nil decide keeps every edit.
+func ApplyDecisions(doc *model.Document, decide Decider) (outputText string, issues []Issue) {
+	currentIssues := []Issue{}
+	if doc == nil {
+		currentIssues = append(currentIssues, Issue{
+			Message:  "Transformation error: nil document",
+			Severity: SeverityError,
+		})
+		return "", currentIssues
+	}
+
+	resolved, err := transformer.ResolveDocument(doc)
+	if err != nil {
+		currentIssues = append(currentIssues, errorIssues(err)...)
+		return "", currentIssues
+	}
+	currentIssues = append(currentIssues, diagnosticIssues(resolved.Diagnostics)...)
+	transformedText, err := transformer.Render(resolved, transformer.NewMarkupRenderer(decide))
+	if err != nil {
+		currentIssues = append(currentIssues, Issue{
+			Message:  fmt.Sprintf("Transformation error: %v", err),
+			Severity: SeverityError,
+		})
+	}
+	return transformedText, currentIssues
+}
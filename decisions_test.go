@@ -0,0 +1,156 @@
+// decisions_test.go
+// package editml_test contains unit tests for partial resolution and the markup profile.
+package editml
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestMarkupProfileRoundTrip tests that the markup profile reproduces the input as written, debug comment lines included.
+func TestMarkupProfileRoundTrip(t *testing.T) {
+	inputText := "%% keep me\nA {+b\\}+ws} {-c-jd} {>n\\<<ws} {=h=}\n{mv~x {+y\\~+ws}~T} z {m:T}\n"
+	expectedOutput := inputText
+
+	doc, _ := ProcessDocument(inputText)
+	output, issues := TransformDocument(doc, ProfileMarkup, nil)
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	if output != expectedOutput {
+		t.Errorf("markup profile: output = %q, want %q", output, expectedOutput)
+	}
+}
+
+// TestApplyDecisions tests resolving some inline edits and keeping the rest.
+func TestApplyDecisions(t *testing.T) {
+	testCases := []struct {
+		name      string
+		decisions map[int]Decision
+		expected  string
+	}{
+		{"none", nil, "Say {+hi \\{there\\}+ws}{-bye-jd} {>why?<} {=now=}."},
+		{"accept all", map[int]Decision{1: DecisionAccept, 2: DecisionAccept, 3: DecisionAccept, 4: DecisionAccept},
+			"Say hi {there}  now."},
+		{"reject all", map[int]Decision{1: DecisionReject, 2: DecisionReject, 3: DecisionReject, 4: DecisionReject},
+			"Say bye  now."},
+		{"mixed", map[int]Decision{1: DecisionAccept, 3: DecisionReject},
+			"Say hi {there}{-bye-jd}  {=now=}."},
+	}
+	doc, _ := ProcessDocument("Say {+hi \\{there\\}+ws}{-bye-jd} {>why?<} {=now=}.")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, issues := ApplyDecisions(doc, DecideByNumber(tc.decisions))
+			if len(issues) > 0 {
+				t.Fatalf("ApplyDecisions returned unexpected issues: %v", issues)
+			}
+			if output != tc.expected {
+				t.Errorf("ApplyDecisions: output = %q, want %q", output, tc.expected)
+			}
+		})
+	}
+}
+
+// TestApplyDecisionsStructural tests that moves are resolved as units, independently of the edits in their block.
+func TestApplyDecisionsStructural(t *testing.T) {
+	inputText := "{mv:T} one {mv~two {+2+ws}~T} three"
+	testCases := []struct {
+		name    string
+		decide  Decider
+		expects string
+	}{
+		{"accept by target", DecideByNumber(map[int]Decision{1: DecisionAccept}), "two {+2+ws} one  three"},
+		{"accept by source", DecideByNumber(map[int]Decision{2: DecisionAccept, 3: DecisionAccept}), "two 2 one  three"},
+		{"reject", DecideByNumber(map[int]Decision{2: DecisionReject}), " one two {+2+ws} three"},
+		{"editor only", DecideByEditor(map[string]Decision{"ws": DecisionReject}), "{mv:T} one {move~two ~T} three"},
+	}
+	doc, _ := ProcessDocument(inputText)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, _ := ApplyDecisions(doc, tc.decide)
+			if output != tc.expects {
+				t.Errorf("ApplyDecisions: output = %q, want %q", output, tc.expects)
+			}
+		})
+	}
+}
+
+// TestMarkupProfileOptions tests the accept and reject options of the markup profile.
+func TestMarkupProfileOptions(t *testing.T) {
+	doc, _ := ProcessDocument("a {+b+ws} {-c-jd} {+d+jd}")
+	output, _ := TransformDocument(doc, ProfileMarkup, ProfileOptions{"accept": "jd", "reject": "#3"})
+	if expected := "a {+b+ws}  "; output != expected {
+		t.Errorf("markup profile options: output = %q, want %q", output, expected)
+	}
+}
+
+// TestApplyDecisionsLiteralMarkup tests that accepted text that would read as markup keeps its Clean View, and other braces are written as they are.
+func TestApplyDecisionsLiteralMarkup(t *testing.T) {
+	inputText := "{+%% not a comment+ws}\nUse {+\\{+x+\\} and {a}+ws} here."
+	expectedOutput := "{=%%=} not a comment\nUse {=\\{=}+x+} and {a} here."
+
+	doc, _ := ProcessDocument(inputText)
+	output, _ := ApplyDecisions(doc, DecideByEditor(map[string]Decision{"ws": DecisionAccept}))
+	if output != expectedOutput {
+		t.Fatalf("ApplyDecisions: output = %q, want %q", output, expectedOutput)
+	}
+	resolved, _ := ProcessDocument(output)
+	clean, _ := TransformDocument(resolved, ProfileCleanView, nil)
+	if expected := "%% not a comment\nUse {+x+} and {a} here."; clean != expected {
+		t.Errorf("Clean View of the resolved document = %q, want %q", clean, expected)
+	}
+}
+
+// TestApplyDecisionsKeepsInput tests that parts of the input no decision touches are copied as written.
+func TestApplyDecisionsKeepsInput(t *testing.T) {
+	inputText := "%% keep me\nText {+x+} here\n%% and me\n{-y-} end\n"
+	doc, _ := ProcessDocument(inputText)
+	if output, _ := ApplyDecisions(doc, nil); output != inputText {
+		t.Errorf("ApplyDecisions without decisions: output = %q, want %q", output, inputText)
+	}
+	expectedOutput := "%% keep me\nText x here\n%% and me\n{-y-} end\n"
+	if output, _ := ApplyDecisions(doc, DecideByNumber(map[int]Decision{1: DecisionAccept})); output != expectedOutput {
+		t.Errorf("ApplyDecisions: output = %q, want %q", output, expectedOutput)
+	}
+}
+
+// TestApplyDecisionsViewsSurvive tests that the output of every decision reads back with the Clean View and original view of the input, also where resolved text meets markup.
+func TestApplyDecisionsViewsSurvive(t *testing.T) {
+	inputs := []string{
+		"{{+-a-+}}",
+		"a{{+=b=+}}c",
+		"x{-{-}+y+} and {+%%+}{- -} note",
+		"{+{+}{-+-}z+}\n{+%% c+} {=\\{=}>n<}",
+		"one{-\n-}%% two {+{+}{=m=}~T}{m:T} {mv~{+{+}-b-}~T}",
+	}
+	for _, inputText := range inputs {
+		doc, _ := ProcessDocument(inputText)
+		edits := 0
+		ApplyDecisions(doc, func(e ChangeEntry) Decision {
+			edits = max(edits, e.Number)
+			return DecisionPending
+		})
+		clean, _ := TransformDocument(doc, ProfileCleanView, nil)
+		original, _ := TransformDocument(doc, ProfileOriginal, nil)
+		// Accepting edits keeps the Clean View, rejecting them the original view.
+		check := func(name string, d Decision, decide Decider) {
+			output, _ := ApplyDecisions(doc, decide)
+			resolved, _ := ProcessDocument(output)
+			profile, expected := ProfileCleanView, clean
+			if d == DecisionReject {
+				profile, expected = ProfileOriginal, original
+			}
+			if got, _ := TransformDocument(resolved, profile, nil); got != expected {
+				t.Errorf("%q, %s: output %q reads as %q, want %q", inputText, name, output, got, expected)
+			}
+		}
+		for _, d := range []Decision{DecisionAccept, DecisionReject} {
+			all := make(map[int]Decision)
+			for i := 1; i <= edits; i++ {
+				all[i] = d
+				check(fmt.Sprintf("%s #%d", d, i), d, DecideByNumber(map[int]Decision{i: d}))
+			}
+			check(string(d)+" all", d, DecideByNumber(all))
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// diff_test.go
+// package editml_test contains unit tests for the original and diff profiles.
+package editml
+
+import (
+	"testing"
+)
+
+// TestOriginalProfile tests that the original profile undoes every edit.
+func TestOriginalProfile(t *testing.T) {
+	inputText := "Keep {-this-jd}{+that+ws} {>note<} {=mark=}. {mv~Moved~T} end {mv:T}"
+	expectedOutput := "Keep this  mark. Moved end "
+
+	doc, _ := ProcessDocument(inputText)
+	output, issues := TransformDocument(doc, ProfileOriginal, nil)
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	if output != expectedOutput {
+		t.Errorf("original profile: output = %q, want %q", output, expectedOutput)
+	}
+}
+
+// TestDiffProfileUnified tests the unified diff and its editor labels.
+func TestDiffProfileUnified(t *testing.T) {
+	inputText := "one\ntwo {-old-jd}{+new+ws}\nthree\nfour\nfive\nsix\nseven{+ more+ab}"
+	expectedOutput := `--- draft.txt
++++ draft.txt
+@@ -1,3 +1,3 @@ jd, ws
+ one
+-two old
++two new
+ three
+@@ -6,2 +6,2 @@ ab
+ six
+-seven
+\ No newline at end of file
++seven more
+\ No newline at end of file
+`
+
+	doc, _ := ProcessDocument(inputText)
+	output, issues := TransformDocument(doc, ProfileDiff, ProfileOptions{"context": "1", "from": "draft.txt", "to": "draft.txt"})
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	if output != expectedOutput {
+		t.Errorf("diff profile (unified): output =\n%s\nwant\n%s", output, expectedOutput)
+	}
+}
+
+// TestDiffProfileNoNewline tests the marker for a last line without a newline.
+func TestDiffProfileNoNewline(t *testing.T) {
+	doc, _ := ProcessDocument("a {+b+}")
+	output, _ := TransformDocument(doc, ProfileDiff, nil)
+	expectedOutput := "--- original\n+++ clean\n@@ -1 +1 @@\n-a \n\\ No newline at end of file\n+a b\n\\ No newline at end of file\n"
+	if output != expectedOutput {
+		t.Errorf("diff profile (no newline): output = %q, want %q", output, expectedOutput)
+	}
+}
+
+// TestDiffProfileSideBySide tests the side-by-side view with wrapping.
+func TestDiffProfileSideBySide(t *testing.T) {
+	inputText := "same\n{-gone\n-jd}a long line{+ that wraps+ws}"
+	expectedOutput := "@@ -1,3 +1,2 @@ jd, ws\n" +
+		"same         same\n" +
+		"gone       | a long lin\n" +
+		"           | e that wra\n" +
+		"           | ps\n" +
+		"a long lin <\n" +
+		"e          <\n"
+
+	doc, _ := ProcessDocument(inputText)
+	output, issues := TransformDocument(doc, ProfileDiff, ProfileOptions{"format": "side-by-side", "width": "23"})
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	if output != expectedOutput {
+		t.Errorf("diff profile (side-by-side): output =\n%s\nwant\n%s", output, expectedOutput)
+	}
+}
+
+// TestDiffProfileIdentical tests that unchanged documents produce no diff.
+func TestDiffProfileIdentical(t *testing.T) {
+	doc, _ := ProcessDocument("no {>comment<} {=changes=}\n")
+	if output, _ := TransformDocument(doc, ProfileDiff, nil); output != "" {
+		t.Errorf("diff profile (identical): output = %q, want empty", output)
+	}
+}
@@ -0,0 +1,94 @@
+// docx.go
+// package editml defines the public API for parsing and transforming EditML documents.
+package editml
+
+import (
+	"fmt"
+
+	"github.com/verkaro/editml-go/model"
+	"github.com/verkaro/editml-go/parser"
+	"github.com/verkaro/editml-go/transformer"
+)
+
+// DOCXOptions configures ExportDOCX; see transformer.DOCXOptions.
+type DOCXOptions = transformer.DOCXOptions
+
+// ExportDOCX renders doc as a Word document with native tracked changes:
+// additions and deletions become insertions and deletions authored by their
+// editor ID, comments become Word comments, highlights become highlighting
+// and moves become tracked moves. It returns the contents of the .docx file.
+// Copies, which Word cannot track, are exported as insertions and reported as
+// warnings. See transformer.DOCXRenderer for details.
+func ExportDOCX(doc *model.Document, opts DOCXOptions) (data []byte, issues []Issue) {
+	return exportPackage(doc, transformer.NewDOCXRenderer(opts))
+}
+
+// packageRenderer is a renderer for a binary package format that reports
+// the constructs the format cannot represent.
+type packageRenderer interface {
+	transformer.Renderer
+	transformer.DiagnosticReporter
+}
+
+// exportPackage renders doc with r and returns the package bytes, with the
+// problems found by the resolver and the renderer.
+func exportPackage(doc *model.Document, r packageRenderer) (data []byte, issues []Issue) {
+	currentIssues := []Issue{}
+	if doc == nil {
+		currentIssues = append(currentIssues, Issue{
+			Message:  "Transformation error: nil document",
+			Severity: SeverityError,
+		})
+		return nil, currentIssues
+	}
+
+	resolved, err := transformer.ResolveDocument(doc)
+	if err != nil {
+		currentIssues = append(currentIssues, errorIssues(err)...)
+		return nil, currentIssues
+	}
+	output, err := transformer.Render(resolved, r)
+	currentIssues = append(currentIssues, diagnosticIssues(resolved.Diagnostics)...)
+	currentIssues = append(currentIssues, diagnosticIssues(r.Diagnostics())...)
+	if err != nil {
+		currentIssues = append(currentIssues, Issue{
+			Message:  fmt.Sprintf("Transformation error: %v", err),
+			Severity: SeverityError,
+		})
+		return nil, currentIssues
+	}
+	return []byte(output), currentIssues
+}
+
+// DOCXImportOptions configures ImportDOCX; see parser.DOCXOptions.
+type DOCXImportOptions = parser.DOCXOptions
+
+// ImportDOCX converts a Word document with tracked changes and comments into
+// an EditML document: insertions and deletions become additions and
+// deletions, comments become a highlight of their range followed by a
+// comment, and tracked moves become moves. Word authors are mapped to editor
+// IDs through opts.Editors. Write the result with the markup profile to get
+// an EditML source file. See parser.ParseDOCX for details.
+func ImportDOCX(data []byte, opts DOCXImportOptions) (doc *model.Document, issues []Issue) {
+	currentIssues := []Issue{}
+	nodes, warnings, err := parser.ParseDOCX(data, opts)
+	if err != nil {
+		currentIssues = append(currentIssues, Issue{
+			Message:  fmt.Sprintf("Parsing error: %v", err),
+			Severity: SeverityError,
+		})
+		return &model.Document{}, currentIssues
+	}
+	for _, w := range warnings {
+		message := fmt.Sprintf("DOCX warning: %s", w.Message)
+		if w.Paragraph > 0 {
+			message = fmt.Sprintf("DOCX warning (paragraph %d): %s", w.Paragraph, w.Message)
+		}
+		currentIssues = append(currentIssues, Issue{
+			Message:  message,
+			Severity: SeverityWarning,
+			Code:     IssueCode(w.Code),
+		})
+	}
+	return &model.Document{Nodes: nodes}, currentIssues
+}
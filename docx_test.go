@@ -0,0 +1,178 @@
+// docx_test.go
+// package editml_test contains unit tests for the DOCX export.
+package editml
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readDOCX returns the parts of a .docx package, checking that each is well-formed XML.
+func readDOCX(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("reading .docx: %v", err)
+	}
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", f.Name, err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		for d := xml.NewDecoder(bytes.NewReader(content)); ; {
+			if _, err := d.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well-formed: %v", f.Name, err)
+			}
+		}
+		parts[f.Name] = string(content)
+	}
+	return parts
+}
+
+// TestExportDOCXInline tests tracked changes, anchored comments and highlights.
+func TestExportDOCXInline(t *testing.T) {
+	doc, _ := ProcessDocument("A {+b+ws} {-c & d-} {=e=}{>why?<jd}\n\nNext\tline.")
+	data, issues := ExportDOCX(doc, DOCXOptions{Author: "Team", Date: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)})
+	if len(issues) > 0 {
+		t.Fatalf("ExportDOCX returned unexpected issues: %v", issues)
+	}
+	parts := readDOCX(t, data)
+
+	for _, want := range []string{
+		`<w:ins w:id="1" w:author="ws" w:date="2026-01-02T00:00:00Z"><w:r><w:t xml:space="preserve">b</w:t></w:r></w:ins>`,
+		`<w:del w:id="2" w:author="Team" w:date="2026-01-02T00:00:00Z"><w:r><w:delText xml:space="preserve">c &amp; d</w:delText></w:r></w:del>`,
+		`<w:commentRangeStart w:id="0"/><w:r><w:rPr><w:highlight w:val="yellow"/></w:rPr><w:t xml:space="preserve">e</w:t></w:r><w:commentRangeEnd w:id="0"/>`,
+		`</w:p><w:p><w:r><w:t xml:space="preserve">Next</w:t><w:tab/><w:t xml:space="preserve">line.</w:t></w:r></w:p>`,
+	} {
+		if !strings.Contains(parts["word/document.xml"], want) {
+			t.Errorf("document.xml does not contain %q:\n%s", want, parts["word/document.xml"])
+		}
+	}
+	if want := `<w:comment w:id="0" w:author="jd" w:date="2026-01-02T00:00:00Z" w:initials="jd"><w:p><w:r><w:t xml:space="preserve">why?</w:t></w:r></w:p></w:comment>`; !strings.Contains(parts["word/comments.xml"], want) {
+		t.Errorf("comments.xml does not contain %q:\n%s", want, parts["word/comments.xml"])
+	}
+	if !strings.Contains(parts["word/settings.xml"], "<w:trackRevisions/>") {
+		t.Errorf("settings.xml does not switch on tracking:\n%s", parts["word/settings.xml"])
+	}
+}
+
+// TestExportDOCXStructural tests tracked moves, tracked paragraph marks and copies.
+func TestExportDOCXStructural(t *testing.T) {
+	doc, _ := ProcessDocument("{mv:T} x {mv~a {+b+}~T} {-gone\n\n-}y {cp~k~C}{cp:C}")
+	data, issues := ExportDOCX(doc, DOCXOptions{})
+	if len(issues) != 1 || issues[0].Code != IssueUnsupportedConstruct || issues[0].Tag != "C" {
+		t.Errorf("ExportDOCX issues = %v, want one warning for the copy", issues)
+	}
+	document := readDOCX(t, data)["word/document.xml"]
+
+	for _, want := range []string{
+		`<w:moveToRangeStart w:id="1" w:name="T" w:author="EditML"/><w:moveTo w:id="2" w:author="EditML"><w:r><w:t xml:space="preserve">a b</w:t></w:r></w:moveTo><w:moveToRangeEnd w:id="1"/>`,
+		`<w:moveFrom w:id="4" w:author="EditML"><w:r><w:delText xml:space="preserve">a </w:delText></w:r></w:moveFrom>`,
+		`<w:p><w:pPr><w:rPr><w:del w:id="6" w:author="EditML"/></w:rPr></w:pPr>`,
+		`<w:ins w:id="7" w:author="EditML"><w:r><w:t xml:space="preserve">k</w:t></w:r></w:ins>`,
+	} {
+		if !strings.Contains(document, want) {
+			t.Errorf("document.xml does not contain %q:\n%s", want, document)
+		}
+	}
+}
+
+// TestImportDOCXRoundTrip tests that a document exported to DOCX imports back to the same EditML.
+func TestImportDOCXRoundTrip(t *testing.T) {
+	inputText := "Hello {+new+ws} {-old-jd} {=hl=}{>why?<jd} world.\n\nSecond {mv~moved ~T} para{-\n\n-ws}joined.\n\n{mv:T} and {+b+}{>on add<jd}"
+	doc, _ := ProcessDocument(inputText)
+	data, _ := ExportDOCX(doc, DOCXOptions{})
+
+	imported, issues := ImportDOCX(data, DOCXImportOptions{Editors: map[string]string{"EditML": ""}})
+	if len(issues) > 0 {
+		t.Fatalf("ImportDOCX returned unexpected issues: %v", issues)
+	}
+	output, _ := TransformDocument(imported, ProfileMarkup, nil)
+	expected := "Hello {+new+ws} {-old-jd} {=hl=}{>why?<jd} world.\n\nSecond {move~moved ~T} para{-\n\n-ws}joined.\n\n{move:T} and {+b+}{>on add<jd}"
+	if output != expected {
+		t.Errorf("DOCX round trip: markup =\n%q\nwant\n%q", output, expected)
+	}
+}
+
+// TestImportDOCXWord tests comments on plain text and the mapping of Word author names.
+func TestImportDOCXWord(t *testing.T) {
+	document := `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		`<w:p><w:r><w:t xml:space="preserve">The </w:t></w:r><w:commentRangeStart w:id="7"/><w:r><w:t>quick</w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve"> fox</w:t></w:r><w:commentRangeEnd w:id="7"/>` +
+		`<w:r><w:commentReference w:id="7"/></w:r>` +
+		`<w:ins w:id="1" w:author="Jane Roe"><w:r><w:t xml:space="preserve"> jumps</w:t></w:r></w:ins>` +
+		`<w:del w:id="2" w:author="Max Mustermann"><w:r><w:delText>.</w:delText></w:r></w:del></w:p>` +
+		`<w:p><w:r><w:t>Done</w:t><w:br/><w:t>here</w:t></w:r></w:p></w:body></w:document>`
+	comments := `<w:comments xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+		`<w:comment w:id="7" w:author="Jane Roe"><w:p><w:r><w:t>Which fox?</w:t></w:r></w:p></w:comment></w:comments>`
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{"word/document.xml": document, "word/comments.xml": comments} {
+		f, _ := zw.Create(name)
+		f.Write([]byte(content))
+	}
+	zw.Close()
+
+	doc, issues := ImportDOCX(buf.Bytes(), DOCXImportOptions{Editors: map[string]string{"Jane Roe": "jr"}})
+	if len(issues) != 1 || issues[0].Code != IssueUnknownEditor || issues[0].Severity != SeverityWarning {
+		t.Errorf("ImportDOCX issues = %v, want one %s warning", issues, IssueUnknownEditor)
+	}
+	output, _ := TransformDocument(doc, ProfileMarkup, nil)
+	if expected := "The {=quick fox=}{>Which fox?<jr}{+ jumps+jr}{-.-MM}\n\nDone\nhere"; output != expected {
+		t.Errorf("ImportDOCX: markup = %q, want %q", output, expected)
+	}
+
+	// An invalid editor ID in the table is not used.
+	doc, issues = ImportDOCX(buf.Bytes(), DOCXImportOptions{Editors: map[string]string{"Jane Roe": "Jane Roe"}})
+	if len(issues) != 3 || issues[0].Code != IssueInvalidEditorID {
+		t.Errorf("ImportDOCX issues = %v, want an %s warning first", issues, IssueInvalidEditorID)
+	}
+	output, _ = TransformDocument(doc, ProfileMarkup, nil)
+	if expected := "The {=quick fox=}{>Which fox?<JR}{+ jumps+JR}{-.-MM}\n\nDone\nhere"; output != expected {
+		t.Errorf("ImportDOCX: markup = %q, want %q", output, expected)
+	}
+
+	if _, issues := ImportDOCX([]byte("not a zip"), DOCXImportOptions{}); len(issues) != 1 || issues[0].Severity != SeverityError {
+		t.Errorf("ImportDOCX of invalid data: issues = %v, want one error", issues)
+	}
+}
+
+// TestImportDOCXLiteralText tests that Word text that would read as EditML is kept as text through markup and reparsing.
+func TestImportDOCXLiteralText(t *testing.T) {
+	document := `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		`<w:p><w:r><w:t xml:space="preserve">JSON {"a": {-1-}} </w:t></w:r>` +
+		`<w:ins w:id="1" w:author="ws"><w:r><w:t>{+x+}</w:t></w:r></w:ins></w:p>` +
+		`<w:p><w:r><w:t>%% not a comment</w:t></w:r>` +
+		`<w:moveFromRangeStart w:id="2" w:name="T"/><w:moveFrom w:id="3" w:author="ws"><w:r><w:t xml:space="preserve"> {=a=}</w:t></w:r></w:moveFrom><w:moveFromRangeEnd w:id="2"/>` +
+		`<w:moveToRangeStart w:id="4" w:name="T"/><w:moveTo w:id="5" w:author="ws"><w:r><w:t xml:space="preserve"> {=a=}</w:t></w:r></w:moveTo><w:moveToRangeEnd w:id="4"/></w:p></w:body></w:document>`
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("word/document.xml")
+	f.Write([]byte(document))
+	zw.Close()
+
+	doc, issues := ImportDOCX(buf.Bytes(), DOCXImportOptions{})
+	if len(issues) > 0 {
+		t.Fatalf("ImportDOCX returned unexpected issues: %v", issues)
+	}
+	output, _ := TransformDocument(doc, ProfileMarkup, nil)
+	if expected := "JSON {\"a\": {=\\{=}-1-}} {+\\{\\+x\\+\\}+ws}\n\n{=%%=} not a comment{move~ {=\\\\{=}=a=}~T}{move:T}"; output != expected {
+		t.Errorf("ImportDOCX: markup = %q, want %q", output, expected)
+	}
+	reparsed, _ := ProcessDocument(output)
+	clean, _ := TransformDocument(reparsed, ProfileCleanView, nil)
+	if expected := "JSON {\"a\": {-1-}} {+x+}\n\n%% not a comment {=a=}"; clean != expected {
+		t.Errorf("Clean View after reparsing = %q, want %q", clean, expected)
+	}
+}
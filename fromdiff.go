@@ -0,0 +1,89 @@
+// fromdiff.go
+// package editml defines the public API for parsing and transforming EditML documents.
+package editml
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/verkaro/editml-go/parser"
+	"github.com/verkaro/editml-go/transformer"
+)
+
+// Codes of the issues reported when generating EditML from two versions of a
+// text.
+const (
+	IssueInvalidEditorID IssueCode = "invalid-editor-id"
+	IssueLineEndings     IssueCode = "line-endings"
+	IssueInexactDiff     IssueCode = "inexact-diff"
+)
+
+// FromDiff generates EditML source from two plain-text versions of a
+// document, for editors who edit a copy of the file instead of writing markup.
+// The texts are compared word by word, and every change becomes a deletion of
+// the original words followed by an addition of the revised ones, attributed
+// to editorID (unattributed if empty). Paragraphs that were moved, unchanged
+// or with small changes, become moves with generated tags, with the changes
+// as edits inside the moved block; see transformer.MarkupFromDiff.
+//
+// The result is checked by parsing it: its Clean View equals revised, and its
+// original view equals original. Unchanged text that happens to read as
+// EditML markup is kept in highlights, where it can be escaped. Line endings
+// are the exception: the parser drops carriage returns before line feeds, so
+// CRLF line endings are converted to LF, with a warning. Any other difference
+// is reported as an error with code IssueInexactDiff.
+//
+// Even with no changes the result is not revised as is when revised ends
+// with a newline: the parser drops the newline that ends its input, so the
+// result ends with one more newline, or its Clean View would lack the last
+// newline of revised.
+func FromDiff(original, revised, editorID string) (outputText string, issues []Issue) {
+	currentIssues := []Issue{}
+	if editorID != "" && !parser.IsEditorID(editorID) {
+		currentIssues = append(currentIssues, Issue{
+			Message:  fmt.Sprintf("Diff error: invalid editor ID %q, want 1 to 5 letters and digits", editorID),
+			Severity: SeverityError,
+			Code:     IssueInvalidEditorID,
+		})
+		return "", currentIssues
+	}
+	if strings.Contains(original, "\r\n") || strings.Contains(revised, "\r\n") {
+		original = strings.ReplaceAll(original, "\r\n", "\n")
+		revised = strings.ReplaceAll(revised, "\r\n", "\n")
+		currentIssues = append(currentIssues, Issue{
+			Message:  "Diff warning: CRLF line endings converted to LF",
+			Severity: SeverityWarning,
+			Code:     IssueLineEndings,
+		})
+	}
+
+	opts := transformer.FromDiffOptions{EditorID: editorID, DetectMoves: true}
+	outputText = transformer.MarkupFromDiff(original, revised, opts)
+	if !diffRoundTrips(outputText, original, revised) {
+		opts.ProtectBraces = true
+		outputText = transformer.MarkupFromDiff(original, revised, opts)
+		if !diffRoundTrips(outputText, original, revised) {
+			currentIssues = append(currentIssues, Issue{
+				Message:  "Diff error: the generated EditML does not reproduce both versions exactly",
+				Severity: SeverityError,
+				Code:     IssueInexactDiff,
+			})
+		}
+	}
+	return outputText, currentIssues
+}
+
+// diffRoundTrips reports whether the original view and the Clean View of
+// the EditML source are original and revised.
+func diffRoundTrips(source, original, revised string) bool {
+	doc, issues := ProcessDocument(source)
+	if len(issues) > 0 {
+		return false
+	}
+	clean, issues := TransformDocument(doc, ProfileCleanView, nil)
+	if len(issues) > 0 || clean != revised {
+		return false
+	}
+	before, issues := TransformDocument(doc, ProfileOriginal, nil)
+	return len(issues) == 0 && before == original
+}
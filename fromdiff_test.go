@@ -0,0 +1,118 @@
+// fromdiff_test.go
+// package editml_test contains unit tests for generating EditML from two versions of a text.
+package editml
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestFromDiff tests that changed words become attributed deletions and additions.
+func TestFromDiff(t *testing.T) {
+	original := "The quick brown fox jumps over the dog.\n"
+	revised := "The slow red fox jumps over the lazy dog.\n"
+	output, issues := FromDiff(original, revised, "ws")
+	if len(issues) > 0 {
+		t.Fatalf("FromDiff: unexpected issues: %v", issues)
+	}
+	expected := "The {-quick brown-ws}{+slow red+ws} fox jumps over the {+lazy +ws}dog.\n\n"
+	if output != expected {
+		t.Errorf("FromDiff:\nExpected: %q\nGot:      %q", expected, output)
+	}
+}
+
+// TestFromDiffExact tests that both views of the generated markup reproduce the two versions.
+func TestFromDiffExact(t *testing.T) {
+	cases := []struct{ name, original, revised string }{
+		{"identical", "Nothing changes.\n", "Nothing changes.\n"},
+		{"empty original", "", "All new\ntext"},
+		{"empty revised", "All gone\n", ""},
+		{"trailing newlines", "a\n", "a\n\n\n"},
+		{"markup in text", "Write {+x+} for an addition.", "Write {+x+} for additions."},
+		{"escapes in edits", "a b", "a {-x-} \\ } b"},
+		{"debug comment lines", "one\n%% two\nthree", "one\n%% two\n%% 2\nthree\n"},
+		{"paragraphs", "First.\n\nSecond one.\n", "First, changed.\n\nSecond.\n\nThird.\n"},
+		{"moved last paragraph", "A a.\n\nB b.\n\nC c.", "C c.\n\nA a.\n\nB b."},
+		{"moved to the end", "A\n\nB\n\nC", "A\n\nC\n\nB"},
+		{"moved paragraphs with edits", "%% one\n\nTwo {+x+} three.\n\nFour five six.\n", "Four five seven.\n\n%% one\n\nTwo {+x+} three.\n"},
+	}
+	for _, c := range cases {
+		output, issues := FromDiff(c.original, c.revised, "")
+		if len(issues) > 0 {
+			t.Errorf("%s: unexpected issues: %v\nOutput: %q", c.name, issues, output)
+			continue
+		}
+		doc, _ := ProcessDocument(output)
+		clean, _ := TransformDocument(doc, ProfileCleanView, nil)
+		if clean != c.revised {
+			t.Errorf("%s: Clean View of %q:\nExpected: %q\nGot:      %q", c.name, output, c.revised, clean)
+		}
+		before, _ := TransformDocument(doc, ProfileOriginal, nil)
+		if before != c.original {
+			t.Errorf("%s: original view of %q:\nExpected: %q\nGot:      %q", c.name, output, c.original, before)
+		}
+	}
+}
+
+// TestFromDiffUnchanged tests that unchanged text is written as is, with one more final newline, which the parser drops.
+func TestFromDiffUnchanged(t *testing.T) {
+	for _, c := range []struct{ text, expected string }{
+		{"x", "x"},
+		{"x\n", "x\n\n"},
+		{"x\n\ny\n", "x\n\ny\n\n"},
+	} {
+		output, issues := FromDiff(c.text, c.text, "ws")
+		if len(issues) > 0 || output != c.expected {
+			t.Errorf("FromDiff(%q, %q) = %q with issues %v, want %q", c.text, c.text, output, issues, c.expected)
+		}
+		doc, _ := ProcessDocument(output)
+		if clean, _ := TransformDocument(doc, ProfileCleanView, nil); clean != c.text {
+			t.Errorf("FromDiff(%q, %q): Clean View = %q", c.text, c.text, clean)
+		}
+	}
+}
+
+// TestFromDiffIssues tests invalid editor IDs and the conversion of CRLF line endings.
+func TestFromDiffIssues(t *testing.T) {
+	if _, issues := FromDiff("a", "b", "toolong"); len(issues) != 1 || issues[0].Code != IssueInvalidEditorID {
+		t.Errorf("invalid editor ID: expected one %s issue, got %v", IssueInvalidEditorID, issues)
+	}
+	output, issues := FromDiff("a\r\nb\r\n", "a\r\nc\r\n", "ws")
+	if len(issues) != 1 || issues[0].Code != IssueLineEndings || issues[0].Severity != SeverityWarning {
+		t.Errorf("CRLF: expected one %s warning, got %v", IssueLineEndings, issues)
+	}
+	if expected := "a\n{-b-ws}{+c+ws}\n\n"; output != expected {
+		t.Errorf("CRLF:\nExpected: %q\nGot:      %q", expected, output)
+	}
+}
+
+// TestFromDiffMoves tests that moved paragraphs become moves, with the changes inside them as edits.
+func TestFromDiffMoves(t *testing.T) {
+	original := "Alpha para one.\n\nBeta paragraph two.\n\nGamma paragraph three is here.\n"
+	revised := "Beta paragraph two.\n\nGamma paragraph three is right here.\n\nAlpha para one.\n"
+	output, issues := FromDiff(original, revised, "ws")
+	if len(issues) > 0 {
+		t.Fatalf("FromDiff: unexpected issues: %v", issues)
+	}
+	expected := "{move~Alpha para one.~m1}{-\n\n-ws}Beta paragraph two.\n\nGamma paragraph three is {+right +ws}here.\n{+\n+ws}{move:m1}{+\n+ws}"
+	if output != expected {
+		t.Errorf("FromDiff:\nExpected: %q\nGot:      %q", expected, output)
+	}
+
+	// A moved paragraph with a small change keeps the change inside the move.
+	original = "One two three four five.\n\nMiddle.\n\nEnd."
+	revised = "Middle.\n\nEnd.\n\nOne two three four six."
+	output, issues = FromDiff(original, revised, "ws")
+	if len(issues) > 0 {
+		t.Fatalf("FromDiff: unexpected issues: %v", issues)
+	}
+	if !strings.Contains(output, "{move~One two three four {-five-ws}{+six+ws}.~m1}") || !strings.Contains(output, "{move:m1}") {
+		t.Errorf("FromDiff: expected a move with nested edits, got %q", output)
+	}
+
+	// A paragraph moved to the end, without the blank line after it, is moved.
+	output, _ = FromDiff("A\n\nB\n\nC", "A\n\nC\n\nB", "ws")
+	if !strings.Contains(output, "{move~") || !strings.Contains(output, "{move:m1}") {
+		t.Errorf("FromDiff: expected a move, got %q", output)
+	}
+}
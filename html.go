@@ -0,0 +1,48 @@
+// html.go
+// package editml defines the public API for parsing and transforming EditML documents.
+package editml
+
+import (
+	"fmt"
+
+	"github.com/verkaro/editml-go/model"
+	"github.com/verkaro/editml-go/parser"
+)
+
+// IssueNestedEdit is the code of the warning reported when an imported HTML
+// document has an addition inside a deletion or the other way round.
+const IssueNestedEdit = IssueCode(parser.WarningNestedEdit)
+
+// HTMLImportOptions configures ImportHTML; see parser.HTMLOptions.
+type HTMLImportOptions = parser.HTMLOptions
+
+// ImportHTML converts an HTML document with revision markup, such as a wiki
+// export or a CMS revision view, into an EditML document: <ins> and <del>
+// become additions and deletions, <mark> and highlight spans highlights,
+// and comment spans comments, attributed to the author in their data-*,
+// title or cite attributes, mapped to editor IDs through opts.Editors. Other
+// markup is stripped to plain text. The output of the HTML preview profile
+// is read back exactly, moves and copies included. Write the result with the
+// markup profile to get an EditML source file. See parser.ParseHTML for
+// details.
+func ImportHTML(htmlText string, opts HTMLImportOptions) (doc *model.Document, issues []Issue) {
+	currentIssues := []Issue{}
+	nodes, warnings, err := parser.ParseHTML(htmlText, opts)
+	if err != nil {
+		currentIssues = append(currentIssues, Issue{
+			Message:  fmt.Sprintf("Parsing error: %v", err),
+			Severity: SeverityError,
+		})
+		return &model.Document{}, currentIssues
+	}
+	for _, w := range warnings {
+		currentIssues = append(currentIssues, Issue{
+			Message:  fmt.Sprintf("HTML warning: %s", w.Message),
+			Line:     w.Line,
+			Column:   1,
+			Severity: SeverityWarning,
+			Code:     IssueCode(w.Code),
+		})
+	}
+	return &model.Document{Nodes: nodes}, currentIssues
+}
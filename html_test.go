@@ -0,0 +1,63 @@
+// html_test.go
+// package editml_test contains unit tests for the HTML preview profile.
+package editml
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestHTMLProfileInline tests the Spec 6 elements for inline edits.
+func TestHTMLProfileInline(t *testing.T) {
+	doc, _ := ProcessDocument("A {+b+ws} {-c-} {>d<jd} {=e=} <f>")
+	output, issues := TransformDocument(doc, ProfileHTML, ProfileOptions{"fragment": "true"})
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	expectedOutput := `<div class="editml">
+<p id="editml-p1">A <ins class="editml-addition" data-editor="ws" title="ws">b</ins> <del class="editml-deletion">c</del> <span class="editml-comment" data-editor="jd" title="jd">d</span> <span class="editml-highlight">e</span> &lt;f&gt;</p>
+</div>
+`
+	if output != expectedOutput {
+		t.Errorf("HTML profile: output =\n%s\nwant\n%s", output, expectedOutput)
+	}
+}
+
+// TestHTMLProfileStructuralLinks tests anchors, links in both directions, previews and the tag summary.
+func TestHTMLProfileStructuralLinks(t *testing.T) {
+	inputText := "One {mv:T}.\n\nTwo {mv~Moved {+text+}~T} and {cp~x~C}.\n\n{cp:C} {mv:Q}"
+	doc, _ := ProcessDocument(inputText)
+	output, issues := TransformDocument(doc, ProfileHTML, nil)
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		`<p id="editml-p1">One <a class="editml-move-target editml-resolved" id="editml-tgt-T-1" data-tag="T" title="Moved text" href="#editml-src-T">moved from ¶ 2</a>.</p>`,
+		`<span class="editml-move-source editml-resolved" id="editml-src-T" data-tag="T" title="Moved text">Moved <ins class="editml-addition">text</ins></span><sup class="editml-link">moved to <a href="#editml-tgt-T-1">¶ 1</a></sup>`,
+		`<sup class="editml-link">copied to <a href="#editml-tgt-C-1">¶ 3</a></sup>`,
+		`<span class="editml-move-target editml-unresolved" id="editml-tgt-Q-1" data-tag="Q">{move:Q}</span>`,
+		`<tr class="editml-resolved"><td>T</td><td>move</td><td><a href="#editml-src-T">¶ 2</a></td><td><a href="#editml-tgt-T-1">¶ 1</a></td><td>resolved</td></tr>`,
+		`<tr class="editml-unresolved"><td>Q</td><td>move</td><td>–</td><td><a href="#editml-tgt-Q-1">¶ 3</a></td><td>unresolved</td></tr>`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("HTML profile output does not contain %q:\n%s", want, output)
+		}
+	}
+}
+
+// TestHTMLProfileConflict tests that mismatched operations are reported as conflicting.
+func TestHTMLProfileConflict(t *testing.T) {
+	doc, _ := ProcessDocument("{cp~x~T} {mv:T}")
+	output, _ := TransformDocument(doc, ProfileHTML, ProfileOptions{"fragment": "true"})
+	for _, want := range []string{
+		`<sup class="editml-link">copy T: conflict</sup>`,
+		`<span class="editml-move-target editml-conflicting" id="editml-tgt-T-1" data-tag="T">{move:T}</span>`,
+		`<tr class="editml-conflicting"><td>T</td>`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("HTML profile output does not contain %q:\n%s", want, output)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// htmlimport_test.go
+// package editml_test contains unit tests for importing HTML with revision markup.
+package editml
+
+import (
+	"testing"
+)
+
+// TestImportHTMLRoundTrip tests that the HTML preview of a document, standalone or as a fragment, imports back to the same EditML.
+func TestImportHTMLRoundTrip(t *testing.T) {
+	inputs := []string{
+		"A {+b+ws} {-c-} {>d<jd} {=e=} <f> & \"q\"\n\nPara {+x \\} y+}{++}.",
+		"One {move:T}.\n\nTwo {move~Moved {+text+ws} \\~ here~T} and {copy~x~C}.\n\n  {copy:C} {move:Q}",
+		"{+start+}\n\n\nend",
+		"",
+	}
+	for _, input := range inputs {
+		doc, _ := ProcessDocument(input)
+		expected, issues := TransformDocument(doc, ProfileMarkup, nil)
+		if len(issues) > 0 {
+			t.Fatalf("TransformDocument(%q): unexpected issues: %v", input, issues)
+		}
+		for _, fragment := range []string{"true", "false"} {
+			preview, _ := TransformDocument(doc, ProfileHTML, ProfileOptions{"fragment": fragment})
+			imported, issues := ImportHTML(preview, HTMLImportOptions{})
+			if len(issues) > 0 {
+				t.Fatalf("ImportHTML(%q): unexpected issues: %v", preview, issues)
+			}
+			output, _ := TransformDocument(imported, ProfileMarkup, nil)
+			if output != expected {
+				t.Errorf("round trip (fragment=%s):\nExpected: %q\nGot:      %q", fragment, expected, output)
+			}
+		}
+	}
+}
+
+// TestImportHTMLRevisions tests generic revision HTML: attribution attributes, stripped tags, paragraphs and whitespace.
+func TestImportHTMLRevisions(t *testing.T) {
+	input := `<!DOCTYPE html>
+<html><head><title>Revision 42</title><script>if (a < b) {}</script></head>
+<body><h1>Notes</h1>
+<p>Some <del cite="https://wiki.example/users/Jane%20Doe">old</del><ins title="bob">new</ins>
+   <b>bold</b> text.</p>
+<ul><li>one <mark data-comment="Check this" data-author="al">two</mark></li><li>three<br>four</li></ul>
+<p><span class="comment" data-user="ws">A remark</span> and <ins data-editor="x1"><del>nested</del></ins></p>
+<pre>a  b
+  c</pre>
+</body></html>`
+	doc, issues := ImportHTML(input, HTMLImportOptions{Editors: map[string]string{"Jane Doe": "jd"}})
+	if len(issues) != 1 || issues[0].Code != IssueNestedEdit {
+		t.Errorf("ImportHTML: expected one nested-edit warning, got %v", issues)
+	}
+	output, _ := TransformDocument(doc, ProfileMarkup, nil)
+	expected := "Notes\n\nSome {-old-jd}{+new+bob} bold text.\n\none {=two=al}{>Check this<al}\nthree\nfour\n\n{>A remark<ws} and {-nested-x1}\n\na  b\n  c"
+	if output != expected {
+		t.Errorf("ImportHTML:\nExpected: %q\nGot:      %q", expected, output)
+	}
+}
+
+// TestImportHTMLUnknownAuthor tests that authors that are not editor IDs get their initials, with a warning.
+func TestImportHTMLUnknownAuthor(t *testing.T) {
+	doc, issues := ImportHTML(`<p><ins data-author="Mary Ann Smith">a</ins> <del data-author="Mary Ann Smith">b</del></p>`, HTMLImportOptions{})
+	if len(issues) != 1 || issues[0].Code != IssueUnknownEditor {
+		t.Errorf("ImportHTML: expected one unknown-editor warning, got %v", issues)
+	}
+	output, _ := TransformDocument(doc, ProfileMarkup, nil)
+	if expected := "{+a+MAS} {-b-MAS}"; output != expected {
+		t.Errorf("ImportHTML:\nExpected: %q\nGot:      %q", expected, output)
+	}
+
+	// An invalid editor ID in the table is not used.
+	doc, issues = ImportHTML(`<p><ins data-author="Mary Ann Smith">a</ins> <del data-author="Mary Ann Smith">b</del></p>`, HTMLImportOptions{Editors: map[string]string{"Mary Ann Smith": "Mary Ann"}})
+	if len(issues) != 2 || issues[0].Code != IssueInvalidEditorID || issues[1].Code != IssueUnknownEditor {
+		t.Errorf("ImportHTML: expected an invalid-editor-id and an unknown-editor warning, got %v", issues)
+	}
+	output, _ = TransformDocument(doc, ProfileMarkup, nil)
+	if expected := "{+a+MAS} {-b-MAS}"; output != expected {
+		t.Errorf("ImportHTML:\nExpected: %q\nGot:      %q", expected, output)
+	}
+}
+
+// TestImportHTMLLiteralText tests that HTML text that would read as EditML is kept as text through markup and reparsing.
+func TestImportHTMLLiteralText(t *testing.T) {
+	doc, _ := ImportHTML(`<p>use {+x+} literally, {like this}</p><pre>%% kept</pre>`, HTMLImportOptions{})
+	output, _ := TransformDocument(doc, ProfileMarkup, nil)
+	if expected := "use {=\\{=}+x+} literally, {like this}\n\n{=%%=} kept"; output != expected {
+		t.Errorf("ImportHTML:\nExpected: %q\nGot:      %q", expected, output)
+	}
+	reparsed, _ := ProcessDocument(output)
+	clean, _ := TransformDocument(reparsed, ProfileCleanView, nil)
+	if expected := "use {+x+} literally, {like this}\n\n%% kept"; clean != expected {
+		t.Errorf("Clean View after reparsing:\nExpected: %q\nGot:      %q", expected, clean)
+	}
+}
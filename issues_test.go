@@ -0,0 +1,102 @@
+// issues_test.go
+// package editml_test contains unit tests for structural transformation issues and fallbacks.
+package editml
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestStructuralIssueOperationMismatch tests that a mismatched target is reported with code, tag and position.
+func TestStructuralIssueOperationMismatch(t *testing.T) {
+	doc, _ := ProcessDocument("%% note\nA {cp~x~T} B\nC {mv:T}.")
+	output, issues := TransformDocument(doc, ProfileCleanView, nil)
+
+	if strings.Contains(output, "ERROR_") {
+		t.Errorf("Clean View contains an error string: %q", output)
+	}
+	if len(issues) != 1 {
+		t.Fatalf("TransformDocument issues = %v, want one issue", issues)
+	}
+	issue := issues[0]
+	if issue.Code != IssueOperationMismatch || issue.Tag != "T" || issue.Severity != SeverityError {
+		t.Errorf("issue = %+v, want %s error for tag T", issue, IssueOperationMismatch)
+	}
+	if issue.Line != 3 || issue.Column != 3 || issue.EndLine != 3 || issue.EndColumn != 9 {
+		t.Errorf("issue position = L%d:%d-L%d:%d, want L3:3-L3:9", issue.Line, issue.Column, issue.EndLine, issue.EndColumn)
+	}
+}
+
+// TestStructuralIssueFallbacks tests the rendering options for constructs that cannot be transformed.
+func TestStructuralIssueFallbacks(t *testing.T) {
+	testCases := []struct {
+		fallback string
+		expected string
+	}{
+		{"", "A {copy~x {+y+}~T} B {move:T}."},
+		{"markup", "A {copy~x {+y+}~T} B {move:T}."},
+		{"content", "A {copy~x {+y+}~T} B x y."},
+		{"omit", "A {copy~x {+y+}~T} B ."},
+	}
+	doc, _ := ProcessDocument("A {cp~x {+y+}~T} B {mv:T}.")
+	for _, tc := range testCases {
+		output, issues := TransformDocument(doc, ProfileCleanView, ProfileOptions{"fallback": tc.fallback})
+		if output != tc.expected {
+			t.Errorf("fallback %q: output = %q, want %q", tc.fallback, output, tc.expected)
+		}
+		if len(issues) != 1 {
+			t.Errorf("fallback %q: issues = %v, want one issue", tc.fallback, issues)
+		}
+	}
+
+	if _, issues := TransformDocument(doc, ProfileCleanView, ProfileOptions{"fallback": "bogus"}); len(issues) != 1 || issues[0].Code != "" {
+		t.Errorf("invalid fallback: issues = %v, want one uncoded error", issues)
+	}
+}
+
+// TestStructuralIssueBlockError tests that an unresolvable block is reported at its source, and that the text of a move that cannot be applied is kept.
+func TestStructuralIssueBlockError(t *testing.T) {
+	nodes, _ := Parse("A {mv~a {m:X} b {m:X}~T} B {mv:T}.")
+	output, issues := TransformCleanView(nodes)
+	if expected := "A {move~a {m:X} b {m:X}~T} B {move:T}."; output != expected {
+		t.Errorf("TransformCleanView: output = %q, want %q", output, expected)
+	}
+	if len(issues) != 1 || issues[0].Code != IssueBlockResolveError || issues[0].Tag != "T" {
+		t.Errorf("TransformCleanView: issues = %v, want one %s issue for tag T", issues, IssueBlockResolveError)
+	}
+
+	doc, _ := ProcessDocument("A {mv~a {m:X} b {m:X}~T} B {mv:T}.")
+	for fallback, expected := range map[string]string{"content": "A a {m:X} b {m:X} B .", "omit": "A  B ."} {
+		if output, _ := TransformDocument(doc, ProfileCleanView, ProfileOptions{"fallback": fallback}); output != expected {
+			t.Errorf("fallback %q: output = %q, want %q", fallback, output, expected)
+		}
+	}
+}
+
+// TestStructuralIssueConflicts tests that duplicate sources and multiple move targets are reported at every occurrence.
+func TestStructuralIssueConflicts(t *testing.T) {
+	doc, _ := ProcessDocument("{mv~a~T} {mv~b~T}\n{mv:U} {mv~c~U} {mv:U}")
+	output, issues := TransformDocument(doc, ProfileCleanView, nil)
+	if output != "" {
+		t.Errorf("TransformDocument: output = %q, want none", output)
+	}
+	expected := []struct {
+		code      IssueCode
+		tag       string
+		line, col int
+	}{
+		{IssueDuplicateSource, "T", 1, 1},
+		{IssueDuplicateSource, "T", 1, 10},
+		{IssueMultipleMoveTargets, "U", 2, 1},
+		{IssueMultipleMoveTargets, "U", 2, 17},
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("TransformDocument issues = %v, want %d issues", issues, len(expected))
+	}
+	for i, want := range expected {
+		issue := issues[i]
+		if issue.Code != want.code || issue.Tag != want.tag || issue.Severity != SeverityError || issue.Line != want.line || issue.Column != want.col {
+			t.Errorf("issue %d = %+v, want %s error for tag %s at L%d:%d", i, issue, want.code, want.tag, want.line, want.col)
+		}
+	}
+}
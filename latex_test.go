@@ -0,0 +1,46 @@
+// latex_test.go
+// package editml_test contains unit tests for the LaTeX export profile.
+package editml
+
+import (
+	"testing"
+)
+
+// TestLaTeXProfileBody tests the changes commands, replacements, escaping and paragraph splitting.
+func TestLaTeXProfileBody(t *testing.T) {
+	inputText := "Cost: 5$ & 10% #1 {-less-ws}{+more_x+ws} {-a\n\nb-jd} {>why~^?<jd} {=hi=xy} {+c+}"
+	doc, _ := ProcessDocument(inputText)
+	output, issues := TransformDocument(doc, ProfileLaTeX, ProfileOptions{"output": "body"})
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	expectedOutput := `Cost: 5\$ \& 10\% \#1 \replaced[id=ws]{more\_x}{less} \deleted[id=jd]{a}` + "\n\n" +
+		`\deleted[id=jd]{b} \comment[id=jd]{why\textasciitilde{}\textasciicircum{}?} \highlight[id=xy]{hi} \added{c}`
+	if output != expectedOutput {
+		t.Errorf("LaTeX profile: output =\n%s\nwant\n%s", output, expectedOutput)
+	}
+}
+
+// TestLaTeXProfilePreamble tests the author declarations and the complete document.
+func TestLaTeXProfilePreamble(t *testing.T) {
+	doc, _ := ProcessDocument("{+a+jd} {cp~{-b-ws}~C}{cp:C} {+c+jd}")
+	preamble, _ := TransformDocument(doc, ProfileLaTeX, ProfileOptions{"output": "preamble"})
+	expectedPreamble := "\\usepackage{changes}\n\\definechangesauthor[name={jd}]{jd}\n\\definechangesauthor[name={ws}]{ws}\n"
+	if preamble != expectedPreamble {
+		t.Errorf("LaTeX preamble =\n%s\nwant\n%s", preamble, expectedPreamble)
+	}
+
+	output, issues := TransformDocument(doc, ProfileLaTeX, ProfileOptions{"class": "book"})
+	expectedOutput := "\\documentclass{book}\n\\usepackage[T1]{fontenc}\n" + expectedPreamble +
+		"\\begin{document}\n\\added[id=jd]{a} \\deleted[id=ws]{b}\\added{} \\added[id=jd]{c}\n\\end{document}\n"
+	if output != expectedOutput {
+		t.Errorf("LaTeX document =\n%s\nwant\n%s", output, expectedOutput)
+	}
+	if len(issues) != 1 || issues[0].Code != IssueUnsupportedConstruct || issues[0].Tag != "C" {
+		t.Errorf("LaTeX issues = %v, want one warning for the copy", issues)
+	}
+
+	if _, issues := TransformDocument(doc, ProfileLaTeX, ProfileOptions{"output": "pdf"}); len(issues) != 1 {
+		t.Errorf("invalid output option: issues = %v, want one error", issues)
+	}
+}
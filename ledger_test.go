@@ -0,0 +1,132 @@
+// ledger_test.go
+// package editml_test contains unit tests for the ledger profile.
+package editml
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/verkaro/editml-go/transformer"
+)
+
+// ledgerInput has inline edits, a resolved move and two identical edits.
+const ledgerInput = "First {+new+ws} para.\n\n{move~Moved {-old-jd} text.~m1}\n\nEnd {>ok<xy}.\n{move:m1} x{=y=} x{=y=}"
+
+// ledgerEntries parses the JSON Lines output of the ledger profile for input.
+func ledgerEntries(t *testing.T, input string) []transformer.LedgerEntry {
+	t.Helper()
+	doc, _ := ProcessDocument(input)
+	output, issues := TransformDocument(doc, ProfileLedger, nil)
+	if len(issues) > 0 {
+		t.Fatalf("ledger profile returned unexpected issues: %v", issues)
+	}
+	var entries []transformer.LedgerEntry
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		var e transformer.LedgerEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("ledger profile: invalid JSON line %q: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// TestLedgerProfileJSONL tests the fields of the JSON Lines records.
+func TestLedgerProfileJSONL(t *testing.T) {
+	entries := ledgerEntries(t, ledgerInput)
+	kinds := []string{"addition", "move-source", "deletion", "comment", "move-target", "highlight", "highlight"}
+	if len(entries) != len(kinds) {
+		t.Fatalf("ledger profile: got %d entries, want %d: %v", len(entries), len(kinds), entries)
+	}
+	for i, e := range entries {
+		if e.Kind != kinds[i] {
+			t.Errorf("entry %d: type = %q, want %q", i, e.Kind, kinds[i])
+		}
+	}
+	if e := entries[0]; e.EditorID != "ws" || e.Content != "new" || e.StartLine != 1 || e.StartColumn != 7 ||
+		e.EndLine != 1 || e.EndColumn != 16 || e.Paragraph != "First {+new+ws} para." {
+		t.Errorf("addition entry = %+v", e)
+	}
+	if e := entries[1]; e.Tag != "m1" || e.Status != transformer.StatusResolved || e.Content != "Moved {-old-jd} text." {
+		t.Errorf("move source entry = %+v", e)
+	}
+	if e := entries[2]; e.EditorID != "jd" || e.StartLine != 3 || e.Paragraph != "{move~Moved {-old-jd} text.~m1}" {
+		t.Errorf("deletion inside the moved block = %+v", e)
+	}
+	if e := entries[4]; e.Paragraph != "End {>ok<xy}.\n{move:m1} x{=y=} x{=y=}" {
+		t.Errorf("move target paragraph = %q", e.Paragraph)
+	}
+	ids := make(map[string]bool)
+	for _, e := range entries {
+		if ids[e.ID] {
+			t.Errorf("duplicate ID %q", e.ID)
+		}
+		ids[e.ID] = true
+	}
+
+	// Identical edits in identical paragraphs are numbered.
+	entries = ledgerEntries(t, "Same {+x+}.\n\nSame {+x+}.")
+	if len(entries) != 2 || entries[1].ID != entries[0].ID+"-2" {
+		t.Errorf("identical edits: got IDs %v", entries)
+	}
+}
+
+// TestLedgerProfileStableIDs tests that IDs survive changes elsewhere in the document.
+func TestLedgerProfileStableIDs(t *testing.T) {
+	before := ledgerEntries(t, ledgerInput)
+	after := ledgerEntries(t, "A new opening paragraph {+here+zz}.\n\n"+strings.Replace(ledgerInput, "para.", "paragraph.", 1))
+	if len(after) != len(before)+1 {
+		t.Fatalf("got %d entries after the change, want %d", len(after), len(before)+1)
+	}
+	for i, e := range before {
+		changed := e.Kind == "addition" // The words after it changed.
+		if got := after[i+1].ID; (got == e.ID) == changed {
+			t.Errorf("entry %d (%s): ID %q became %q", i, e.Kind, e.ID, got)
+		}
+	}
+	if after[1].StartLine == before[0].StartLine {
+		t.Errorf("positions should have moved down")
+	}
+}
+
+// TestLedgerProfileCSV tests the RFC 4180 CSV format.
+func TestLedgerProfileCSV(t *testing.T) {
+	doc, _ := ProcessDocument("a {+\"quoted\", text+ws}\n\nb")
+	output, issues := TransformDocument(doc, ProfileLedger, ProfileOptions{"format": "csv"})
+	if len(issues) > 0 {
+		t.Fatalf("ledger profile returned unexpected issues: %v", issues)
+	}
+	if !strings.Contains(output, "\r\n") {
+		t.Errorf("CSV records should end with CRLF: %q", output)
+	}
+	records, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != 2 || records[0][0] != "id" || records[1][3] != `"quoted", text` || records[1][6] != "1" || records[1][10] != "a {+\"quoted\", text+ws}" {
+		t.Errorf("CSV records = %q", records)
+	}
+	if _, issues := TransformDocument(doc, ProfileLedger, ProfileOptions{"format": "xml"}); len(issues) == 0 {
+		t.Errorf("invalid format: expected an issue")
+	}
+}
+
+// TestLedgerProfileLongParagraph tests that the paragraph of an entry is cut around the edit in a document without blank lines.
+func TestLedgerProfileLongParagraph(t *testing.T) {
+	line := strings.Repeat("é", 300) + "\n"
+	input := strings.Repeat(line, 10) + "a {+new+ws} b\n" + strings.Repeat(line, 10)
+	entries := ledgerEntries(t, input)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	p := entries[0].Paragraph
+	if !strings.HasPrefix(p, "…") || !strings.HasSuffix(p, "…") || !strings.Contains(p, "\na {+new+ws} b\n") {
+		t.Errorf("paragraph not cut around the edit: %q", p)
+	}
+	if len(p) > 2*1024+100 || !utf8.ValidString(p) {
+		t.Errorf("paragraph of %d bytes, valid UTF-8 %v", len(p), utf8.ValidString(p))
+	}
+}
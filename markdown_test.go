@@ -0,0 +1,46 @@
+// markdown_test.go
+// package editml_test contains unit tests for the Markdown profile.
+package editml
+
+import (
+	"testing"
+)
+
+// TestMarkdownProfileShow tests suggestions, highlights and footnotes in show mode.
+func TestMarkdownProfileShow(t *testing.T) {
+	inputText := "Intro {+new words +ws}and {- old-jd} text{>check\nthis<xy}, {=key=}{>plain<}.\n\n{+One.\n\nTwo.+}\n"
+	expectedOutput := "Intro <ins>new words</ins> and  ~~old~~ text[^1], ==key==[^2].\n\n<ins>One.</ins>\n\n<ins>Two.</ins>\n\n" +
+		"[^1]: **xy:** check this\n[^2]: plain\n"
+
+	doc, _ := ProcessDocument(inputText)
+	output, issues := TransformDocument(doc, ProfileMarkdown, nil)
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	if output != expectedOutput {
+		t.Errorf("markdown profile (show): output = %q, want %q", output, expectedOutput)
+	}
+}
+
+// TestMarkdownProfileApply tests apply mode with bold highlights and resolved moves.
+func TestMarkdownProfileApply(t *testing.T) {
+	inputText := "A {+b+} {-c-}{=d=} {mv~moved {>n<}~T}end {mv:T}"
+	expectedOutput := "A b **d** end moved [^1]\n\n[^1]: n\n"
+
+	doc, _ := ProcessDocument(inputText)
+	output, issues := TransformDocument(doc, ProfileMarkdown, ProfileOptions{"mode": "apply", "highlight": "bold"})
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	if output != expectedOutput {
+		t.Errorf("markdown profile (apply): output = %q, want %q", output, expectedOutput)
+	}
+}
+
+// TestMarkdownProfileInvalidOption tests that unknown option values are reported.
+func TestMarkdownProfileInvalidOption(t *testing.T) {
+	doc, _ := ProcessDocument("text")
+	if _, issues := TransformDocument(doc, ProfileMarkdown, ProfileOptions{"highlight": "neon"}); len(issues) != 1 {
+		t.Errorf("markdown profile with invalid highlight: issues = %v, want one error", issues)
+	}
+}
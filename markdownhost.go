@@ -0,0 +1,65 @@
+// markdownhost.go
+// package editml defines the public API for parsing and transforming EditML documents.
+package editml
+
+import (
+	"fmt"
+
+	"github.com/verkaro/editml-go/model"
+	"github.com/verkaro/editml-go/parser"
+)
+
+// IssueProtectedMarkup is the code of the warning reported when code or a
+// comment of a host language holds text that reads as EditML, which is kept
+// as text.
+const IssueProtectedMarkup IssueCode = "protected-markup"
+
+// ProcessMarkdown is ProcessDocument for EditML in a Markdown manuscript.
+// Fenced and indented code blocks, inline code spans and <!-- --> comments
+// are protected: they are emitted as TextNodes of their own, and markup
+// inside them, debug comment lines included, is kept as text, so that code
+// samples such as JSON are never read as edits. An edit may still enclose a
+// whole code span, and a backtick inside an edit does not start one. Each protected region that holds EditML-looking text gets
+// an IssueProtectedMarkup warning, in case the markup was meant. See
+// parser.MarkdownRegions for how regions are found.
+func ProcessMarkdown(inputText string) (doc *model.Document, issues []Issue) {
+	regions := parser.MarkdownRegions(inputText)
+	protected := make([]model.Range, len(regions))
+	for i, r := range regions {
+		protected[i] = r.Range
+	}
+	textWithoutDebugComments, offsets := parser.SkipDebugCommentsOutsideWithMap(inputText, protected)
+	local := parser.LocalRanges(protected, offsets)
+	parsedNodes, spans, err := parser.ParseProtectedWithSpans(textWithoutDebugComments, local)
+
+	currentIssues := []Issue{}
+	if err != nil {
+		currentIssues = append(currentIssues, Issue{
+			Message:  fmt.Sprintf("Parsing error: %v", err),
+			Severity: SeverityError,
+		})
+	}
+	lines := parser.NewLineIndex(inputText)
+	for _, r := range regions {
+		if !parser.ContainsEditML(inputText[r.Range.Start:r.Range.End]) {
+			continue
+		}
+		start, end := lines.Position(r.Range.Start), lines.Position(r.Range.End)
+		currentIssues = append(currentIssues, Issue{
+			Message:   fmt.Sprintf("Markdown warning: %s contains EditML markup, which is kept as text", r.Kind),
+			Line:      start.Line,
+			Column:    start.Column,
+			Severity:  SeverityWarning,
+			Code:      IssueProtectedMarkup,
+			EndLine:   end.Line,
+			EndColumn: end.Column,
+		})
+	}
+	doc = &model.Document{
+		Nodes:     parsedNodes,
+		Spans:     parser.RemapSpans(spans, offsets, inputText),
+		Source:    inputText,
+		Protected: protected,
+	}
+	return doc, currentIssues
+}
@@ -0,0 +1,71 @@
+// markdownhost_test.go
+// package editml_test contains unit tests for Markdown-aware parsing.
+package editml
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/verkaro/editml-go/model"
+	"github.com/verkaro/editml-go/parser"
+)
+
+// TestProcessMarkdown tests that code and comments are emitted as protected TextNodes, with warnings for markup in them.
+func TestProcessMarkdown(t *testing.T) {
+	input := "Use `{-x-}` or {+`cfg`+ws}. <!-- {>todo<} -->\n\n```json\n{\"a\": {-1-}}\n%% kept\n```\n\n    {=code=}\n%% dropped\nDone {-now-}."
+	doc, issues := ProcessMarkdown(input)
+	var lines []int
+	for _, issue := range issues {
+		if issue.Code != IssueProtectedMarkup || issue.Severity != SeverityWarning {
+			t.Errorf("ProcessMarkdown: unexpected issue %+v", issue)
+		}
+		lines = append(lines, issue.Line)
+	}
+	if expected := []int{1, 1, 3, 8}; !reflect.DeepEqual(lines, expected) {
+		t.Errorf("ProcessMarkdown: warnings on lines %v, want %v", lines, expected)
+	}
+	if node, ok := doc.Nodes[1].(model.TextNode); !ok || node.Text != "`{-x-}`" {
+		t.Errorf("ProcessMarkdown: expected a protected code span node, got %#v", doc.Nodes[1])
+	}
+
+	output, _ := TransformDocument(doc, ProfileCleanView, nil)
+	expected := "Use `{-x-}` or `cfg`. <!-- {>todo<} -->\n\n```json\n{\"a\": {-1-}}\n%% kept\n```\n\n    {=code=}\nDone ."
+	if output != expected {
+		t.Errorf("Clean View:\nExpected: %q\nGot:      %q", expected, output)
+	}
+
+	// Protected text stays protected in moved blocks.
+	doc, issues = ProcessMarkdown("{move~Run `{+x+}` {+now+}.~M}\n\n{move:M}")
+	output, _ = TransformDocument(doc, ProfileCleanView, nil)
+	if expected := "\n\nRun `{+x+}` now."; output != expected || len(issues) != 1 {
+		t.Errorf("moved block: got %q with issues %v", output, issues)
+	}
+}
+
+// TestMarkdownRegions tests the CommonMark rules for fences, indented code, code spans and comments.
+func TestMarkdownRegions(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []string
+	}{
+		{"~~~~\na\n~~~\nb\n~~~~~\nc", []string{"~~~~\na\n~~~\nb\n~~~~~"}},
+		{"```go\nunclosed {+x+}", []string{"```go\nunclosed {+x+}"}},
+		{"``` a`b\nnot a fence", nil},
+		{"text\n    lazy continuation\n\n    code\n\n    more\n\nafter", []string{"    code\n\n    more"}},
+		{"- item\n\n    item paragraph", nil},
+		{"\\`not code and ``a`b`` and `unclosed\n\nx`", []string{"``a`b``"}},
+		{"<!-- a `b` -->`c <!-- d`", []string{"<!-- a `b` -->", "`c <!-- d`"}},
+		{"Call {+`+}foo()` to start.", nil},
+		{"Call {-`-}foo() to start `x`.", []string{"`x`"}},
+		{"Run `{+x+}` now.", []string{"`{+x+}`"}},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, r := range parser.MarkdownRegions(c.input) {
+			got = append(got, c.input[r.Range.Start:r.Range.End])
+		}
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("MarkdownRegions(%q) = %q, want %q", c.input, got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// model/document.go
+// package model defines the abstract syntax tree (AST) nodes for EditML.
+package model
+
+// Document is the root of a parsed EditML document (Spec 4.3, DocumentNode).
+// It holds the top-level nodes in document order.
+type Document struct {
+	Nodes  []Node // The top-level AST nodes, in document order.
+	Spans  []Span // Optional: Spans[i] is the input range of Nodes[i]. Nil if positions are unknown.
+	Source string // Optional: The original input text the spans refer to.
+
+	// Protected lists the ranges of Source that hold host-language syntax
+	// rather than EditML, such as the passage headers of a Twee file. They are
+	// sorted and disjoint, and markup is never recognized inside them. Nil for
+	// plain EditML documents.
+	Protected []Range
+}
@@ -0,0 +1,29 @@
+// model/position.go
+// package model defines the abstract syntax tree (AST) nodes for EditML.
+package model
+
+// Position is a location in the original EditML input text.
+type Position struct {
+	Offset int // Byte offset from the start of the input (0-based).
+	Line   int // Line number (1-based).
+	Column int // Column number, counted in runes (1-based).
+}
+
+// Span is the half-open range [Start, End) of the input covered by a node,
+// including its markup.
+type Span struct {
+	Start Position
+	End   Position
+
+	// Content maps each byte of the node's unescaped content (TextNode.Text,
+	// InlineEditNode.Content or StructuralSourceNode.BlockContent) to the byte
+	// offset in the input it was read from. It has one extra trailing entry for
+	// the end of the content. It is nil for nodes without content.
+	Content []int
+}
+
+// Range is the half-open range [Start, End) of byte offsets in an input text.
+type Range struct {
+	Start int
+	End   int
+}
@@ -0,0 +1,21 @@
+// odt.go
+// package editml defines the public API for parsing and transforming EditML documents.
+package editml
+
+import (
+	"github.com/verkaro/editml-go/model"
+	"github.com/verkaro/editml-go/transformer"
+)
+
+// ODTOptions configures ExportODT; see transformer.ODTOptions.
+type ODTOptions = transformer.ODTOptions
+
+// ExportODT renders doc as an OpenDocument text file with tracked changes, as
+// opened by LibreOffice: additions and deletions become insertions and
+// deletions created by their editor ID, and comments become annotations.
+// Moves and copies, which OpenDocument cannot track, become deletions and
+// insertions and are reported as warnings. It returns the contents of the
+// .odt file. See transformer.ODTRenderer for details.
+func ExportODT(doc *model.Document, opts ODTOptions) (data []byte, issues []Issue) {
+	return exportPackage(doc, transformer.NewODTRenderer(opts))
+}
@@ -0,0 +1,89 @@
+// odt_test.go
+// package editml_test contains unit tests for the OpenDocument export.
+package editml
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExportODT tests tracked changes, anchored annotations, highlights and whitespace.
+func TestExportODT(t *testing.T) {
+	doc, _ := ProcessDocument("A  {+b+ws} {-c & d-} {=e=}{>why?<jd}\n\nNext\tline.")
+	data, issues := ExportODT(doc, ODTOptions{Date: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)})
+	if len(issues) > 0 {
+		t.Fatalf("ExportODT returned unexpected issues: %v", issues)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("reading .odt: %v", err)
+	}
+	if f := zr.File[0]; f.Name != "mimetype" || f.Method != zip.Store {
+		t.Errorf("first entry = %s (method %d), want stored mimetype", f.Name, f.Method)
+	}
+	content := readDOCX(t, data)["content.xml"]
+
+	for _, want := range []string{
+		`<text:tracked-changes text:track-changes="true"><text:changed-region xml:id="ct1" text:id="ct1"><text:insertion><office:change-info><dc:creator>ws</dc:creator><dc:date>2026-01-02T00:00:00</dc:date></office:change-info></text:insertion></text:changed-region>`,
+		`<text:deletion><office:change-info><dc:creator>EditML</dc:creator><dc:date>2026-01-02T00:00:00</dc:date></office:change-info><text:p>c &amp; d</text:p></text:deletion>`,
+		`<text:p>A <text:s text:c="1"/><text:change-start text:change-id="ct1"/>b<text:change-end text:change-id="ct1"/><text:s text:c="1"/><text:change text:change-id="ct2"/>`,
+		`<office:annotation office:name="editml-note-1"><dc:creator>jd</dc:creator><dc:date>2026-01-02T00:00:00</dc:date><text:p>why?</text:p></office:annotation><text:span text:style-name="EditMLHighlight">e</text:span><office:annotation-end office:name="editml-note-1"/>`,
+		`<text:p>Next<text:tab/>line.</text:p>`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content.xml does not contain %q:\n%s", want, content)
+		}
+	}
+}
+
+// TestExportODTStructural tests that moves and copies become deletions and insertions with warnings.
+func TestExportODTStructural(t *testing.T) {
+	doc, _ := ProcessDocument("{mv:T} x {mv~a {+b+}~T} {cp~k~C}{cp:C}")
+	data, issues := ExportODT(doc, ODTOptions{Author: "Team"})
+	if len(issues) != 3 {
+		t.Errorf("ExportODT issues = %v, want 3 warnings", issues)
+	}
+	for _, issue := range issues {
+		if issue.Code != IssueUnsupportedConstruct || issue.Severity != SeverityWarning {
+			t.Errorf("issue = %+v, want %s warning", issue, IssueUnsupportedConstruct)
+		}
+	}
+	content := readDOCX(t, data)["content.xml"]
+	for _, want := range []string{
+		`<text:p><text:change-start text:change-id="ct1"/>a b<text:change-end text:change-id="ct1"/><text:s text:c="1"/>x <text:change text:change-id="ct2"/>`,
+		`<dc:creator>Team</dc:creator>`,
+		`<text:p>a </text:p></text:deletion>`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content.xml does not contain %q:\n%s", want, content)
+		}
+	}
+}
+
+// TestExportODTNoDate tests that changes and comments are not dated without a date, so that the export is reproducible.
+func TestExportODTNoDate(t *testing.T) {
+	doc, _ := ProcessDocument("A {+b+ws}{>why?<jd}")
+	data, issues := ExportODT(doc, ODTOptions{})
+	if len(issues) > 0 {
+		t.Fatalf("ExportODT returned unexpected issues: %v", issues)
+	}
+	content := readDOCX(t, data)["content.xml"]
+	if strings.Contains(content, "dc:date") {
+		t.Errorf("content.xml should not contain dates:\n%s", content)
+	}
+	for _, want := range []string{
+		`<office:change-info><dc:creator>ws</dc:creator></office:change-info>`,
+		`<dc:creator>jd</dc:creator><text:p>why?</text:p>`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content.xml does not contain %q:\n%s", want, content)
+		}
+	}
+	again, _ := ExportODT(doc, ODTOptions{})
+	if !bytes.Equal(data, again) {
+		t.Errorf("ExportODT without a date should be reproducible")
+	}
+}
@@ -0,0 +1,52 @@
+// pandoc_test.go
+// package editml_test contains unit tests for the Pandoc JSON export profile.
+package editml
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestPandocProfile tests the track-changes spans, comments and paragraph structure.
+func TestPandocProfile(t *testing.T) {
+	doc, _ := ProcessDocument("A  {+b c+ws} {-d\n\ne-} {=f=}{>why?<jd}\nnext")
+	output, issues := TransformDocument(doc, ProfilePandoc, ProfileOptions{"date": "2026-01-02"})
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	var parsed map[string]any
+	if err := json.Unmarshal([]byte(output), &parsed); err != nil {
+		t.Fatalf("Pandoc profile output is not JSON: %v", err)
+	}
+
+	expectedOutput := `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[` +
+		`{"t":"Para","c":[{"t":"Str","c":"A"},{"t":"Space"},` +
+		`{"t":"Span","c":[["",["insertion"],[["author","ws"],["date","2026-01-02T00:00:00Z"]]],[{"t":"Str","c":"b"},{"t":"Space"},{"t":"Str","c":"c"}]]},{"t":"Space"},` +
+		`{"t":"Span","c":[["",["deletion"],[["author","EditML"],["date","2026-01-02T00:00:00Z"]]],[{"t":"Str","c":"d"}]]},` +
+		`{"t":"Span","c":[["",["paragraph-deletion"],[["author","EditML"],["date","2026-01-02T00:00:00Z"]]],[]]}]},` +
+		`{"t":"Para","c":[{"t":"Span","c":[["",["deletion"],[["author","EditML"],["date","2026-01-02T00:00:00Z"]]],[{"t":"Str","c":"e"}]]},{"t":"Space"},` +
+		`{"t":"Span","c":[["0",["comment-start"],[["author","jd"],["date","2026-01-02T00:00:00Z"]]],[{"t":"Str","c":"why?"}]]},` +
+		`{"t":"Span","c":[["",["mark"],[]],[{"t":"Str","c":"f"}]]},` +
+		`{"t":"Span","c":[["0",["comment-end"],[]],[]]},{"t":"SoftBreak"},{"t":"Str","c":"next"}]}]}` + "\n"
+	if output != expectedOutput {
+		t.Errorf("Pandoc profile: output =\n%s\nwant\n%s", output, expectedOutput)
+	}
+}
+
+// TestPandocProfileStructural tests that moves become deletions and insertions with warnings.
+func TestPandocProfileStructural(t *testing.T) {
+	doc, _ := ProcessDocument("{mv~a {+b+}~T} x {mv:T}")
+	output, issues := TransformDocument(doc, ProfilePandoc, ProfileOptions{"author": "Team"})
+	if len(issues) != 2 || issues[0].Code != IssueUnsupportedConstruct || issues[0].Tag != "T" {
+		t.Errorf("Pandoc profile issues = %v, want two warnings for the move", issues)
+	}
+	for _, want := range []string{
+		`{"t":"Span","c":[["",["deletion"],[["author","Team"]]],[{"t":"Str","c":"a"},{"t":"Space"}]]}`,
+		`{"t":"Span","c":[["",["insertion"],[["author","Team"]]],[{"t":"Str","c":"a"},{"t":"Space"},{"t":"Str","c":"b"}]]}`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Pandoc profile output does not contain %s:\n%s", want, output)
+		}
+	}
+}
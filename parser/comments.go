@@ -3,10 +3,11 @@
 package parser
 
 import (
-	"bufio"
 	"strings"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/verkaro/editml-go/model"
 )
 
 // skipDebugComments processes an input string and removes EditML line comments.
@@ -17,38 +18,96 @@ import (
 // For the MVP, this handles line comments. Block comment handling ('%%[...]%%')
 // is a future enhancement.
 func SkipDebugComments(input string) string {
-	var resultLines []string
-	scanner := bufio.NewScanner(strings.NewReader(input))
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		isCommentLine := false
-
-		if strings.HasPrefix(line, "%%") {
-			// Check if it's just "%%" or if the character after "%%" makes it a comment.
-			if len(line) == 2 { // Line is exactly "%%"
-				isCommentLine = true
-			} else {
-				// Decode the first rune after "%%"
-				charAfter, _ := utf8.DecodeRuneInString(line[2:])
-
-				// According to Spec 3.2.1:
-				// "%%" must be followed by an ASCII space (U+0020), a horizontal tab (U+0009),
-				// a newline character, the end of the file, or any non-alphanumeric character.
-				// If %% is immediately followed by an alphanumeric character, %% is treated as literal text.
-				if unicode.IsLetter(charAfter) || unicode.IsDigit(charAfter) {
-					isCommentLine = false // e.g., "%%VERSION" is not a comment
-				} else {
-					isCommentLine = true // e.g., "%% This is a comment", "%%-not-alphanum"
-				}
+	result, _ := SkipDebugCommentsWithMap(input)
+	return result
+}
+
+// SkipDebugCommentsWithMap behaves like SkipDebugComments and also returns an
+// offset map: for each byte of the result, plus one trailing entry for its end,
+// the byte offset in input it was copied from. The end maps to just past the
+// last kept byte, so that spans ending the text do not take in the dropped
+// line break. Parsers use it to report positions in terms of the original
+// input.
+func SkipDebugCommentsWithMap(input string) (string, []int) {
+	return SkipDebugCommentsOutsideWithMap(input, nil)
+}
+
+// SkipDebugCommentsOutsideWithMap is SkipDebugCommentsWithMap for EditML
+// embedded in a host language: lines that start inside one of the sorted
+// protected ranges of input are kept even if they read as comments, since
+// they are host-language text, such as a "%%" line in a code sample.
+func SkipDebugCommentsOutsideWithMap(input string, protected []model.Range) (string, []int) {
+	var sb strings.Builder
+	offsets := make([]int, 0, len(input)+1)
+	keptLines := 0
+	end := 0 // Offset in input just past the last kept byte.
+
+	for lineStart := 0; lineStart < len(input); {
+		// Lines are split like bufio.ScanLines: on '\n', dropping a trailing '\r'.
+		lineEnd := strings.IndexByte(input[lineStart:], '\n')
+		next := len(input)
+		if lineEnd < 0 {
+			lineEnd = len(input)
+		} else {
+			lineEnd += lineStart
+			next = lineEnd + 1
+		}
+		line := input[lineStart:lineEnd]
+		line = strings.TrimSuffix(line, "\r")
+
+		if !isDebugCommentLine(line) || inRanges(lineStart, protected) {
+			// Rejoin with newline. Note: This might alter original newline conventions if mixed (e.g. \r\n vs \n)
+			// but is generally fine for typical text processing.
+			if keptLines > 0 {
+				sb.WriteByte('\n')
+				offsets = append(offsets, lineStart-1) // The newline that ended the previous kept line.
+			}
+			sb.WriteString(line)
+			for i := 0; i < len(line); i++ { // One offset per byte, not per rune.
+				offsets = append(offsets, lineStart+i)
 			}
+			keptLines++
+			end = lineStart + len(line)
 		}
+		lineStart = next
+	}
+	offsets = append(offsets, end)
+	return sb.String(), offsets
+}
 
-		if !isCommentLine {
-			resultLines = append(resultLines, line)
+// DebugCommentStarts returns, in order, the offsets of the lines of input that
+// SkipDebugCommentsOutsideWithMap drops as debug comments.
+func DebugCommentStarts(input string, protected []model.Range) []int {
+	var starts []int
+	for lineStart := 0; lineStart < len(input); {
+		next := len(input)
+		if i := strings.IndexByte(input[lineStart:], '\n'); i >= 0 {
+			next = lineStart + i + 1
 		}
+		if isDebugCommentLine(firstLine(input[lineStart:])) && !inRanges(lineStart, protected) {
+			starts = append(starts, lineStart)
+		}
+		lineStart = next
+	}
+	return starts
+}
+
+// isDebugCommentLine reports whether line is an EditML line comment.
+func isDebugCommentLine(line string) bool {
+	if !strings.HasPrefix(line, "%%") {
+		return false
 	}
-	// Rejoin with newline. Note: This might alter original newline conventions if mixed (e.g. \r\n vs \n)
-	// but is generally fine for typical text processing.
-	return strings.Join(resultLines, "\n")
+	// Check if it's just "%%" or if the character after "%%" makes it a comment.
+	if len(line) == 2 { // Line is exactly "%%"
+		return true
+	}
+	// Decode the first rune after "%%"
+	charAfter, _ := utf8.DecodeRuneInString(line[2:])
+
+	// According to Spec 3.2.1:
+	// "%%" must be followed by an ASCII space (U+0020), a horizontal tab (U+0009),
+	// a newline character, the end of the file, or any non-alphanumeric character.
+	// If %% is immediately followed by an alphanumeric character, %% is treated as literal text.
+	// e.g., "%%VERSION" is not a comment, "%% This is a comment" and "%%-not-alphanum" are.
+	return !(unicode.IsLetter(charAfter) || unicode.IsDigit(charAfter))
 }
@@ -0,0 +1,253 @@
+// parser/criticmarkup.go
+// package parser provides functionality to parse EditML text into an AST.
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// CriticMarkupOptions configures ParseCriticMarkup.
+type CriticMarkupOptions struct {
+	// Editors maps the names used in CriticMarkup attributions ("@name") to
+	// EditML editor IDs. Names that are not listed are used as editor IDs
+	// as they are, if they are valid ones (Spec 3.3.2). Entries mapping to
+	// invalid IDs are not used.
+	Editors map[string]string
+}
+
+// Codes of the warnings returned by ParseCriticMarkup.
+const (
+	// WarningMalformedMarkup: CriticMarkup that is not closed or, for a
+	// substitution, lacks its "~>" separator. It is kept as plain text.
+	WarningMalformedMarkup = "malformed-markup"
+	// WarningUnknownEditor: an attribution names an editor that is neither
+	// in CriticMarkupOptions.Editors nor a valid editor ID.
+	WarningUnknownEditor = "unknown-editor"
+	// WarningInvalidEditorID: the editor table maps a name to something that
+	// is neither a valid editor ID nor "". The entry is not used.
+	WarningInvalidEditorID = "invalid-editor-id"
+)
+
+// CriticMarkupWarning describes CriticMarkup that could not be converted
+// exactly.
+type CriticMarkupWarning struct {
+	Code    string
+	Offset  int // Byte offset in the input where the construct starts.
+	Message string
+}
+
+// criticOpeners maps the opening delimiter of every CriticMarkup construct to
+// its closing delimiter.
+var criticOpeners = map[string]string{
+	"{++": "++}",
+	"{--": "--}",
+	"{~~": "~~}",
+	"{==": "==}",
+	"{>>": "<<}",
+}
+
+var (
+	// attributionRegex splits a CriticMarkup comment into the attributed name
+	// and the remaining text: "@name", "@name: text" or "@name text".
+	attributionRegex = regexp.MustCompile(`^\s*@([^\s:]+)(?::\s*|\s+|$)((?s).*)$`)
+	// editorIDRegex matches a valid EditML editor ID (Spec 3.3.2).
+	editorIDRegex = regexp.MustCompile(`^` + editorIDPattern + `$`)
+)
+
+// IsEditorID reports whether id is a valid editor ID: 1 to 5 ASCII letters
+// and digits (Spec 3.3.2).
+func IsEditorID(id string) bool {
+	return editorIDRegex.MatchString(id)
+}
+
+// ParseCriticMarkup converts CriticMarkup text into EditML nodes, with the
+// span of every node in input:
+//
+//   - {++text++} becomes an addition, {--text--} a deletion, {==text==} a
+//     highlight and {>>text<<} a comment;
+//   - {~~old~>new~~} becomes a deletion of old followed by an addition of new;
+//   - a comment starting with "@name" is attributed to that editor, and a
+//     comment holding nothing but "@name" right after another construct
+//     attributes that construct instead of becoming a comment.
+//
+// Text outside CriticMarkup is kept as written. Malformed constructs are kept
+// as text and reported as warnings, like attributions to unknown editors.
+func ParseCriticMarkup(input string, opts CriticMarkupOptions) ([]model.Node, []model.Span, []CriticMarkupWarning) {
+	p := &criticParser{input: input, opts: opts, lines: NewLineIndex(input)}
+	p.parse()
+	return p.nodes, p.spans, p.warnings
+}
+
+// criticParser holds the state of one ParseCriticMarkup call.
+type criticParser struct {
+	input    string
+	opts     CriticMarkupOptions
+	lines    *LineIndex
+	nodes    []model.Node
+	spans    []model.Span
+	warnings []CriticMarkupWarning
+}
+
+func (p *criticParser) parse() {
+	textStart, pos := 0, 0
+	for {
+		i := strings.Index(p.input[pos:], "{")
+		if i < 0 {
+			break
+		}
+		start := pos + i
+		opener := p.input[start:min(start+3, len(p.input))]
+		closer, ok := criticOpeners[opener]
+		if !ok {
+			pos = start + 1
+			continue
+		}
+		contentStart := start + len(opener)
+		j := strings.Index(p.input[contentStart:], closer)
+		if j < 0 {
+			p.warn(WarningMalformedMarkup, start, fmt.Sprintf("unclosed CriticMarkup %q, kept as text", opener))
+			pos = contentStart
+			continue
+		}
+		contentEnd := contentStart + j
+		end := contentEnd + len(closer)
+		if opener == "{~~" && !strings.Contains(p.input[contentStart:contentEnd], "~>") {
+			p.warn(WarningMalformedMarkup, start, `CriticMarkup substitution without "~>", kept as text`)
+			pos = end
+			continue
+		}
+
+		p.addText(textStart, start)
+		first := len(p.nodes)
+		p.addConstruct(opener, start, contentStart, contentEnd, end)
+		pos, textStart = end, end
+
+		// A bare attribution right after the construct names its editor.
+		if opener != "{>>" {
+			if name, attrEnd, ok := p.bareAttribution(end); ok {
+				if id, ok := p.editorID(name, end); ok {
+					p.attribute(first, id, attrEnd)
+				}
+				pos, textStart = attrEnd, attrEnd
+			}
+		}
+	}
+	p.addText(textStart, len(p.input))
+}
+
+// addConstruct appends the nodes for the construct input[start:end], whose
+// content is input[contentStart:contentEnd].
+func (p *criticParser) addConstruct(opener string, start, contentStart, contentEnd, end int) {
+	content := p.input[contentStart:contentEnd]
+	switch opener {
+	case "{++":
+		p.addEdit(model.EditTypeAddition, content, "", start, end, contentStart)
+	case "{--":
+		p.addEdit(model.EditTypeDeletion, content, "", start, end, contentStart)
+	case "{==":
+		p.addEdit(model.EditTypeHighlight, content, "", start, end, contentStart)
+	case "{~~":
+		sep := strings.Index(content, "~>")
+		p.addEdit(model.EditTypeDeletion, content[:sep], "", start, contentStart+sep, contentStart)
+		p.addEdit(model.EditTypeAddition, content[sep+2:], "", contentStart+sep, end, contentStart+sep+2)
+	case "{>>":
+		editorID := ""
+		if m := attributionRegex.FindStringSubmatchIndex(content); m != nil {
+			if id, ok := p.editorID(content[m[2]:m[3]], start); ok {
+				editorID = id
+				content, contentStart = content[m[4]:m[5]], contentStart+m[4]
+			}
+		}
+		p.addEdit(model.EditTypeComment, content, editorID, start, end, contentStart)
+	}
+}
+
+// bareAttribution reports whether a comment holding nothing but "@name"
+// starts at offset at, and returns the name and the end of the comment.
+func (p *criticParser) bareAttribution(at int) (string, int, bool) {
+	if !strings.HasPrefix(p.input[at:], "{>>") {
+		return "", 0, false
+	}
+	j := strings.Index(p.input[at+3:], "<<}")
+	if j < 0 {
+		return "", 0, false
+	}
+	m := attributionRegex.FindStringSubmatch(p.input[at+3 : at+3+j])
+	if m == nil || strings.TrimSpace(m[2]) != "" {
+		return "", 0, false
+	}
+	return m[1], at + 3 + j + 3, true
+}
+
+// editorID returns the EditML editor ID for an attributed name, reporting a
+// warning at offset if there is none.
+func (p *criticParser) editorID(name string, offset int) (string, bool) {
+	if id, ok := p.opts.Editors[name]; ok {
+		if id == "" || editorIDRegex.MatchString(id) {
+			return id, true
+		}
+		p.warn(WarningInvalidEditorID, offset, fmt.Sprintf("the editor table maps %q to %q, which is not a valid editor ID (1-5 letters or digits); attribution dropped", name, id))
+		return "", false
+	}
+	if editorIDRegex.MatchString(name) {
+		return name, true
+	}
+	p.warn(WarningUnknownEditor, offset, fmt.Sprintf("editor %q is not a valid editor ID (1-5 letters or digits) and has no entry in the editor table; attribution dropped", name))
+	return "", false
+}
+
+// attribute sets the editor ID of the nodes appended since index first, and
+// extends the span of the last one over its attribution, which ends at end.
+func (p *criticParser) attribute(first int, editorID string, end int) {
+	for i := first; i < len(p.nodes); i++ {
+		if n, ok := p.nodes[i].(model.InlineEditNode); ok {
+			n.EditorID = editorID
+			p.nodes[i] = n
+		}
+	}
+	p.spans[len(p.spans)-1].End = p.lines.Position(end)
+}
+
+// addText appends input[start:end], if it is not empty, as the nodes of
+// LiteralNodes, so that text that would read as EditML stays text.
+func (p *criticParser) addText(start, end int) {
+	if start >= end {
+		return
+	}
+	lineStart := start == 0 || p.input[start-1] == '\n'
+	for _, node := range LiteralNodes(p.input[start:end], lineStart) {
+		length := 0
+		switch n := node.(type) {
+		case model.TextNode:
+			length = len(n.Text)
+		case model.InlineEditNode:
+			length = len(n.Content)
+		}
+		p.nodes = append(p.nodes, node)
+		p.spans = append(p.spans, model.Span{
+			Start:   p.lines.Position(start),
+			End:     p.lines.Position(start + length),
+			Content: identityOffsets(start, length),
+		})
+		start += length
+	}
+}
+
+// addEdit appends an inline edit covering input[start:end], whose content
+// starts at contentStart.
+func (p *criticParser) addEdit(editType model.EditType, content, editorID string, start, end, contentStart int) {
+	p.nodes = append(p.nodes, model.InlineEditNode{EditType: editType, Content: content, EditorID: editorID})
+	p.spans = append(p.spans, model.Span{
+		Start:   p.lines.Position(start),
+		End:     p.lines.Position(end),
+		Content: identityOffsets(contentStart, len(content)),
+	})
+}
+
+func (p *criticParser) warn(code string, offset int, message string) {
+	p.warnings = append(p.warnings, CriticMarkupWarning{Code: code, Offset: offset, Message: message})
+}
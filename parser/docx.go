@@ -0,0 +1,499 @@
+// parser/docx.go
+// package parser provides functionality to parse EditML text into an AST.
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// DOCXOptions configures ParseDOCX.
+type DOCXOptions struct {
+	// Editors maps Word author names to EditML editor IDs. Authors that are
+	// not listed are used as editor IDs as they are, if they are valid ones
+	// (Spec 3.3.2); otherwise their initials are used, with a warning.
+	// Entries mapping to invalid IDs are not used, with a warning.
+	Editors map[string]string
+}
+
+// Codes of the warnings returned by ParseDOCX, in addition to
+// WarningUnknownEditor and WarningInvalidEditorID.
+const (
+	// WarningUnpairedMove: one side of a Word move has no counterpart; it is
+	// imported as a deletion or an addition.
+	WarningUnpairedMove = "unpaired-move"
+)
+
+// DOCXWarning describes part of a Word document that could not be converted
+// exactly.
+type DOCXWarning struct {
+	Code      string
+	Paragraph int // Number of the paragraph in the document body (1-based).
+	Message   string
+}
+
+// wordNamespace is the namespace of WordprocessingML elements.
+const wordNamespace = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+
+// ParseDOCX converts the body of a Word document (.docx) with tracked changes
+// into EditML nodes:
+//
+//   - inserted runs (w:ins) become additions and deleted runs (w:del)
+//     deletions, attributed to their author; tracked paragraph marks become
+//     added or deleted blank lines;
+//   - a comment becomes a comment node after its anchored range, and plain
+//     text in the range becomes a highlight; ranges holding tracked changes
+//     keep them, followed by the comment;
+//   - highlighted runs become highlights;
+//   - a tracked move (w:moveFrom/w:moveTo) becomes a move source holding the
+//     moved-away text, and a move target.
+//
+// Paragraphs are separated by blank lines, line breaks become newlines and
+// tabs become tab characters. Formatting is not imported. It returns an error
+// if data is not a Word document.
+func ParseDOCX(data []byte, opts DOCXOptions) ([]model.Node, []DOCXWarning, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a .docx package: %v", err)
+	}
+	parts := make(map[string][]byte)
+	for _, f := range zr.File {
+		if f.Name != "word/document.xml" && f.Name != "word/comments.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %v", f.Name, err)
+		}
+		parts[f.Name], err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %v", f.Name, err)
+		}
+	}
+	if parts["word/document.xml"] == nil {
+		return nil, nil, fmt.Errorf("not a .docx package: word/document.xml is missing")
+	}
+
+	d := &docxParser{
+		opts:     opts,
+		comments: make(map[string]docxComment),
+		ranges:   make(map[string]int),
+		editors:  make(map[string]string),
+		sources:  make(map[string]int),
+		targets:  make(map[string]int),
+	}
+	if c := parts["word/comments.xml"]; c != nil {
+		if err := d.readComments(c); err != nil {
+			return nil, nil, fmt.Errorf("reading word/comments.xml: %v", err)
+		}
+	}
+	if err := d.readDocument(parts["word/document.xml"]); err != nil {
+		return nil, nil, fmt.Errorf("reading word/document.xml: %v", err)
+	}
+	return d.finish(), d.warnings, nil
+}
+
+// docxComment is a comment read from comments.xml.
+type docxComment struct {
+	author string
+	text   string
+}
+
+// docxItem is a node under construction. Text of the same kind is merged.
+type docxItem struct {
+	kind   string // "text", an EditType, "move-source" or "move-target".
+	editor string
+	text   strings.Builder
+	tag    string // Move name, for moves.
+	closed bool   // Whether the item takes no more text.
+}
+
+// docxParser holds the state of one ParseDOCX call.
+type docxParser struct {
+	opts     DOCXOptions
+	comments map[string]docxComment
+	editors  map[string]string // Author -> editor ID, as resolved.
+	warnings []DOCXWarning
+
+	items     []*docxItem
+	ranges    map[string]int // Open comment ID -> index of the first item in its range.
+	sources   map[string]int // Move name -> index of its source item.
+	targets   map[string]int // Move name -> index of its target item.
+	paragraph int
+}
+
+// readComments reads the text and author of every comment.
+func (d *docxParser) readComments(data []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var id string
+	var c docxComment
+	var text strings.Builder
+	paragraphs := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "comment":
+				id, c, paragraphs = wordAttr(t, "id"), docxComment{author: wordAttr(t, "author")}, 0
+				text.Reset()
+			case "p":
+				if paragraphs++; paragraphs > 1 {
+					text.WriteString("\n\n")
+				}
+			case "t":
+				var s string
+				if err := dec.DecodeElement(&s, &t); err != nil {
+					return err
+				}
+				text.WriteString(s)
+			case "tab":
+				text.WriteString("\t")
+			case "br", "cr":
+				text.WriteString("\n")
+			}
+		case xml.EndElement:
+			if t.Name.Space == wordNamespace && t.Name.Local == "comment" {
+				c.text = text.String()
+				d.comments[id] = c
+			}
+		}
+	}
+}
+
+// docxState is the tracked-change context of the element being read.
+type docxState struct {
+	kind   string // EditType of the enclosing w:ins or w:del, or "".
+	editor string
+	move   string // "moveFrom" or "moveTo" inside a move container.
+}
+
+// readDocument reads the document body into items.
+func (d *docxParser) readDocument(data []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var stack []docxState // One entry per open w:ins, w:del, w:moveFrom or w:moveTo.
+	var move string       // Name of the open move range.
+	highlight, inBody, inPPr := false, false, false
+	var mark *docxState // Tracked change of the current paragraph mark.
+	current := func() docxState {
+		if len(stack) == 0 {
+			return docxState{}
+		}
+		return stack[len(stack)-1]
+	}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "body":
+				inBody = true
+			case "p":
+				if !inBody {
+					continue
+				}
+				d.paragraph++
+				if d.paragraph > 1 {
+					d.paragraphBreak(mark, move)
+				}
+				mark = nil
+			case "pPr":
+				inPPr = true
+			case "ins", "del":
+				kind := string(model.EditTypeAddition)
+				if t.Name.Local == "del" {
+					kind = string(model.EditTypeDeletion)
+				}
+				s := docxState{kind: kind, editor: d.editor(wordAttr(t, "author"))}
+				if inPPr {
+					mark = &s // Tracked paragraph mark, applied at the next paragraph.
+					continue
+				}
+				stack = append(stack, s)
+			case "moveFrom", "moveTo":
+				stack = append(stack, docxState{move: t.Name.Local})
+			case "moveFromRangeStart", "moveToRangeStart":
+				move = wordAttr(t, "name")
+				d.startMove(move, strings.TrimSuffix(t.Name.Local, "RangeStart"))
+			case "moveFromRangeEnd", "moveToRangeEnd":
+				move = ""
+				d.closeLast()
+			case "rPr":
+				highlight = false
+			case "highlight":
+				highlight = wordAttr(t, "val") != "none"
+			case "r":
+				highlight = false
+			case "t", "delText":
+				var s string
+				if err := dec.DecodeElement(&s, &t); err != nil {
+					return err
+				}
+				d.addText(s, current(), move, highlight)
+			case "tab":
+				if !inPPr {
+					d.addText("\t", current(), move, highlight)
+				}
+			case "br", "cr":
+				d.addText("\n", current(), move, highlight)
+			case "commentRangeStart":
+				d.closeLast()
+				d.ranges[wordAttr(t, "id")] = len(d.items)
+			case "commentReference":
+				d.addComment(wordAttr(t, "id"))
+			}
+		case xml.EndElement:
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "pPr":
+				inPPr = false
+			case "ins", "del":
+				if !inPPr && len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+			case "moveFrom", "moveTo":
+				if len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+			case "body":
+				inBody = false
+			}
+		}
+	}
+}
+
+// paragraphBreak adds the blank line between two paragraphs, as an addition
+// or a deletion if the paragraph mark was tracked. Inside a move range, the
+// blank line is part of the move.
+func (d *docxParser) paragraphBreak(mark *docxState, move string) {
+	if mark == nil || move != "" {
+		d.addText("\n\n", docxState{}, move, false)
+		return
+	}
+	d.addText("\n\n", *mark, "", false)
+}
+
+// addText appends text read in the given context.
+func (d *docxParser) addText(s string, state docxState, move string, highlight bool) {
+	if move != "" {
+		// Text of a move range belongs to the move item started at the range.
+		if last := d.last(); last != nil && (last.kind == "move-source" || last.kind == "move-target") && !last.closed {
+			if state.kind != string(model.EditTypeAddition) || last.kind == "move-target" {
+				last.text.WriteString(s)
+			}
+			return
+		}
+	}
+	kind, editor := "text", ""
+	switch {
+	case state.kind != "":
+		kind, editor = state.kind, state.editor
+	case highlight:
+		kind = string(model.EditTypeHighlight)
+	}
+	d.appendText(kind, editor, s)
+}
+
+// appendText appends s to the last item if it has the same kind and editor,
+// and to a new item otherwise.
+func (d *docxParser) appendText(kind, editor, s string) {
+	if last := d.last(); last != nil && !last.closed && last.kind == kind && last.editor == editor {
+		last.text.WriteString(s)
+		return
+	}
+	item := &docxItem{kind: kind, editor: editor}
+	item.text.WriteString(s)
+	d.items = append(d.items, item)
+}
+
+// startMove starts the item for one side of a move.
+func (d *docxParser) startMove(name, side string) {
+	kind, seen := "move-source", d.sources
+	if side == "moveTo" {
+		kind, seen = "move-target", d.targets
+	}
+	seen[name] = len(d.items)
+	d.items = append(d.items, &docxItem{kind: kind, tag: name})
+}
+
+// addComment appends the comment with the given ID after its range. Plain
+// text in the range becomes a highlight.
+func (d *docxParser) addComment(id string) {
+	c, ok := d.comments[id]
+	if !ok {
+		return
+	}
+	if start, ok := d.ranges[id]; ok {
+		delete(d.ranges, id)
+		plain := start < len(d.items)
+		for _, item := range d.items[start:] {
+			if item.kind != "text" && item.kind != string(model.EditTypeHighlight) {
+				plain = false
+			}
+		}
+		if plain {
+			highlight := &docxItem{kind: string(model.EditTypeHighlight), closed: true}
+			for _, item := range d.items[start:] {
+				highlight.text.WriteString(item.text.String())
+			}
+			d.items = append(d.items[:start], highlight)
+		}
+	}
+	item := &docxItem{kind: string(model.EditTypeComment), editor: d.editor(c.author), closed: true}
+	item.text.WriteString(c.text)
+	d.items = append(d.items, item)
+	d.closeLast()
+}
+
+// closeLast stops the last item from taking more text.
+func (d *docxParser) closeLast() {
+	if last := d.last(); last != nil {
+		last.closed = true
+	}
+}
+
+func (d *docxParser) last() *docxItem {
+	if len(d.items) == 0 {
+		return nil
+	}
+	return d.items[len(d.items)-1]
+}
+
+// moveTagRegex matches names usable as structural tags (Spec 3.4.1).
+var moveTagRegex = regexp.MustCompile(`^` + structuralTagPattern + `$`)
+
+// finish converts the items into nodes. Moves without a counterpart, or whose
+// name is not a valid tag, become deletions and additions. Word text is
+// literal, so text that would read as EditML is protected (see LiteralNodes).
+func (d *docxParser) finish() []model.Node {
+	var nodes []model.Node
+	for _, item := range d.items {
+		text := item.text.String()
+		switch item.kind {
+		case "text":
+			if last, ok := lastNode(nodes).(model.TextNode); ok {
+				nodes[len(nodes)-1] = model.TextNode{Text: last.Text + text}
+				continue
+			}
+			nodes = append(nodes, model.TextNode{Text: text})
+		case "move-source", "move-target":
+			_, hasSource := d.sources[item.tag]
+			_, hasTarget := d.targets[item.tag]
+			if hasSource && hasTarget && moveTagRegex.MatchString(item.tag) {
+				if item.kind == "move-source" {
+					nodes = append(nodes, model.StructuralSourceNode{Operation: model.OperationMove, Tag: item.tag, BlockContent: LiteralMarkup(text, true)})
+				} else {
+					nodes = append(nodes, model.StructuralTargetNode{Operation: model.OperationMove, Tag: item.tag})
+				}
+				continue
+			}
+			editType := model.EditTypeDeletion
+			if item.kind == "move-target" {
+				editType = model.EditTypeAddition
+			}
+			d.warnings = append(d.warnings, DOCXWarning{
+				Code:    WarningUnpairedMove,
+				Message: fmt.Sprintf("move %q has no counterpart or is not a valid tag; imported as a %s", item.tag, editType),
+			})
+			nodes = append(nodes, model.InlineEditNode{EditType: editType, Content: text})
+		default:
+			nodes = append(nodes, model.InlineEditNode{EditType: model.EditType(item.kind), Content: text, EditorID: item.editor})
+		}
+	}
+	return literalText(nodes)
+}
+
+func lastNode(nodes []model.Node) model.Node {
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[len(nodes)-1]
+}
+
+// editor returns the editor ID for a Word author, warning once per author
+// that needs initials or has an invalid entry in the editor table.
+func (d *docxParser) editor(author string) string {
+	if author == "" {
+		return ""
+	}
+	if id, ok := d.editors[author]; ok {
+		return id
+	}
+	if id, ok := d.opts.Editors[author]; ok {
+		if id == "" || editorIDRegex.MatchString(id) {
+			return id
+		}
+		d.warnings = append(d.warnings, DOCXWarning{
+			Code:      WarningInvalidEditorID,
+			Paragraph: d.paragraph,
+			Message:   fmt.Sprintf("the editor table maps author %q to %q, which is not a valid editor ID (1-5 letters or digits); entry ignored", author, id),
+		})
+	}
+	id := author
+	if !editorIDRegex.MatchString(author) {
+		id = initials(author)
+		d.warnings = append(d.warnings, DOCXWarning{
+			Code:      WarningUnknownEditor,
+			Paragraph: d.paragraph,
+			Message:   fmt.Sprintf("author %q has no entry in the editor table; using editor ID %q", author, id),
+		})
+	}
+	d.editors[author] = id
+	return id
+}
+
+// initials returns up to five initials of name, as an editor ID.
+func initials(name string) string {
+	var sb strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		for _, r := range word {
+			if r < 128 && sb.Len() < 5 {
+				sb.WriteRune(r)
+			}
+			break
+		}
+	}
+	if sb.Len() == 0 {
+		return "anon"
+	}
+	return sb.String()
+}
+
+// wordAttr returns the value of the WordprocessingML attribute local of t.
+func wordAttr(t xml.StartElement, local string) string {
+	for _, a := range t.Attr {
+		if a.Name.Local == local && (a.Name.Space == wordNamespace || a.Name.Space == "") {
+			return a.Value
+		}
+	}
+	return ""
+}
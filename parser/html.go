@@ -0,0 +1,601 @@
+// parser/html.go
+// package parser provides functionality to parse EditML text into an AST.
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// HTMLOptions configures ParseHTML.
+type HTMLOptions struct {
+	// Editors maps author names, as found in the attributes of edits, to
+	// EditML editor IDs. Authors that are not listed are used as editor IDs
+	// as they are, if they are valid ones (Spec 3.3.2); otherwise their
+	// initials are used, with a warning. Entries mapping to invalid IDs are
+	// not used, with a warning.
+	Editors map[string]string
+}
+
+// Codes of the warnings returned by ParseHTML, in addition to
+// WarningUnknownEditor and WarningInvalidEditorID.
+const (
+	// WarningNestedEdit: an addition inside a deletion or the other way
+	// round. EditML edits cannot be nested, so the text is imported as the
+	// inner edit.
+	WarningNestedEdit = "nested-edit"
+)
+
+// HTMLWarning describes part of an HTML document that could not be converted
+// exactly.
+type HTMLWarning struct {
+	Code    string
+	Line    int // Line in the HTML input (1-based).
+	Message string
+}
+
+var (
+	// htmlSkippedRegex matches the elements whose content is not text and
+	// may not be well-formed: scripts and style sheets.
+	htmlSkippedRegex = regexp.MustCompile(`(?is)<script\b.*?</script\s*>|<style\b.*?</style\s*>`)
+	// htmlPreviewRegex matches the root element of an HTML preview.
+	htmlPreviewRegex = regexp.MustCompile(`<div class="editml">`)
+	// htmlStructuralClass matches the class of a structural source or target
+	// in an HTML preview.
+	htmlStructuralClass = regexp.MustCompile(`^editml-(move|copy)-(source|target)$`)
+)
+
+// htmlVoidElements are the HTML elements that have no end tag.
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlBlockElements are the HTML elements that start a new paragraph, and
+// htmlLineElements those that start a new line.
+var (
+	htmlBlockElements = map[string]bool{
+		"address": true, "article": true, "aside": true, "blockquote": true, "dl": true, "div": true,
+		"figure": true, "figcaption": true, "footer": true, "h1": true, "h2": true, "h3": true,
+		"h4": true, "h5": true, "h6": true, "header": true, "hr": true, "main": true, "nav": true,
+		"ol": true, "p": true, "pre": true, "section": true, "table": true, "ul": true,
+	}
+	htmlLineElements = map[string]bool{"dd": true, "dt": true, "li": true, "tr": true}
+)
+
+// htmlSkippedElements are the HTML elements whose content is not part of the
+// document text.
+var htmlSkippedElements = map[string]bool{
+	"head": true, "noscript": true, "script": true, "style": true, "template": true, "title": true,
+}
+
+// ParseHTML converts an HTML document with revision markup, such as a wiki
+// or CMS revision view, into EditML nodes:
+//
+//   - <ins> elements become additions and <del> elements deletions;
+//   - <mark> elements become highlights, followed by a comment if they have
+//     a data-comment attribute;
+//   - elements with the class "comment" or "editml-comment" become comments,
+//     and those with the class "highlight" or "editml-highlight" highlights.
+//
+// Edits are attributed to the author named by their data-editor,
+// data-author or data-user attribute, or else by their title or cite
+// attribute; for a cite URL, the last part of its path is used. Other
+// elements are stripped to their text: block elements such as <p> separate
+// paragraphs with a blank line, <li> and <br> start new lines, and
+// whitespace is collapsed outside <pre>.
+//
+// The HTML preview profile of this library is recognised by its
+// <div class="editml"> root and read back exactly: its text is taken as
+// written, structural sources and targets become moves and copies again,
+// and the links and the summary table it adds are skipped. Converting an
+// EditML document to the preview and back gives the document again.
+//
+// It returns an error if input cannot be read as HTML.
+func ParseHTML(input string, opts HTMLOptions) ([]model.Node, []HTMLWarning, error) {
+	input = htmlSkippedRegex.ReplaceAllString(input, "")
+	p := &htmlParser{
+		opts:    opts,
+		editors: make(map[string]string),
+		preview: htmlPreviewRegex.MatchString(input),
+	}
+	p.containers = []*htmlContainer{{}}
+
+	dec := xml.NewDecoder(strings.NewReader(input))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("not valid HTML: %v", err)
+		}
+		p.line, _ = dec.InputPos()
+		switch t := tok.(type) {
+		case xml.StartElement:
+			p.start(t)
+		case xml.EndElement:
+			p.end(strings.ToLower(t.Name.Local))
+		case xml.CharData:
+			p.text(string(t))
+		}
+	}
+	for len(p.stack) > 0 {
+		p.pop()
+	}
+	return p.containers[0].nodes(p.preview), p.warnings, nil
+}
+
+// htmlItem is a node under construction. Text of the same kind is merged
+// until the item is closed.
+type htmlItem struct {
+	kind   string // "text", an EditType, or "source" or "target" for structural nodes.
+	editor string
+	text   strings.Builder
+	op     string // Operation, for structural nodes.
+	tag    string // Tag, for structural nodes.
+	block  []model.Node
+	closed bool
+}
+
+// htmlContainer holds the items of the document, or of the block of a
+// structural source being read.
+type htmlContainer struct {
+	items []*htmlItem
+}
+
+// htmlElement is an open element and what to undo when it ends.
+type htmlElement struct {
+	name   string
+	skip   bool   // Content is skipped.
+	edit   string // EditType of the edit the element opens, if any.
+	editor string
+	pre    bool // Whitespace is kept.
+	source bool // The element is a structural source, with its own container.
+	block  bool // The element separates paragraphs or lines.
+	inRoot bool // The element is the root of an HTML preview.
+
+	comment string // Comment following a <mark>, if any.
+	writes  int    // Text written before the element started.
+}
+
+// htmlParser holds the state of one ParseHTML call.
+type htmlParser struct {
+	opts     HTMLOptions
+	editors  map[string]string // Author -> editor ID, as resolved.
+	warnings []HTMLWarning
+	line     int
+
+	preview    bool // Whether the input is an HTML preview of this library.
+	inRoot     bool // Whether the preview's root element is open.
+	stack      []htmlElement
+	containers []*htmlContainer // The document, then open structural sources.
+
+	// Pending separation before the next text, outside an HTML preview: ""
+	// for none, " " for a space, "\n" for a new line or "\n\n" for a new
+	// paragraph.
+	pending string
+	writes  int // Number of non-empty texts written.
+}
+
+// start handles a start tag.
+func (p *htmlParser) start(t xml.StartElement) {
+	name := strings.ToLower(t.Name.Local)
+	attrs := make(map[string]string)
+	for _, a := range t.Attr {
+		attrs[strings.ToLower(a.Name.Local)] = a.Value
+	}
+	classes := strings.Fields(attrs["class"])
+	e := htmlElement{name: name}
+	switch {
+	case p.skipping() || htmlSkippedElements[name]:
+		e.skip = true
+	case p.preview && !p.inRoot:
+		// Outside the root of a preview, text is ignored.
+		e.inRoot = hasClass(classes, "editml")
+		p.inRoot = e.inRoot
+	case p.preview && (hasClass(classes, "editml-link") || hasClass(classes, "editml-summary")):
+		e.skip = true
+	default:
+		for _, class := range classes {
+			if m := htmlStructuralClass.FindStringSubmatch(class); m != nil && p.depth() == 0 {
+				e.skip = m[2] == "target"
+				e.source = m[2] == "source"
+				p.structural(m[1], m[2], attrs["data-tag"])
+			}
+		}
+		if e.skip || e.source {
+			break
+		}
+		if e.edit = htmlEditType(name, classes); e.edit != "" {
+			e.editor = p.editor(htmlAuthor(attrs))
+			if e.editor == "" {
+				e.editor = p.editorOf() // Nested edits keep the outer attribution.
+			}
+			if outer := p.edit(); (outer == string(model.EditTypeAddition) || outer == string(model.EditTypeDeletion)) &&
+				(e.edit == string(model.EditTypeAddition) || e.edit == string(model.EditTypeDeletion)) && outer != e.edit {
+				p.warn(WarningNestedEdit, fmt.Sprintf("<%s> inside an edit of another kind imported as a %s", name, e.edit))
+			}
+			if name == "mark" {
+				e.comment = attrs["data-comment"]
+			}
+			p.flush() // Separation before the edit is outside it.
+			p.closeLast()
+			e.writes = p.writes
+		}
+		e.pre = name == "pre"
+		switch {
+		case name == "br":
+			p.write("\n")
+		case htmlBlockElements[name]:
+			e.block = true
+			p.separate("\n\n")
+		case htmlLineElements[name]:
+			e.block = true
+			p.separate("\n")
+		case name == "td" || name == "th":
+			p.separate(" ")
+		}
+	}
+	if htmlVoidElements[name] {
+		return
+	}
+	p.stack = append(p.stack, e)
+}
+
+// end handles an end tag: it closes the element and every element opened
+// inside it that was left open. End tags without a start tag are ignored.
+func (p *htmlParser) end(name string) {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		if p.stack[i].name == name {
+			for len(p.stack) > i {
+				p.pop()
+			}
+			return
+		}
+	}
+}
+
+// pop closes the innermost open element.
+func (p *htmlParser) pop() {
+	e := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+	switch {
+	case e.inRoot:
+		p.inRoot = false
+	case e.source:
+		c := p.containers[len(p.containers)-1]
+		p.containers = p.containers[:len(p.containers)-1]
+		source := p.last()
+		source.block = c.nodes(false)
+		source.closed = true
+	case e.edit != "":
+		if p.writes == e.writes {
+			p.appendText(e.edit, e.editor, "") // Empty edits are kept.
+		}
+		p.closeLast()
+		if e.comment != "" {
+			p.appendText(string(model.EditTypeComment), e.editor, e.comment)
+			p.closeLast()
+		}
+	case e.block:
+		p.separate(map[bool]string{true: "\n\n", false: "\n"}[htmlBlockElements[e.name]])
+	}
+}
+
+// text handles character data.
+func (p *htmlParser) text(s string) {
+	if p.skipping() || p.preview && !p.inRoot {
+		return
+	}
+	if p.preview || p.inPre() {
+		p.write(s)
+		return
+	}
+	// Whitespace is collapsed, and dropped where a line starts.
+	for i, word := range strings.Fields(s) {
+		if i > 0 || strings.TrimLeft(s, " \t\r\n\f") != s {
+			p.separate(" ")
+		}
+		p.write(word)
+	}
+	if strings.TrimRight(s, " \t\r\n\f") != s {
+		p.separate(" ")
+	}
+}
+
+// write appends text in the current context, after any pending separation.
+func (p *htmlParser) write(s string) {
+	p.flush()
+	p.appendText(p.kind(), p.editorOf(), s)
+}
+
+// flush writes the pending separation in the current context, unless it
+// would start the text.
+func (p *htmlParser) flush() {
+	if p.pending != "" && p.hasText() {
+		p.appendText(p.kind(), p.editorOf(), p.pending)
+	}
+	p.pending = ""
+}
+
+// separate requests a separation before the next text. Stronger separations
+// win: a paragraph break over a new line, a new line over a space.
+func (p *htmlParser) separate(s string) {
+	if p.preview {
+		return
+	}
+	if len(s) > len(p.pending) || s == "\n" && p.pending == " " {
+		p.pending = s
+	}
+}
+
+// hasText reports whether any text has been written to the current container.
+func (p *htmlParser) hasText() bool {
+	for _, item := range p.containers[len(p.containers)-1].items {
+		if item.text.Len() > 0 || item.kind == "source" || item.kind == "target" {
+			return true
+		}
+	}
+	return false
+}
+
+// structural adds a structural source or target, read from a preview.
+func (p *htmlParser) structural(op, side, tag string) {
+	p.closeLast()
+	item := &htmlItem{kind: side, op: op, tag: tag, closed: side == "target"}
+	c := p.containers[len(p.containers)-1]
+	c.items = append(c.items, item)
+	if side == "source" {
+		p.containers = append(p.containers, &htmlContainer{})
+	}
+}
+
+// appendText appends s to the last item of the current container if it has
+// the same kind and editor, and to a new item otherwise.
+func (p *htmlParser) appendText(kind, editor, s string) {
+	if s != "" {
+		p.writes++
+	}
+	c := p.containers[len(p.containers)-1]
+	if n := len(c.items); n > 0 {
+		if last := c.items[n-1]; !last.closed && last.kind == kind && last.editor == editor {
+			last.text.WriteString(s)
+			return
+		}
+	}
+	item := &htmlItem{kind: kind, editor: editor}
+	item.text.WriteString(s)
+	c.items = append(c.items, item)
+}
+
+// closeLast stops the last item of the current container from taking more text.
+func (p *htmlParser) closeLast() {
+	if last := p.last(); last != nil {
+		last.closed = true
+	}
+}
+
+// last returns the last item of the current container, or, if it has none,
+// the structural source that opened it.
+func (p *htmlParser) last() *htmlItem {
+	for i := len(p.containers) - 1; i >= 0; i-- {
+		if items := p.containers[i].items; len(items) > 0 {
+			return items[len(items)-1]
+		}
+	}
+	return nil
+}
+
+// skipping reports whether the content of an open element is skipped.
+func (p *htmlParser) skipping() bool {
+	return len(p.stack) > 0 && p.stack[len(p.stack)-1].skip
+}
+
+// inPre reports whether a <pre> element is open.
+func (p *htmlParser) inPre() bool {
+	for _, e := range p.stack {
+		if e.pre {
+			return true
+		}
+	}
+	return false
+}
+
+// depth returns the number of open structural sources.
+func (p *htmlParser) depth() int {
+	return len(p.containers) - 1
+}
+
+// edit returns the EditType of the innermost open edit, or "".
+func (p *htmlParser) edit() string {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		if p.stack[i].edit != "" {
+			return p.stack[i].edit
+		}
+	}
+	return ""
+}
+
+// kind returns the kind of item text goes into.
+func (p *htmlParser) kind() string {
+	if edit := p.edit(); edit != "" {
+		return edit
+	}
+	return "text"
+}
+
+// editorOf returns the editor ID of the innermost open edit, or "".
+func (p *htmlParser) editorOf() string {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		if p.stack[i].edit != "" {
+			return p.stack[i].editor
+		}
+	}
+	return ""
+}
+
+// editor returns the editor ID for an author, warning once per author that
+// needs initials or has an invalid entry in the editor table.
+func (p *htmlParser) editor(author string) string {
+	if author == "" {
+		return ""
+	}
+	if id, ok := p.editors[author]; ok {
+		return id
+	}
+	if id, ok := p.opts.Editors[author]; ok {
+		if id == "" || editorIDRegex.MatchString(id) {
+			return id
+		}
+		p.warn(WarningInvalidEditorID, fmt.Sprintf("the editor table maps author %q to %q, which is not a valid editor ID (1-5 letters or digits); entry ignored", author, id))
+	}
+	id := author
+	if !editorIDRegex.MatchString(author) {
+		id = initials(author)
+		p.warn(WarningUnknownEditor, fmt.Sprintf("author %q has no entry in the editor table; using editor ID %q", author, id))
+	}
+	p.editors[author] = id
+	return id
+}
+
+// warn records a warning at the current line.
+func (p *htmlParser) warn(code, message string) {
+	p.warnings = append(p.warnings, HTMLWarning{Code: code, Line: p.line, Message: message})
+}
+
+// nodes converts the items of a container into nodes. In a preview, whose
+// text is EditML source, the newlines the preview adds inside its root are
+// removed. Other HTML text is literal, so text that would read as EditML is
+// protected (see LiteralNodes).
+func (c *htmlContainer) nodes(preview bool) []model.Node {
+	var nodes []model.Node
+	for _, item := range c.items {
+		text := item.text.String()
+		switch item.kind {
+		case "text":
+			if last, ok := lastNode(nodes).(model.TextNode); ok {
+				nodes[len(nodes)-1] = model.TextNode{Text: last.Text + text}
+				continue
+			}
+			nodes = append(nodes, model.TextNode{Text: text})
+		case "source":
+			var block strings.Builder
+			for _, n := range item.block {
+				switch n := n.(type) {
+				case model.TextNode:
+					block.WriteString(n.Text)
+				case model.InlineEditNode:
+					block.WriteString(inlineMarkup(n))
+				}
+			}
+			nodes = append(nodes, model.StructuralSourceNode{Operation: item.op, Tag: item.tag, BlockContent: block.String()})
+		case "target":
+			nodes = append(nodes, model.StructuralTargetNode{Operation: item.op, Tag: item.tag})
+		default:
+			nodes = append(nodes, model.InlineEditNode{EditType: model.EditType(item.kind), Content: text, EditorID: item.editor})
+		}
+	}
+	if preview {
+		if first, ok := firstNode(nodes).(model.TextNode); ok {
+			nodes[0] = model.TextNode{Text: strings.TrimPrefix(first.Text, "\n")}
+		}
+		if last, ok := lastNode(nodes).(model.TextNode); ok {
+			nodes[len(nodes)-1] = model.TextNode{Text: strings.TrimSuffix(last.Text, "\n")}
+		}
+		if first, ok := firstNode(nodes).(model.TextNode); ok && first.Text == "" {
+			nodes = nodes[1:]
+		}
+		if last, ok := lastNode(nodes).(model.TextNode); ok && last.Text == "" {
+			nodes = nodes[:len(nodes)-1]
+		}
+		return nodes
+	}
+	return literalText(nodes)
+}
+
+func firstNode(nodes []model.Node) model.Node {
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
+
+// htmlEditType returns the EditType of the edit an element stands for, or "".
+func htmlEditType(name string, classes []string) string {
+	for _, class := range classes {
+		switch strings.TrimPrefix(class, "editml-") {
+		case "addition":
+			return string(model.EditTypeAddition)
+		case "deletion":
+			return string(model.EditTypeDeletion)
+		case "comment":
+			return string(model.EditTypeComment)
+		case "highlight":
+			return string(model.EditTypeHighlight)
+		}
+	}
+	switch name {
+	case "ins":
+		return string(model.EditTypeAddition)
+	case "del":
+		return string(model.EditTypeDeletion)
+	case "mark":
+		return string(model.EditTypeHighlight)
+	}
+	return ""
+}
+
+// htmlAuthor returns the author named by the attributes of an edit.
+func htmlAuthor(attrs map[string]string) string {
+	for _, name := range []string{"data-editor", "data-author", "data-user", "title"} {
+		if v := strings.TrimSpace(attrs[name]); v != "" {
+			return v
+		}
+	}
+	cite := strings.TrimSpace(attrs["cite"])
+	if u, err := url.Parse(cite); err == nil && u.Scheme != "" {
+		if base := path.Base(strings.TrimSuffix(u.Path, "/")); base != "/" && base != "." {
+			return base
+		}
+	}
+	return cite
+}
+
+// hasClass reports whether classes contains class.
+func hasClass(classes []string, class string) bool {
+	for _, c := range classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// inlineMarkup returns the EditML markup of an inline edit, with its content
+// escaped; see transformer.InlineMarkup, which the parser cannot import.
+func inlineMarkup(n model.InlineEditNode) string {
+	ops := map[model.EditType][2]string{
+		model.EditTypeAddition:  {"+", "+"},
+		model.EditTypeDeletion:  {"-", "-"},
+		model.EditTypeComment:   {">", "<"},
+		model.EditTypeHighlight: {"=", "="},
+	}[n.EditType]
+	var sb strings.Builder
+	for _, r := range n.Content {
+		if strings.ContainsRune(`\{}`+ops[1], r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return "{" + ops[0] + sb.String() + ops[1] + n.EditorID + "}"
+}
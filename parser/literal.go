@@ -0,0 +1,174 @@
+// parser/literal.go
+// package parser provides functionality to parse EditML text into an AST.
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// markupStartRegex matches the opening brace of every EditML construct: a
+// brace followed by an inline operator (Spec 3.3.1), or by a structural
+// keyword and its separator (Spec 3.4).
+var markupStartRegex = regexp.MustCompile(`\{(?:[-+>=]|(?:move|mv|m|copy|cp|c)[~:])`)
+
+// LiteralNodes returns nodes that read back as text when written as EditML
+// source. Text nodes are written as they are and never unescaped, so the
+// opening brace of every sequence that could start an EditML construct, and
+// a "%%" that starts a line as a debug comment, are held in unattributed
+// highlights instead, which the Clean View shows as plain text and whose
+// content can be escaped. Other text, braces included, stays in TextNodes.
+// lineStart tells whether text starts a line of the source.
+func LiteralNodes(text string, lineStart bool) []model.Node {
+	var nodes []model.Node
+	last := 0
+	protect := func(start, end int) {
+		if start > last {
+			nodes = append(nodes, model.TextNode{Text: text[last:start]})
+		}
+		nodes = append(nodes, model.InlineEditNode{EditType: model.EditTypeHighlight, Content: text[start:end]})
+		last = end
+	}
+	for i := 0; i < len(text); {
+		atLineStart := (i == 0 && lineStart) || (i > 0 && text[i-1] == '\n')
+		switch {
+		case atLineStart && isDebugCommentLine(firstLine(text[i:])):
+			protect(i, i+2)
+			i += 2
+		case text[i] == '{' && markupStartRegex.MatchString(text[i:min(i+7, len(text))]):
+			protect(i, i+1)
+			i++
+		default:
+			i++
+		}
+	}
+	if last < len(text) {
+		nodes = append(nodes, model.TextNode{Text: text[last:]})
+	}
+	return nodes
+}
+
+// firstLine returns text up to its first line break.
+func firstLine(text string) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return strings.TrimSuffix(text[:i], "\r")
+	}
+	return text
+}
+
+// LiteralMarkup returns EditML source that reads back as text, written from
+// LiteralNodes.
+func LiteralMarkup(text string, lineStart bool) string {
+	var sb strings.Builder
+	for _, node := range LiteralNodes(text, lineStart) {
+		switch n := node.(type) {
+		case model.TextNode:
+			sb.WriteString(n.Text)
+		case model.InlineEditNode:
+			sb.WriteString(inlineMarkup(n))
+		}
+	}
+	return sb.String()
+}
+
+// literalText replaces every TextNode of nodes, which importers fill with
+// literal text, with its LiteralNodes, so that the nodes read back the same
+// when written as EditML source.
+func literalText(nodes []model.Node) []model.Node {
+	var out []model.Node
+	lineStart := true
+	for _, node := range nodes {
+		text, ok := node.(model.TextNode)
+		if !ok {
+			out = append(out, node)
+			lineStart = false
+			continue
+		}
+		out = append(out, LiteralNodes(text.Text, lineStart)...)
+		if text.Text != "" {
+			lineStart = strings.HasSuffix(text.Text, "\n")
+		}
+	}
+	return out
+}
+
+// ProtectUnintended returns text, EditML source put together from pieces,
+// with every construct that does not start at one of the offsets in intended
+// held as text the way LiteralMarkup holds it, and, if comments is set, every
+// such debug comment line too. Writers that join resolved text and markup
+// need it: pieces that read as text on their own can read as markup together,
+// such as "{" followed by "-a-}". Constructs are found as ProcessDocument
+// finds them, outside the sorted protected ranges of text. Protecting one can
+// reveal another, so text is read again until it reads as intended.
+func ProtectUnintended(text string, intended []int, protected []model.Range, comments bool) string {
+	for {
+		wanted := make(map[int]bool, len(intended))
+		for _, offset := range intended {
+			wanted[offset] = true
+		}
+		var fixes []model.Range // The bytes to hold in highlights, in order.
+		stripped, offsets := text, identityOffsets(0, len(text))
+		if comments {
+			for _, start := range DebugCommentStarts(text, protected) {
+				if !wanted[start] {
+					fixes = append(fixes, model.Range{Start: start, End: start + 2})
+				}
+			}
+			stripped, offsets = SkipDebugCommentsOutsideWithMap(text, protected)
+		}
+		if len(fixes) == 0 {
+			nodes, spans, err := ParseProtectedWithSpans(stripped, LocalRanges(protected, offsets))
+			if err != nil {
+				return text
+			}
+			for i, node := range nodes {
+				if _, ok := node.(model.TextNode); ok {
+					continue
+				}
+				if start := offsets[spans[i].Start.Offset]; !wanted[start] {
+					fixes = append(fixes, model.Range{Start: start, End: start + 1})
+				}
+			}
+		}
+		if len(fixes) == 0 {
+			return text
+		}
+		text, intended, protected = protectRanges(text, intended, protected, fixes)
+	}
+}
+
+// protectRanges holds each of the sorted fixes of text in an unattributed
+// highlight, and moves the intended offsets and protected ranges along. The
+// highlights are intended markup from then on.
+func protectRanges(text string, intended []int, protected []model.Range, fixes []model.Range) (string, []int, []model.Range) {
+	var sb strings.Builder
+	shift := func(offset int) int {
+		moved := offset
+		for _, fix := range fixes {
+			if fix.Start >= offset {
+				break
+			}
+			moved += len(inlineMarkup(model.InlineEditNode{EditType: model.EditTypeHighlight, Content: text[fix.Start:fix.End]})) - (fix.End - fix.Start)
+		}
+		return moved
+	}
+	last := 0
+	var moved []int
+	for _, fix := range fixes {
+		sb.WriteString(text[last:fix.Start])
+		moved = append(moved, sb.Len())
+		sb.WriteString(inlineMarkup(model.InlineEditNode{EditType: model.EditTypeHighlight, Content: text[fix.Start:fix.End]}))
+		last = fix.End
+	}
+	sb.WriteString(text[last:])
+	for _, offset := range intended {
+		moved = append(moved, shift(offset))
+	}
+	ranges := make([]model.Range, len(protected))
+	for i, r := range protected {
+		ranges[i] = model.Range{Start: shift(r.Start), End: shift(r.End)}
+	}
+	return sb.String(), moved, ranges
+}
@@ -0,0 +1,265 @@
+// parser/markdown.go
+// package parser provides functionality to parse EditML text into an AST.
+package parser
+
+import (
+	"strings"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// Kinds of the Markdown regions that are kept out of EditML parsing.
+const (
+	MarkdownFencedCode   = "fenced code block"
+	MarkdownIndentedCode = "indented code block"
+	MarkdownCodeSpan     = "code span"
+	MarkdownComment      = "HTML comment"
+)
+
+// MarkdownRegion is a range of a Markdown text that holds code or a comment
+// rather than prose.
+type MarkdownRegion struct {
+	Kind  string // One of the Markdown* kinds.
+	Range model.Range
+}
+
+// MarkdownRegions returns the code and comment regions of a Markdown text,
+// in order and disjoint, following CommonMark:
+//
+//   - fenced code blocks, from the opening fence of three or more backticks
+//     or tildes to the closing one, or to the end of the text if unclosed;
+//   - indented code blocks, lines indented by four or more columns after a
+//     blank line, outside lists;
+//   - code spans, between backtick runs of the same length in a paragraph;
+//   - HTML comments, from "<!--" to "-->".
+//
+// Markup is not recognized inside a region, so a backtick in a comment or a
+// "<!--" in a code span is text.
+func MarkdownRegions(input string) []MarkdownRegion {
+	var regions []MarkdownRegion
+	var fenceChar byte
+	fenceLen, fenceStart := 0, 0
+	codeStart, codeEnd := -1, -1 // The pending indented code block.
+	prevBlank, inList := true, false
+	flushCode := func() {
+		if codeStart >= 0 {
+			regions = append(regions, MarkdownRegion{MarkdownIndentedCode, model.Range{Start: codeStart, End: codeEnd}})
+			codeStart = -1
+		}
+	}
+
+	for lineStart := 0; lineStart < len(input); {
+		lineEnd := strings.IndexByte(input[lineStart:], '\n')
+		next := len(input)
+		if lineEnd < 0 {
+			lineEnd = len(input)
+		} else {
+			lineEnd += lineStart
+			next = lineEnd + 1
+		}
+		line := strings.TrimSuffix(input[lineStart:lineEnd], "\r")
+		blank := strings.TrimSpace(line) == ""
+
+		switch {
+		case fenceLen > 0:
+			if closesFence(line, fenceChar, fenceLen) {
+				regions = append(regions, MarkdownRegion{MarkdownFencedCode, model.Range{Start: fenceStart, End: lineEnd}})
+				fenceLen = 0
+			}
+		case openingFence(line) != "":
+			flushCode()
+			fence := openingFence(line)
+			fenceChar, fenceLen, fenceStart = fence[0], len(fence), lineStart
+			prevBlank, inList = false, false
+		case blank:
+			// Blank lines inside an indented code block continue it.
+			prevBlank = true
+		case indentWidth(line) >= 4 && (codeStart >= 0 || prevBlank) && !inList:
+			if codeStart < 0 {
+				codeStart = lineStart
+			}
+			codeEnd = lineEnd
+			prevBlank = false
+		default:
+			flushCode()
+			if isListItem(line) {
+				inList = true
+			} else if prevBlank && indentWidth(line) == 0 {
+				inList = false
+			}
+			prevBlank = false
+		}
+		lineStart = next
+	}
+	if fenceLen > 0 {
+		regions = append(regions, MarkdownRegion{MarkdownFencedCode, model.Range{Start: fenceStart, End: len(input)}})
+	}
+	flushCode()
+
+	// Code spans and comments are searched for between the code blocks.
+	var all []MarkdownRegion
+	start := 0
+	for _, block := range regions {
+		all = append(all, inlineRegions(input, start, block.Range.Start)...)
+		all = append(all, block)
+		start = block.Range.End
+	}
+	return append(all, inlineRegions(input, start, len(input))...)
+}
+
+// indentWidth returns the width of the indentation of line in columns, with
+// tab stops every four columns.
+func indentWidth(line string) int {
+	width := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// openingFence returns the fence that opens a fenced code block on line,
+// such as "```" or "~~~~", or "" if line does not open one.
+func openingFence(line string) string {
+	if indentWidth(line) > 3 {
+		return ""
+	}
+	line = strings.TrimLeft(line, " ")
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	if n < 3 || (line[0] == '`' && strings.Contains(line[n:], "`")) {
+		return "" // Backtick fences cannot have backticks in their info string.
+	}
+	return line[:n]
+}
+
+// closesFence reports whether line closes a fenced code block opened by n
+// or more fence characters c.
+func closesFence(line string, c byte, n int) bool {
+	if indentWidth(line) > 3 {
+		return false
+	}
+	line = strings.TrimLeft(line, " ")
+	rest := strings.TrimLeft(line, string(c))
+	return len(line)-len(rest) >= n && strings.TrimSpace(rest) == ""
+}
+
+// isListItem reports whether line starts a list item: a bullet ("-", "*" or
+// "+") or an ordered marker ("1." or "1)") followed by a space or tab.
+func isListItem(line string) bool {
+	line = strings.TrimLeft(line, " ")
+	i := 0
+	for i < len(line) && i < 9 && line[i] >= '0' && line[i] <= '9' {
+		i++
+	}
+	switch {
+	case i == 0 && line != "" && strings.ContainsRune("-*+", rune(line[0])):
+		i = 1
+	case i > 0 && i < len(line) && (line[i] == '.' || line[i] == ')'):
+		i++
+	default:
+		return false
+	}
+	return i < len(line) && (line[i] == ' ' || line[i] == '\t')
+}
+
+// inlineRegions returns the code spans and HTML comments in input[start:end].
+// Backticks inside an inline edit, as in "{+`+}", neither open nor close a
+// code span: the content of an edit is never parsed further, so a code span
+// gains nothing there and would swallow the edit's closing delimiter.
+func inlineRegions(input string, start, end int) []MarkdownRegion {
+	var regions []MarkdownRegion
+	edits := inlineEditRanges(input, start, end)
+	for i := start; i < end; {
+		switch {
+		case input[i] == '\\':
+			i += 2 // A backslash escapes the next character, backticks included.
+		case strings.HasPrefix(input[i:end], "<!--"):
+			close := strings.Index(input[i+4:end], "-->")
+			if close < 0 {
+				i += 4
+				continue
+			}
+			stop := i + 4 + close + 3
+			regions = append(regions, MarkdownRegion{MarkdownComment, model.Range{Start: i, End: stop}})
+			i = stop
+		case input[i] == '`':
+			n := backtickRun(input, i, end)
+			if inRanges(i, edits) {
+				i += n
+				continue
+			}
+			close := closingBackticks(input, i+n, end, n, edits)
+			if close < 0 {
+				i += n // An unmatched run is literal backticks.
+				continue
+			}
+			regions = append(regions, MarkdownRegion{MarkdownCodeSpan, model.Range{Start: i, End: close + n}})
+			i = close + n
+		default:
+			i++
+		}
+	}
+	return regions
+}
+
+// inlineEditRanges returns the ranges of input[start:end] held by inline
+// edits when it is read as EditML, in order.
+func inlineEditRanges(input string, start, end int) []model.Range {
+	nodes, spans, err := ParseEditMLToNodesWithSpans(input[start:end])
+	if err != nil {
+		return nil
+	}
+	var edits []model.Range
+	for i, node := range nodes {
+		if _, ok := node.(model.InlineEditNode); ok {
+			edits = append(edits, model.Range{Start: start + spans[i].Start.Offset, End: start + spans[i].End.Offset})
+		}
+	}
+	return edits
+}
+
+// backtickRun returns the length of the run of backticks at input[i:end].
+func backtickRun(input string, i, end int) int {
+	n := 0
+	for i+n < end && input[i+n] == '`' {
+		n++
+	}
+	return n
+}
+
+// closingBackticks returns the offset of the first run of exactly n
+// backticks in input[from:end] outside edits before the end of the
+// paragraph, or -1.
+func closingBackticks(input string, from, end, n int, edits []model.Range) int {
+	for j := from; j < end; {
+		switch input[j] {
+		case '`':
+			m := backtickRun(input, j, end)
+			if m == n && !inRanges(j, edits) {
+				return j
+			}
+			j += m
+		case '\n':
+			lineEnd := strings.IndexByte(input[j+1:end], '\n')
+			if lineEnd < 0 {
+				lineEnd = end - j - 1
+			}
+			if strings.TrimSpace(input[j+1:j+1+lineEnd]) == "" {
+				return -1 // A blank line ends the paragraph.
+			}
+			j++
+		default:
+			j++
+		}
+	}
+	return -1
+}
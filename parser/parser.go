@@ -5,7 +5,6 @@ package parser
 import (
 	"regexp"
 	"sort"
-	"strings"
 
 	"github.com/verkaro/editml-go/model"
 )
@@ -52,41 +51,48 @@ var (
 	copyTargetRegex = regexp.MustCompile(`\{(copy|cp|c):` + structuralTagPattern + `\}`)
 )
 
-// unescapeInlineContent processes escape sequences for inline edit content.
+// unescapeInlineContentWithMap processes escape sequences for inline edit content.
 // Handles general escapes \\, \{, \} and context-specific operator escapes.
 // (Spec 3.1, 3.3.1)
-func unescapeInlineContent(content string, editType model.EditType) string {
+// It also returns the input offset of every unescaped byte, for content that
+// starts at base.
+func unescapeInlineContentWithMap(content string, editType model.EditType, base int) (string, []int) {
 	// General escapes first
-	content = strings.ReplaceAll(content, "\\\\", "\\") // Backslash
-	content = strings.ReplaceAll(content, "\\{", "{")   // Open curly
-	content = strings.ReplaceAll(content, "\\}", "}")   // Close curly
+	reps := []replacement{
+		{`\\`, `\`}, // Backslash
+		{`\{`, `{`}, // Open curly
+		{`\}`, `}`}, // Close curly
+	}
 
 	// Context-specific closing operator escapes
 	switch editType {
 	case model.EditTypeAddition:
-		content = strings.ReplaceAll(content, "\\+", "+")
+		reps = append(reps, replacement{`\+`, `+`})
 	case model.EditTypeDeletion:
-		content = strings.ReplaceAll(content, "\\-", "-")
+		reps = append(reps, replacement{`\-`, `-`})
 	case model.EditTypeComment:
-		content = strings.ReplaceAll(content, "\\<", "<")
+		reps = append(reps, replacement{`\<`, `<`})
 	case model.EditTypeHighlight:
-		content = strings.ReplaceAll(content, "\\=", "=")
+		reps = append(reps, replacement{`\=`, `=`})
 	}
 	// Note: Spec 3.1 lists more characters that must be escaped in certain contexts
 	// (e.g., \~, \%, \[, \]). A more comprehensive unescaper might be needed
 	// in future iterations if these are critical within inline content.
-	return content
+	return unescapeWithMap(content, base, reps)
 }
 
-// unescapeStructuralBlockContent handles general backslash escapes and then \~ -> ~
-// for structural block content. (Spec 3.1, 3.4.1)
-func unescapeStructuralBlockContent(content string) string {
-	content = strings.ReplaceAll(content, "\\\\", "\\") // General unescape for literal backslashes
-	content = strings.ReplaceAll(content, "\\~", "~")   // Specific unescape for literal tildes
+// unescapeStructuralBlockContentWithMap handles general backslash escapes and
+// then \~ -> ~ for structural block content. (Spec 3.1, 3.4.1)
+// It also returns the input offset of every unescaped byte, for content that
+// starts at base.
+func unescapeStructuralBlockContentWithMap(content string, base int) (string, []int) {
+	return unescapeWithMap(content, base, []replacement{
+		{`\\`, `\`}, // General unescape for literal backslashes
+		{`\~`, `~`}, // Specific unescape for literal tildes
+	})
 	// Note: Spec 3.1 lists other characters. If they can appear escaped in structural content
 	// and need unescaping, they might need handling here too (e.g. \{, \}).
 	// However, spec 3.4.1 only explicitly mentions \~ for block content.
-	return content
 }
 
 // genericMatch is a helper struct for collecting all types of identified EditML constructs.
@@ -94,6 +100,9 @@ type genericMatch struct {
 	startIndex int
 	endIndex   int
 	node       model.Node
+	// contentOffsets maps each byte of the node's unescaped content to its
+	// offset in the input (see model.Span.Content). Nil for target nodes.
+	contentOffsets []int
 	// Future: could add 'priority' or 'level' for more complex overlap resolution
 }
 
@@ -102,131 +111,165 @@ type genericMatch struct {
 // and any critical errors encountered during this phase.
 // This function is unexported and will be called by the public editml.Parse().
 func ParseEditMLToNodes(input string) ([]model.Node, error) { // Changed from ParseToNodes to parseEditMLToNodes
+	nodes, _, err := ParseEditMLToNodesWithSpans(input)
+	return nodes, err
+}
+
+// ParseEditMLToNodesWithSpans is ParseEditMLToNodes that also returns the span
+// of every node in input. spans[i] belongs to nodes[i]; positions refer to
+// input itself (see RemapSpans to refer them to a preprocessed original).
+func ParseEditMLToNodesWithSpans(input string) ([]model.Node, []model.Span, error) {
+	return ParseProtectedWithSpans(input, nil)
+}
+
+// ParseProtectedWithSpans is ParseEditMLToNodesWithSpans for EditML embedded
+// in a host language: protected lists ranges of input, sorted and disjoint,
+// that hold host-language syntax. Markup is never recognized inside them, so
+// no edit starts, ends or is delimited there, but an edit may enclose a whole
+// protected range, whose text then becomes part of the edit's content
+// verbatim, escapes included. Protected ranges outside edits are emitted as
+// TextNodes of their own.
+func ParseProtectedWithSpans(input string, protected []model.Range) ([]model.Node, []model.Span, error) {
+	// The expressions run on text, in which the protected bytes are masked;
+	// node text and content are read back from input.
+	text := maskRanges(input, protected)
 	var allMatches []genericMatch
 	var issues []error // For collecting critical parsing errors
 
 	// --- 1. Find Inline Addition Matches ---
-	addIndices := addRegex.FindAllStringSubmatchIndex(input, -1)
+	addIndices := addRegex.FindAllStringSubmatchIndex(text, -1)
 	for _, m := range addIndices {
-		content := input[m[2]:m[3]] // Group 1 (index 2,3) is the content
+		content := text[m[2]:m[3]] // Group 1 (index 2,3) is the content
 		editorID := ""
 		if m[4] != -1 && m[5] != -1 { // Group 2 (index 4,5) is the editorID
-			editorID = input[m[4]:m[5]]
+			editorID = text[m[4]:m[5]]
 		}
+		unescaped, contentOffsets := unescapeInlineContentWithMap(content, model.EditTypeAddition, m[2])
 		allMatches = append(allMatches, genericMatch{
 			startIndex: m[0], endIndex: m[1],
 			node: model.InlineEditNode{
 				EditType: model.EditTypeAddition,
-				Content:  unescapeInlineContent(content, model.EditTypeAddition),
+				Content:  unescaped,
 				EditorID: editorID,
 			},
+			contentOffsets: contentOffsets,
 		})
 	}
 
 	// --- 2. Find Inline Deletion Matches ---
-	delIndices := delRegex.FindAllStringSubmatchIndex(input, -1)
+	delIndices := delRegex.FindAllStringSubmatchIndex(text, -1)
 	for _, m := range delIndices {
-		content := input[m[2]:m[3]]
+		content := text[m[2]:m[3]]
 		editorID := ""
 		if m[4] != -1 && m[5] != -1 {
-			editorID = input[m[4]:m[5]]
+			editorID = text[m[4]:m[5]]
 		}
+		unescaped, contentOffsets := unescapeInlineContentWithMap(content, model.EditTypeDeletion, m[2])
 		allMatches = append(allMatches, genericMatch{
 			startIndex: m[0], endIndex: m[1],
 			node: model.InlineEditNode{
 				EditType: model.EditTypeDeletion,
-				Content:  unescapeInlineContent(content, model.EditTypeDeletion),
+				Content:  unescaped,
 				EditorID: editorID,
 			},
+			contentOffsets: contentOffsets,
 		})
 	}
 
 	// --- 3. Find Inline Comment Matches ---
-	commentIndices := commentRegex.FindAllStringSubmatchIndex(input, -1)
+	commentIndices := commentRegex.FindAllStringSubmatchIndex(text, -1)
 	for _, m := range commentIndices {
-		content := input[m[2]:m[3]]
+		content := text[m[2]:m[3]]
 		editorID := ""
 		if m[4] != -1 && m[5] != -1 {
-			editorID = input[m[4]:m[5]]
+			editorID = text[m[4]:m[5]]
 		}
+		unescaped, contentOffsets := unescapeInlineContentWithMap(content, model.EditTypeComment, m[2])
 		allMatches = append(allMatches, genericMatch{
 			startIndex: m[0], endIndex: m[1],
 			node: model.InlineEditNode{
 				EditType: model.EditTypeComment,
-				Content:  unescapeInlineContent(content, model.EditTypeComment),
+				Content:  unescaped,
 				EditorID: editorID,
 			},
+			contentOffsets: contentOffsets,
 		})
 	}
 
 	// --- 4. Find Inline Highlight Matches ---
-	highlightIndices := highlightRegex.FindAllStringSubmatchIndex(input, -1)
+	highlightIndices := highlightRegex.FindAllStringSubmatchIndex(text, -1)
 	for _, m := range highlightIndices {
-		content := input[m[2]:m[3]]
+		content := text[m[2]:m[3]]
 		editorID := ""
 		if m[4] != -1 && m[5] != -1 {
-			editorID = input[m[4]:m[5]]
+			editorID = text[m[4]:m[5]]
 		}
+		unescaped, contentOffsets := unescapeInlineContentWithMap(content, model.EditTypeHighlight, m[2])
 		allMatches = append(allMatches, genericMatch{
 			startIndex: m[0], endIndex: m[1],
 			node: model.InlineEditNode{
 				EditType: model.EditTypeHighlight,
-				Content:  unescapeInlineContent(content, model.EditTypeHighlight),
+				Content:  unescaped,
 				EditorID: editorID,
 			},
+			contentOffsets: contentOffsets,
 		})
 	}
 
 	// --- 5. Find Move Source Matches ---
-	moveSourceMatches := moveSourceRegex.FindAllStringSubmatchIndex(input, -1)
+	moveSourceMatches := moveSourceRegex.FindAllStringSubmatchIndex(text, -1)
 	for _, m := range moveSourceMatches {
 		// m[0]:m[1] is full match; m[2]:m[3] is op keyword; m[4]:m[5] is BlockContent; m[6]:m[7] is TAG
-		rawBlockContent := input[m[4]:m[5]]
+		rawBlockContent := text[m[4]:m[5]]
+		blockContent, contentOffsets := unescapeStructuralBlockContentWithMap(rawBlockContent, m[4])
 		allMatches = append(allMatches, genericMatch{
 			startIndex: m[0], endIndex: m[1],
 			node: model.StructuralSourceNode{
 				Operation:    model.OperationMove, // Normalized
-				BlockContent: unescapeStructuralBlockContent(rawBlockContent),
-				Tag:          input[m[6]:m[7]],
+				BlockContent: blockContent,
+				Tag:          text[m[6]:m[7]],
 			},
+			contentOffsets: contentOffsets,
 		})
 	}
 
 	// --- 6. Find Move Target Matches ---
-	moveTargetMatches := moveTargetRegex.FindAllStringSubmatchIndex(input, -1)
+	moveTargetMatches := moveTargetRegex.FindAllStringSubmatchIndex(text, -1)
 	for _, m := range moveTargetMatches {
 		// m[0]:m[1] is full match; m[2]:m[3] is op keyword; m[4]:m[5] is TAG
 		allMatches = append(allMatches, genericMatch{
 			startIndex: m[0], endIndex: m[1],
 			node: model.StructuralTargetNode{
 				Operation: model.OperationMove, // Normalized
-				Tag:       input[m[4]:m[5]],
+				Tag:       text[m[4]:m[5]],
 			},
 		})
 	}
 
 	// --- 7. Find Copy Source Matches ---
-	copySourceMatches := copySourceRegex.FindAllStringSubmatchIndex(input, -1)
+	copySourceMatches := copySourceRegex.FindAllStringSubmatchIndex(text, -1)
 	for _, m := range copySourceMatches {
-		rawBlockContent := input[m[4]:m[5]]
+		rawBlockContent := text[m[4]:m[5]]
+		blockContent, contentOffsets := unescapeStructuralBlockContentWithMap(rawBlockContent, m[4])
 		allMatches = append(allMatches, genericMatch{
 			startIndex: m[0], endIndex: m[1],
 			node: model.StructuralSourceNode{
 				Operation:    model.OperationCopy, // Normalized
-				BlockContent: unescapeStructuralBlockContent(rawBlockContent),
-				Tag:          input[m[6]:m[7]],
+				BlockContent: blockContent,
+				Tag:          text[m[6]:m[7]],
 			},
+			contentOffsets: contentOffsets,
 		})
 	}
 
 	// --- 8. Find Copy Target Matches ---
-	copyTargetMatches := copyTargetRegex.FindAllStringSubmatchIndex(input, -1)
+	copyTargetMatches := copyTargetRegex.FindAllStringSubmatchIndex(text, -1)
 	for _, m := range copyTargetMatches {
 		allMatches = append(allMatches, genericMatch{
 			startIndex: m[0], endIndex: m[1],
 			node: model.StructuralTargetNode{
 				Operation: model.OperationCopy, // Normalized
-				Tag:       input[m[4]:m[5]],
+				Tag:       text[m[4]:m[5]],
 			},
 		})
 	}
@@ -247,6 +290,26 @@ func ParseEditMLToNodes(input string) ([]model.Node, error) { // Changed from Pa
 
 	// --- 10. Iterate through sorted matches and interleave TextNodes ---
 	var nodes []model.Node
+	var spans []model.Span
+	lines := NewLineIndex(input)
+	addNode := func(node model.Node, start, end int, contentOffsets []int) {
+		if len(protected) > 0 {
+			node = restoreContent(node, input, contentOffsets)
+		}
+		nodes = append(nodes, node)
+		spans = append(spans, model.Span{
+			Start:   lines.Position(start),
+			End:     lines.Position(end),
+			Content: contentOffsets,
+		})
+	}
+	// addText adds the text between start and end, as separate TextNodes
+	// for the protected ranges in it.
+	addText := func(start, end int) {
+		for _, r := range splitRanges(start, end, protected) {
+			addNode(model.TextNode{Text: input[r.Start:r.End]}, r.Start, r.End, identityOffsets(r.Start, r.End-r.Start))
+		}
+	}
 	lastIndex := 0
 	for _, match := range allMatches {
 		// Basic overlap detection: if a match starts before the last one ended,
@@ -263,29 +326,29 @@ func ParseEditMLToNodes(input string) ([]model.Node, error) { // Changed from Pa
 
 		// Add preceding text as a TextNode
 		if match.startIndex > lastIndex {
-			nodes = append(nodes, model.TextNode{Text: input[lastIndex:match.startIndex]})
+			addText(lastIndex, match.startIndex)
 		}
 		// Add the matched EditML node
-		nodes = append(nodes, match.node)
+		addNode(match.node, match.startIndex, match.endIndex, match.contentOffsets)
 		lastIndex = match.endIndex
 	}
 
 	// Add any remaining text after the last match
 	if lastIndex < len(input) {
-		nodes = append(nodes, model.TextNode{Text: input[lastIndex:]})
+		addText(lastIndex, len(input))
 	}
 
 	// Handle empty input: if input is empty and no nodes were produced, return empty slice, no error.
 	if input == "" && len(nodes) == 0 {
-		return []model.Node{}, nil
+		return []model.Node{}, []model.Span{}, nil
 	}
 
 	// If any critical errors were collected, return them. For MVP, this 'issues' list is basic.
 	if len(issues) > 0 {
 		// For now, we'll just return the first error if multiple occurred.
 		// A more robust system would return all 'issues'.
-		return nodes, issues[0]
+		return nodes, spans, issues[0]
 	}
 
-	return nodes, nil
+	return nodes, spans, nil
 }
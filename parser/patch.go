@@ -0,0 +1,248 @@
+// parser/patch.go
+// package parser provides functionality to parse EditML text into an AST.
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Codes of the warnings returned by ParsePatch.
+const (
+	// WarningMalformedPatch: a unified hunk that ends before the line counts
+	// of its header are reached. The hunk is kept as far as it could be read.
+	WarningMalformedPatch = "malformed-patch"
+	// WarningExtraFile: the patch changes more than one file. Only the hunks
+	// of the first file are returned.
+	WarningExtraFile = "extra-file"
+)
+
+// Kinds of a PatchSegment.
+const (
+	PatchContext = ' '
+	PatchRemoved = '-'
+	PatchAdded   = '+'
+)
+
+// PatchSegment is a piece of a hunk: context, removed or added text. The
+// segments of unified diffs are whole lines, with their newlines; those of
+// word diffs are parts of lines.
+type PatchSegment struct {
+	Kind byte // PatchContext, PatchRemoved or PatchAdded.
+	Text string
+}
+
+// PatchHunk is one hunk of a patch.
+type PatchHunk struct {
+	Header   string // The "@@ -a,b +c,d @@" line, without trailing text.
+	Line     int    // Line of the header in the patch (1-based).
+	OldStart int    // First line of the hunk in the original text (1-based).
+	OldLines int    // Number of lines of the hunk in the original text.
+	NewStart int    // First line of the hunk in the changed text (1-based).
+	NewLines int    // Number of lines of the hunk in the changed text.
+	WordDiff bool   // Whether the hunk is in word-diff format.
+	Segments []PatchSegment
+}
+
+// Old returns the text the hunk applies to: its context and removed text.
+func (h PatchHunk) Old() string { return h.join(PatchAdded) }
+
+// New returns the text the hunk produces: its context and added text.
+func (h PatchHunk) New() string { return h.join(PatchRemoved) }
+
+// join concatenates the segments not of kind skip.
+func (h PatchHunk) join(skip byte) string {
+	var sb strings.Builder
+	for _, s := range h.Segments {
+		if s.Kind != skip {
+			sb.WriteString(s.Text)
+		}
+	}
+	return sb.String()
+}
+
+// PatchWarning describes a part of a patch that could not be read.
+type PatchWarning struct {
+	Code    string
+	Line    int // Line in the patch (1-based).
+	Message string
+}
+
+var (
+	// hunkHeaderRegex matches a hunk header: "@@ -a[,b] +c[,d] @@".
+	hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+	// wordDiffRegex matches the removed ("[-text-]") and added ("{+text+}")
+	// words of a word diff.
+	wordDiffRegex = regexp.MustCompile(`\[-(.*?)-\]|\{\+(.*?)\+\}`)
+)
+
+// ParsePatch reads the hunks of a patch in unified format (diff -u, git diff)
+// or in git's plain word-diff format (git diff --word-diff=plain). File
+// headers and other lines outside hunks are skipped.
+//
+// Hunks are recognised as word diffs when one of their lines does not start
+// with a space, "+", "-" or "\". In a word diff, "[-text-]" is removed and
+// "{+text+}" added text; a line holding nothing but a removal or an addition
+// is taken to be removed or added with its newline.
+func ParsePatch(patch string) ([]PatchHunk, []PatchWarning) {
+	var hunks []PatchHunk
+	var warnings []PatchWarning
+	lines := strings.SplitAfter(patch, "\n")
+	files := 0
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\n")
+		if strings.HasPrefix(line, "+++ ") {
+			files++
+			if files == 2 {
+				warnings = append(warnings, PatchWarning{WarningExtraFile, i + 1, fmt.Sprintf("patch changes more than one file; %q and later files ignored", strings.TrimPrefix(line, "+++ "))})
+			}
+			continue
+		}
+		m := hunkHeaderRegex.FindStringSubmatch(line)
+		if m == nil || files > 1 {
+			continue
+		}
+		h := PatchHunk{Header: m[0], Line: i + 1}
+		h.OldStart, _ = strconv.Atoi(m[1])
+		h.NewStart, _ = strconv.Atoi(m[3])
+		h.OldLines, h.NewLines = hunkCount(m[2]), hunkCount(m[4])
+
+		// The hunk body ends at the next header or file, or at the end.
+		end := i + 1
+		for end < len(lines) && !isPatchBoundary(lines, end) {
+			end++
+		}
+		body := lines[i+1 : end]
+		if len(body) > 0 && body[len(body)-1] == "" {
+			body = body[:len(body)-1]
+		}
+		if h.WordDiff = isWordDiff(body); h.WordDiff {
+			h.Segments = wordDiffSegments(body)
+		} else {
+			var w *PatchWarning
+			h.Segments, w = unifiedSegments(body, i+1, h.OldLines, h.NewLines)
+			if w != nil {
+				warnings = append(warnings, *w)
+			}
+		}
+		hunks = append(hunks, h)
+		i = end - 1
+	}
+	return hunks, warnings
+}
+
+// hunkCount returns a line count of a hunk header, which is 1 if omitted.
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// isPatchBoundary reports whether line i of a patch ends a hunk: it starts
+// the next hunk or file. A file header is a "---" line followed by a "+++"
+// line, as a removed line may start with "--".
+func isPatchBoundary(lines []string, i int) bool {
+	line := lines[i]
+	return strings.HasPrefix(line, "@@ ") || strings.HasPrefix(line, "diff ") ||
+		strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+}
+
+// isWordDiff reports whether the lines of a hunk are in word-diff format:
+// one of them is neither empty nor starts with a space, "+", "-" or "\".
+func isWordDiff(body []string) bool {
+	for _, line := range body {
+		if line != "" && line != "\n" && !strings.ContainsRune(` +-\`, rune(line[0])) {
+			return true
+		}
+	}
+	return false
+}
+
+// unifiedSegments returns the segments of a hunk in unified format, whose
+// header is at line header of the patch. The hunk ends when the line counts
+// of the header are reached; a warning is returned if body ends first.
+func unifiedSegments(body []string, header, oldLines, newLines int) ([]PatchSegment, *PatchWarning) {
+	var segments []PatchSegment
+	var oldCount, newCount int
+	for _, line := range body {
+		if oldCount >= oldLines && newCount >= newLines && line[0] != '\\' {
+			break
+		}
+		if line[0] == '\\' {
+			// "\ No newline at end of file" applies to the previous line.
+			if n := len(segments); n > 0 {
+				segments[n-1].Text = strings.TrimSuffix(segments[n-1].Text, "\n")
+			}
+			continue
+		}
+		// Some tools strip the space of empty context lines.
+		kind, text := line[0], line[1:]
+		if kind == '\n' {
+			kind, text = PatchContext, line
+		}
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		switch kind {
+		case ' ':
+			oldCount++
+			newCount++
+		case '-':
+			oldCount++
+		case '+':
+			newCount++
+		}
+		segments = append(segments, PatchSegment{Kind: kind, Text: text})
+	}
+	if oldCount < oldLines || newCount < newLines {
+		return segments, &PatchWarning{WarningMalformedPatch, header,
+			fmt.Sprintf("hunk has %d original and %d changed lines, header says %d and %d", oldCount, newCount, oldLines, newLines)}
+	}
+	return segments, nil
+}
+
+// wordDiffSegments returns the segments of a hunk in word-diff format.
+func wordDiffSegments(body []string) []PatchSegment {
+	var segments []PatchSegment
+	add := func(kind byte, text string) {
+		if text == "" {
+			return
+		}
+		if n := len(segments); n > 0 && segments[n-1].Kind == kind {
+			segments[n-1].Text += text
+			return
+		}
+		segments = append(segments, PatchSegment{Kind: kind, Text: text})
+	}
+	for _, line := range body {
+		content := strings.TrimSuffix(line, "\n")
+		newline := line[len(content):]
+		matches := wordDiffRegex.FindAllStringSubmatchIndex(content, -1)
+		// A line that is one removal or addition goes with its newline.
+		if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(content) {
+			m := matches[0]
+			if m[2] >= 0 {
+				add(PatchRemoved, content[m[2]:m[3]]+newline)
+			} else {
+				add(PatchAdded, content[m[4]:m[5]]+newline)
+			}
+			continue
+		}
+		pos := 0
+		for _, m := range matches {
+			add(PatchContext, content[pos:m[0]])
+			if m[2] >= 0 {
+				add(PatchRemoved, content[m[2]:m[3]])
+			} else {
+				add(PatchAdded, content[m[4]:m[5]])
+			}
+			pos = m[1]
+		}
+		add(PatchContext, content[pos:]+newline)
+	}
+	return segments
+}
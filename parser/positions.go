@@ -0,0 +1,138 @@
+// parser/positions.go
+// package parser provides functionality to parse EditML text into an AST.
+package parser
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// LineIndex converts byte offsets in a text into line/column positions.
+type LineIndex struct {
+	text       string
+	lineStarts []int // Byte offset of the first byte of each line.
+}
+
+// NewLineIndex builds a LineIndex for text.
+func NewLineIndex(text string) *LineIndex {
+	li := &LineIndex{text: text, lineStarts: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			li.lineStarts = append(li.lineStarts, i+1)
+		}
+	}
+	return li
+}
+
+// Position returns the position of the byte at offset. Offsets outside the
+// text are clamped to its bounds.
+func (li *LineIndex) Position(offset int) model.Position {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(li.text) {
+		offset = len(li.text)
+	}
+	// The line is the last line starting at or before offset.
+	line := sort.Search(len(li.lineStarts), func(i int) bool { return li.lineStarts[i] > offset }) - 1
+	column := utf8.RuneCountInString(li.text[li.lineStarts[line]:offset]) + 1
+	return model.Position{Offset: offset, Line: line + 1, Column: column}
+}
+
+// LineOffset returns the byte offset at which the 1-based line starts. Lines
+// before the first start at 0, and lines after the last at the end of the
+// text.
+func (li *LineIndex) LineOffset(line int) int {
+	switch {
+	case line <= 1:
+		return 0
+	case line > len(li.lineStarts):
+		return len(li.text)
+	}
+	return li.lineStarts[line-1]
+}
+
+// replacement is a single escape sequence rewrite used by unescapeWithMap.
+type replacement struct {
+	old string
+	new string
+}
+
+// unescapeWithMap applies each replacement to raw in turn, like a sequence of
+// strings.ReplaceAll calls, while tracking where every output byte came from.
+// The returned map has one entry per output byte plus a trailing entry for the
+// end of the text; each entry is base plus the byte's offset in raw. The
+// replacement text maps to the trailing bytes of the sequence it replaces, so
+// an unescaped "{" maps to the "{" of "\{".
+func unescapeWithMap(raw string, base int, reps []replacement) (string, []int) {
+	text := raw
+	offsets := make([]int, len(raw)+1)
+	for i := range offsets {
+		offsets[i] = base + i
+	}
+
+	for _, rep := range reps {
+		if !strings.Contains(text, rep.old) {
+			continue
+		}
+		var sb strings.Builder
+		nextOffsets := make([]int, 0, len(offsets))
+		for i := 0; i < len(text); {
+			if strings.HasPrefix(text[i:], rep.old) {
+				sb.WriteString(rep.new)
+				for j := range rep.new {
+					nextOffsets = append(nextOffsets, offsets[i+len(rep.old)-len(rep.new)+j])
+				}
+				i += len(rep.old)
+				continue
+			}
+			sb.WriteByte(text[i])
+			nextOffsets = append(nextOffsets, offsets[i])
+			i++
+		}
+		nextOffsets = append(nextOffsets, offsets[len(text)])
+		text = sb.String()
+		offsets = nextOffsets
+	}
+	return text, offsets
+}
+
+// identityOffsets returns a content map for text copied verbatim from the
+// input starting at base.
+func identityOffsets(base, length int) []int {
+	offsets := make([]int, length+1)
+	for i := range offsets {
+		offsets[i] = base + i
+	}
+	return offsets
+}
+
+// RemapSpans rewrites spans produced from derived text so that they refer to
+// the original input. offsets maps each byte of the derived text (plus its end)
+// to an offset in original: for preprocessed input this is the map returned by
+// SkipDebugCommentsWithMap; for nodes parsed from a structural source's block
+// content it is the source's Span.Content. The spans are modified in place and
+// returned.
+func RemapSpans(spans []model.Span, offsets []int, original string) []model.Span {
+	li := NewLineIndex(original)
+	remap := func(offset int) int {
+		if offset < 0 {
+			return 0
+		}
+		if offset >= len(offsets) {
+			return len(original)
+		}
+		return offsets[offset]
+	}
+	for i := range spans {
+		spans[i].Start = li.Position(remap(spans[i].Start.Offset))
+		spans[i].End = li.Position(remap(spans[i].End.Offset))
+		for j, offset := range spans[i].Content {
+			spans[i].Content[j] = remap(offset)
+		}
+	}
+	return spans
+}
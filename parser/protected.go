@@ -0,0 +1,158 @@
+// parser/protected.go
+// package parser provides functionality to parse EditML text into an AST.
+package parser
+
+import (
+	"strings"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// maskByte replaces the bytes of protected ranges before matching. It is
+// neither a markup character nor a letter or digit, so masked text can
+// neither delimit an edit nor extend an editor ID or tag.
+const maskByte = 0
+
+// maskRanges returns input with every byte in protected replaced by maskByte,
+// except line breaks, so that offsets and line numbers are unchanged.
+func maskRanges(input string, protected []model.Range) string {
+	if len(protected) == 0 {
+		return input
+	}
+	b := []byte(input)
+	for _, r := range protected {
+		for i := clampOffset(r.Start, len(b)); i < clampOffset(r.End, len(b)); i++ {
+			if b[i] != '\n' {
+				b[i] = maskByte
+			}
+		}
+	}
+	return string(b)
+}
+
+// clampOffset clamps offset to [0, n].
+func clampOffset(offset, n int) int {
+	if offset < 0 {
+		return 0
+	}
+	if offset > n {
+		return n
+	}
+	return offset
+}
+
+// restoreContent returns node with its content read back from input through
+// its content map, so that masked protected bytes get their original value.
+func restoreContent(node model.Node, input string, contentOffsets []int) model.Node {
+	read := func(n int) string {
+		var sb strings.Builder
+		for _, offset := range contentOffsets[:n] {
+			sb.WriteByte(input[offset])
+		}
+		return sb.String()
+	}
+	switch n := node.(type) {
+	case model.InlineEditNode:
+		n.Content = read(len(n.Content))
+		return n
+	case model.StructuralSourceNode:
+		n.BlockContent = read(len(n.BlockContent))
+		return n
+	}
+	return node
+}
+
+// splitRanges splits [start, end) at the bounds of the protected ranges, so
+// that each part is either wholly protected or not protected at all.
+func splitRanges(start, end int, protected []model.Range) []model.Range {
+	var parts []model.Range
+	for _, r := range protected {
+		if r.End <= start || r.Start >= end {
+			continue
+		}
+		if r.Start > start {
+			parts = append(parts, model.Range{Start: start, End: r.Start})
+			start = r.Start
+		}
+		stop := min(r.End, end)
+		parts = append(parts, model.Range{Start: start, End: stop})
+		start = stop
+	}
+	if start < end {
+		parts = append(parts, model.Range{Start: start, End: end})
+	}
+	return parts
+}
+
+// RemapRanges refers ranges of derived text to the original input, through
+// the offset map of the derivation (see RemapSpans). A range ends just past
+// the original byte of its last byte, so that dropped text after it is not
+// taken in; empty ranges stay empty.
+func RemapRanges(ranges []model.Range, offsets []int) []model.Range {
+	remapped := make([]model.Range, len(ranges))
+	for i, r := range ranges {
+		start := offsets[clampOffset(r.Start, len(offsets)-1)]
+		remapped[i] = model.Range{Start: start, End: start}
+		if r.End > r.Start {
+			remapped[i].End = offsets[clampOffset(r.End-1, len(offsets)-1)] + 1
+		}
+	}
+	return remapped
+}
+
+// LocalRanges is the inverse of RemapRanges: it returns the ranges of derived
+// text whose bytes come from one of ranges of the original input, given the
+// offset map of the derivation. The resolver uses it to protect the parts of
+// a structural source's block content that are protected in the document.
+func LocalRanges(ranges []model.Range, offsets []int) []model.Range {
+	if len(ranges) == 0 {
+		return nil
+	}
+	var local []model.Range
+	for i := 0; i+1 < len(offsets); i++ {
+		if !inRanges(offsets[i], ranges) {
+			continue
+		}
+		if n := len(local); n > 0 && local[n-1].End == i {
+			local[n-1].End = i + 1
+		} else {
+			local = append(local, model.Range{Start: i, End: i + 1})
+		}
+	}
+	return local
+}
+
+// inRanges reports whether offset lies in one of the sorted ranges.
+func inRanges(offset int, ranges []model.Range) bool {
+	for _, r := range ranges {
+		if offset < r.Start {
+			return false
+		}
+		if offset < r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsEditML reports whether text holds something the parser would read
+// as EditML, such as an inline edit, a structural target or a debug comment
+// line. Host language modes use it to warn about markup in protected ranges,
+// which is kept as text.
+func ContainsEditML(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if isDebugCommentLine(strings.TrimSuffix(line, "\r")) {
+			return true
+		}
+	}
+	nodes, err := ParseEditMLToNodes(text)
+	if err != nil {
+		return false
+	}
+	for _, node := range nodes {
+		if _, ok := node.(model.TextNode); !ok {
+			return true
+		}
+	}
+	return false
+}
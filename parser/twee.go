@@ -0,0 +1,274 @@
+// parser/twee.go
+// package parser provides functionality to parse EditML text into an AST.
+package parser
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// Passage is a passage of a Twee 3 story: a header line of the form
+// ":: Name [tags] {metadata}" and the text after it, up to the next header.
+type Passage struct {
+	Name     string      // The passage name, with Twee escapes resolved.
+	Tags     []string    // The passage tags, in order; nil if the header has none.
+	Metadata string      // The raw JSON metadata object, or "" if the header has none.
+	Header   model.Range // The header line, without its line break.
+	Body     model.Range // The passage text after the header line, up to the next header.
+}
+
+var (
+	// tweeHeaderRegex matches passage header lines.
+	tweeHeaderRegex = regexp.MustCompile(`(?m)^::.*$`)
+
+	// tweeMacroRegex and tweeLinkRegex match story format macros
+	// (<<if $x>>) and links ([[Go north->North]]), which stay on one line.
+	tweeMacroRegex = regexp.MustCompile(`<<.*?>>`)
+	tweeLinkRegex  = regexp.MustCompile(`\[\[.*?\]\]`)
+)
+
+// ParseTweePassages splits a Twee 3 source into its passages, in order. Text
+// before the first header belongs to no passage.
+func ParseTweePassages(input string) []Passage {
+	var passages []Passage
+	for _, m := range tweeHeaderRegex.FindAllStringIndex(input, -1) {
+		p := parseTweeHeader(input[m[0]+2 : m[1]])
+		p.Header = model.Range{Start: m[0], End: m[1]}
+		if n := len(passages); n > 0 {
+			passages[n-1].Body.End = m[0]
+		}
+		p.Body = model.Range{Start: m[1], End: len(input)}
+		passages = append(passages, p)
+	}
+	return passages
+}
+
+// parseTweeHeader reads the name, tags and metadata of a passage header,
+// given the text after its "::". Backslashes escape the next character of
+// the name, so that names can contain brackets and braces.
+func parseTweeHeader(header string) Passage {
+	var p Passage
+	var name strings.Builder
+	i := 0
+	for ; i < len(header) && header[i] != '[' && header[i] != '{'; i++ {
+		if header[i] == '\\' && i+1 < len(header) {
+			i++
+		}
+		name.WriteByte(header[i])
+	}
+	p.Name = strings.TrimSpace(name.String())
+	rest := header[i:]
+	if strings.HasPrefix(rest, "[") {
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			end = len(rest) - 1
+		}
+		p.Tags = strings.Fields(rest[1:end])
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+	if strings.HasPrefix(rest, "{") {
+		p.Metadata = rest
+	}
+	return p
+}
+
+// isTweeCodePassage reports whether the whole body of p is code or data
+// rather than story text: the StoryData passage holds JSON, and passages
+// tagged script or stylesheet hold JavaScript and CSS.
+func isTweeCodePassage(p Passage) bool {
+	if p.Name == "StoryData" {
+		return true
+	}
+	for _, tag := range p.Tags {
+		if tag == "script" || tag == "stylesheet" {
+			return true
+		}
+	}
+	return false
+}
+
+// TweeProtectedRanges returns the ranges of a Twee 3 source that are not
+// story text, for ParseProtectedWithSpans: passage headers, macros, links,
+// and the bodies of code and data passages. The ranges are sorted and
+// disjoint.
+func TweeProtectedRanges(input string, passages []Passage) []model.Range {
+	var ranges []model.Range
+	for _, m := range tweeMacroRegex.FindAllStringIndex(input, -1) {
+		ranges = append(ranges, model.Range{Start: m[0], End: m[1]})
+	}
+	for _, m := range tweeLinkRegex.FindAllStringIndex(input, -1) {
+		ranges = append(ranges, model.Range{Start: m[0], End: m[1]})
+	}
+	for _, p := range passages {
+		ranges = append(ranges, p.Header)
+		if isTweeCodePassage(p) {
+			ranges = append(ranges, p.Body)
+		}
+	}
+	return mergeRanges(ranges)
+}
+
+// mergeRanges sorts ranges and merges the ones that overlap.
+func mergeRanges(ranges []model.Range) []model.Range {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	var merged []model.Range
+	for _, r := range ranges {
+		if r.Start >= r.End {
+			continue
+		}
+		if n := len(merged); n > 0 && r.Start <= merged[n-1].End {
+			merged[n-1].End = max(merged[n-1].End, r.End)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// ParseTweeWithSpans parses EditML in a Twee 3 source. The text before the
+// first passage and each passage are parsed on their own, so no edit spans
+// two passages, and the ranges returned by TweeProtectedRanges are protected.
+// It returns the nodes with their spans, the passages and the protected
+// ranges.
+//
+// A move or copy source without any target whose tag is the name of a
+// passage targets that passage: a target node is added on a line of its own
+// at the end of the passage's text, before the blank lines that separate it
+// from the next one, or on the line after the header if the passage is empty.
+func ParseTweeWithSpans(input string) ([]model.Node, []model.Span, []Passage, []model.Range, error) {
+	passages := ParseTweePassages(input)
+	protected := TweeProtectedRanges(input, passages)
+	lines := NewLineIndex(input)
+
+	// Segment 0 is the text before the first passage; segment i+1 is
+	// passages[i], from its header to the next header.
+	bounds := []int{0}
+	for _, p := range passages {
+		bounds = append(bounds, p.Header.Start)
+	}
+	bounds = append(bounds, len(input))
+	segmentNodes := make([][]model.Node, len(bounds)-1)
+	segmentSpans := make([][]model.Span, len(bounds)-1)
+	var firstErr error
+	for i := range segmentNodes {
+		start, end := bounds[i], bounds[i+1]
+		var local []model.Range
+		for _, r := range splitRanges(start, end, protected) {
+			if inRanges(r.Start, protected) {
+				local = append(local, model.Range{Start: r.Start - start, End: r.End - start})
+			}
+		}
+		nodes, spans, err := ParseProtectedWithSpans(input[start:end], local)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		for j := range spans {
+			spans[j].Start = lines.Position(spans[j].Start.Offset + start)
+			spans[j].End = lines.Position(spans[j].End.Offset + start)
+			for k := range spans[j].Content {
+				spans[j].Content[k] += start
+			}
+		}
+		segmentNodes[i], segmentSpans[i] = nodes, spans
+	}
+
+	// Find the sources that target passages.
+	sources := make(map[string]model.StructuralSourceNode)
+	targeted := make(map[string]bool)
+	for _, nodes := range segmentNodes {
+		for _, node := range nodes {
+			switch n := node.(type) {
+			case model.StructuralSourceNode:
+				sources[n.Tag] = n
+			case model.StructuralTargetNode:
+				targeted[n.Tag] = true
+			}
+		}
+	}
+	for i, p := range passages {
+		if source, ok := sources[p.Name]; ok && !targeted[p.Name] {
+			targeted[p.Name] = true // Later passages of the same name are not targets.
+			offset := passageEnd(input, p)
+			target := model.StructuralTargetNode{Operation: source.Operation, Tag: source.Tag}
+			segmentNodes[i+1], segmentSpans[i+1] = insertNode(segmentNodes[i+1], segmentSpans[i+1], target, lines.Position(offset))
+			if offset > 0 && input[offset-1] != '\n' {
+				// The block goes on a line of its own, not after the last word.
+				segmentNodes[i+1], segmentSpans[i+1] = insertNode(segmentNodes[i+1], segmentSpans[i+1], model.TextNode{Text: "\n"}, lines.Position(offset))
+			}
+		}
+	}
+
+	var nodes []model.Node
+	var spans []model.Span
+	for i := range segmentNodes {
+		nodes = append(nodes, segmentNodes[i]...)
+		spans = append(spans, segmentSpans[i]...)
+	}
+	if nodes == nil {
+		nodes, spans = []model.Node{}, []model.Span{}
+	}
+	return nodes, spans, passages, protected, firstErr
+}
+
+// TweeCutMarkup returns the markup of a Twee 3 source that ParseTweeWithSpans
+// reads as text because a passage header cuts it off: the constructs that
+// would span a header if the source were parsed as one text, such as an
+// edit opened in one passage and closed in the next. Each is returned as the
+// range from its start to the first header it spans. passages and protected
+// are those of ParseTweeWithSpans.
+func TweeCutMarkup(input string, passages []Passage, protected []model.Range) []model.Range {
+	nodes, spans, err := ParseProtectedWithSpans(input, protected)
+	if err != nil {
+		return nil
+	}
+	var cuts []model.Range
+	for i, node := range nodes {
+		if _, ok := node.(model.TextNode); ok || i >= len(spans) {
+			continue
+		}
+		for _, p := range passages {
+			if spans[i].Start.Offset < p.Header.Start && p.Header.Start < spans[i].End.Offset {
+				cuts = append(cuts, model.Range{Start: spans[i].Start.Offset, End: p.Header.Start})
+				break
+			}
+		}
+	}
+	return cuts
+}
+
+// passageEnd returns the offset at which a target at the end of p goes: just
+// past its last non-blank text, or at the start of the line after the header
+// if the passage is empty.
+func passageEnd(input string, p Passage) int {
+	body := input[p.Body.Start:p.Body.End]
+	end := p.Body.Start + len(strings.TrimRight(body, " \t\r\n"))
+	if end == p.Body.Start && strings.HasPrefix(body, "\n") {
+		end++
+	}
+	return end
+}
+
+// insertNode inserts node with an empty span at pos into nodes and their
+// spans, splitting the TextNode that covers pos.
+func insertNode(nodes []model.Node, spans []model.Span, node model.Node, pos model.Position) ([]model.Node, []model.Span) {
+	k := sort.Search(len(spans), func(i int) bool { return spans[i].Start.Offset >= pos.Offset })
+	if k > 0 && spans[k-1].End.Offset > pos.Offset {
+		// pos lies inside node k-1, which is always text: edits end where the
+		// passage's text does, before its trailing blank lines.
+		text, ok := nodes[k-1].(model.TextNode)
+		if ok {
+			span := spans[k-1]
+			cut := pos.Offset - span.Start.Offset
+			before := model.Span{Start: span.Start, End: pos, Content: span.Content[: cut+1 : cut+1]}
+			after := model.Span{Start: pos, End: span.End, Content: span.Content[cut:]}
+			nodes = append(nodes[:k-1], append([]model.Node{model.TextNode{Text: text.Text[:cut]}, model.TextNode{Text: text.Text[cut:]}}, nodes[k:]...)...)
+			spans = append(spans[:k-1], append([]model.Span{before, after}, spans[k:]...)...)
+		}
+	}
+	nodes = append(nodes[:k], append([]model.Node{node}, nodes[k:]...)...)
+	spans = append(spans[:k], append([]model.Span{{Start: pos, End: pos}}, spans[k:]...)...)
+	return nodes, spans
+}
@@ -0,0 +1,285 @@
+// patch.go
+// package editml defines the public API for parsing and transforming EditML documents.
+package editml
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/verkaro/editml-go/model"
+	"github.com/verkaro/editml-go/parser"
+	"github.com/verkaro/editml-go/transformer"
+)
+
+// Codes of the issues reported when importing a patch. Their Line and Column
+// refer to the patch, not to the document.
+const (
+	IssueMalformedPatch           = IssueCode(parser.WarningMalformedPatch)
+	IssueExtraFile                = IssueCode(parser.WarningExtraFile)
+	IssueHunkFailed     IssueCode = "hunk-failed"
+)
+
+// ImportPatch applies a patch to base, an EditML or plain-text document, as
+// suggestions instead of changes: the text the patch removes becomes
+// deletions and the text it adds additions, attributed to editorID
+// (unattributed if empty). The rest of base is kept as written, so existing
+// markup is preserved. The patch may be a unified diff (diff -u, git diff),
+// whose removed and added lines become the edits, or a plain word diff (git
+// diff --word-diff=plain), whose removed and added words do; see
+// parser.ParsePatch.
+//
+// The patch must have been made against the source of base. Each hunk is
+// applied where its header says, or, if base has changed since, at the
+// nearest place after the previous hunk where its context and removed text
+// are found. Hunks that cannot be placed, and hunks that remove or add
+// EditML markup, which edits cannot contain, or change text inside an
+// existing construct, are skipped and reported as errors with code
+// IssueHunkFailed, naming their hunk header.
+func ImportPatch(base, patch, editorID string) (outputText string, issues []Issue) {
+	currentIssues := []Issue{}
+	if editorID != "" && !parser.IsEditorID(editorID) {
+		currentIssues = append(currentIssues, Issue{
+			Message:  fmt.Sprintf("Patch error: invalid editor ID %q, want 1 to 5 letters and digits", editorID),
+			Severity: SeverityError,
+			Code:     IssueInvalidEditorID,
+		})
+		return base, currentIssues
+	}
+
+	hunks, warnings := parser.ParsePatch(patch)
+	for _, w := range warnings {
+		currentIssues = append(currentIssues, Issue{
+			Message:  fmt.Sprintf("Patch warning: %s", w.Message),
+			Line:     w.Line,
+			Column:   1,
+			Severity: SeverityWarning,
+			Code:     IssueCode(w.Code),
+		})
+	}
+	if len(hunks) == 0 {
+		currentIssues = append(currentIssues, Issue{
+			Message:  "Patch warning: no hunks found",
+			Severity: SeverityWarning,
+			Code:     IssueMalformedPatch,
+		})
+	}
+
+	lines := parser.NewLineIndex(base)
+	constructs := markupRanges(base)
+	var sb strings.Builder
+	cursor := 0 // Offset in base up to which the output is written.
+	for _, h := range hunks {
+		// A hunk without original lines inserts after line OldStart.
+		start := h.OldStart
+		if h.OldLines == 0 {
+			start++
+		}
+		ranges := locateHunk(base, h, lines.LineOffset(start), cursor)
+		if ranges == nil {
+			currentIssues = append(currentIssues, Issue{
+				Message:  fmt.Sprintf("Patch error: hunk %s does not apply", h.Header),
+				Line:     h.Line,
+				Column:   1,
+				Severity: SeverityError,
+				Code:     IssueHunkFailed,
+			})
+			continue
+		}
+		var hunk strings.Builder
+		if writeHunk(&hunk, base, h, ranges, ranges[0][0], editorID, constructs) {
+			currentIssues = append(currentIssues, Issue{
+				Message:  fmt.Sprintf("Patch error: hunk %s changes EditML markup, which edits cannot contain; apply it by hand", h.Header),
+				Line:     h.Line,
+				Column:   1,
+				Severity: SeverityError,
+				Code:     IssueHunkFailed,
+			})
+			continue
+		}
+		sb.WriteString(base[cursor:ranges[0][0]])
+		sb.WriteString(hunk.String())
+		cursor = ranges[len(ranges)-1][1]
+	}
+	sb.WriteString(base[cursor:])
+	return sb.String(), currentIssues
+}
+
+// whitespaceRuns matches the runs of whitespace and of other characters.
+var whitespaceRuns = regexp.MustCompile(`\s+|\S+`)
+
+// locateHunk finds the text a hunk applies to in base, at or after from:
+// at expected if it is there, else at the place nearest to expected. It
+// returns the range of base matched by each segment of the hunk (empty for
+// added segments), or nil if the hunk is not found.
+//
+// Word diffs do not show all whitespace faithfully, in particular newlines
+// next to removed or added text, so their text is matched with any
+// whitespace where they have whitespace, and any whitespace is allowed
+// between segments. Segments on both sides of added text may show the same
+// whitespace of base, as in "the {+lazy+} dog", so whitespace that starts a
+// segment is optional after a segment that ends with whitespace.
+func locateHunk(base string, h parser.PatchHunk, expected, from int) [][2]int {
+	var pattern strings.Builder
+	afterSpace := false // Whether the last segment of base text ends with whitespace.
+	for i, s := range h.Segments {
+		switch {
+		case s.Kind == parser.PatchAdded:
+			pattern.WriteString("()")
+			continue
+		case !h.WordDiff:
+			pattern.WriteString("(" + regexp.QuoteMeta(s.Text) + ")")
+		default:
+			pattern.WriteString(`\s*(`)
+			for _, loc := range whitespaceRuns.FindAllStringIndex(s.Text, -1) {
+				if run := s.Text[loc[0]:loc[1]]; strings.TrimSpace(run) != "" {
+					pattern.WriteString(regexp.QuoteMeta(run))
+				} else if i == len(h.Segments)-1 && loc[1] == len(s.Text) || loc[0] == 0 && afterSpace {
+					pattern.WriteString(`\s*`) // The text may end without a newline.
+				} else if loc[1] == len(s.Text) {
+					pattern.WriteString(`\s+?`) // Leave the rest to the next segment.
+				} else {
+					pattern.WriteString(`\s+`)
+				}
+			}
+			pattern.WriteString(")")
+		}
+		afterSpace = strings.TrimRightFunc(s.Text, unicode.IsSpace) != s.Text
+	}
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil
+	}
+
+	var best []int
+	for _, m := range re.FindAllStringSubmatchIndex(base[from:], -1) {
+		if best == nil || absInt(from+m[0]-expected) < absInt(from+best[0]-expected) {
+			best = m
+		}
+		if from+m[0] >= expected {
+			break
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	ranges := make([][2]int, len(h.Segments))
+	for i := range ranges {
+		ranges[i] = [2]int{from + best[2+2*i], from + best[3+2*i]}
+	}
+	return ranges
+}
+
+// writeHunk writes the part of base a hunk applies to, located at ranges by
+// locateHunk and starting at offset start, with its context as is and each
+// run of removed and added text as a deletion followed by an addition.
+// Whitespace between the segments of a word diff belongs to the deletion
+// next to it, if any, and so do the spaces after a deletion of words that
+// follows whitespace, so that no double space is left. Whitespace a word diff
+// shows after added text but base shares with the text before goes with the
+// addition. It reports whether the removed or added text contains
+// EditML markup, or whether an edit would replace or insert text inside one
+// of constructs, the ranges of base held by markup (see markupRanges).
+func writeHunk(sb *strings.Builder, base string, h parser.PatchHunk, ranges [][2]int, start int, editorID string, constructs [][2]int) (changesMarkup bool) {
+	var deleted, inserted strings.Builder
+	editStart := start // Offset in base of the text the pending edits replace.
+	flush := func(editEnd int) {
+		if deleted.Len() > 0 || inserted.Len() > 0 {
+			changesMarkup = changesMarkup || hasMarkup(deleted.String()) || hasMarkup(inserted.String()) ||
+				cutsMarkup(constructs, editStart, editEnd)
+		}
+		sb.WriteString(transformer.EditMarkup(model.EditTypeDeletion, deleted.String(), editorID))
+		sb.WriteString(transformer.EditMarkup(model.EditTypeAddition, inserted.String(), editorID))
+		deleted.Reset()
+		inserted.Reset()
+	}
+	pos := start
+	afterRemoved := false // Whether the last segment with text was removed.
+	for i, s := range h.Segments {
+		if s.Kind == parser.PatchAdded {
+			inserted.WriteString(s.Text)
+			continue
+		}
+		gap, text := base[pos:ranges[i][0]], base[ranges[i][0]:ranges[i][1]]
+		editEnd := pos
+		pos = ranges[i][1]
+		if h.WordDiff && inserted.Len() > 0 && !startsWithSpace(gap+text) {
+			inserted.WriteString(s.Text[:len(s.Text)-len(strings.TrimLeftFunc(s.Text, unicode.IsSpace))])
+		}
+		if s.Kind == parser.PatchRemoved {
+			deleted.WriteString(gap + text)
+			afterRemoved = true
+			continue
+		}
+		if afterRemoved {
+			deleted.WriteString(gap)
+			editEnd += len(gap)
+			gap = ""
+			if h.WordDiff && inserted.Len() == 0 && (editStart == 0 || startsWithSpace(base[editStart-1:])) {
+				spaces := len(text) - len(strings.TrimLeft(text, " \t"))
+				deleted.WriteString(text[:spaces])
+				editEnd += spaces
+				text = text[spaces:]
+			}
+		}
+		flush(editEnd)
+		sb.WriteString(gap + text)
+		editStart = pos
+		afterRemoved = false
+	}
+	flush(pos)
+	return changesMarkup
+}
+
+// startsWithSpace reports whether s starts with whitespace.
+func startsWithSpace(s string) bool {
+	return strings.TrimLeftFunc(s, unicode.IsSpace) != s
+}
+
+// markupRanges returns the ranges of base held by anything but plain text
+// when it is parsed as EditML.
+func markupRanges(base string) [][2]int {
+	doc, _ := ProcessDocument(base)
+	if doc == nil {
+		return nil
+	}
+	var constructs [][2]int
+	for i, n := range doc.Nodes {
+		if _, ok := n.(model.TextNode); !ok && i < len(doc.Spans) {
+			constructs = append(constructs, [2]int{doc.Spans[i].Start.Offset, doc.Spans[i].End.Offset})
+		}
+	}
+	return constructs
+}
+
+// cutsMarkup reports whether replacing base[start:end] changes text inside
+// one of constructs: whether the range overlaps one, or, if it is empty,
+// lies strictly inside one.
+func cutsMarkup(constructs [][2]int, start, end int) bool {
+	for _, c := range constructs {
+		if start < c[1] && c[0] < end || start == end && c[0] < start && start < c[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMarkup reports whether text parses as anything but plain text.
+func hasMarkup(text string) bool {
+	nodes, _ := Parse(text)
+	for _, n := range nodes {
+		if _, ok := n.(model.TextNode); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// absInt returns the absolute value of n.
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
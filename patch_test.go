@@ -0,0 +1,129 @@
+// patch_test.go
+// package editml_test contains unit tests for importing patches as EditML suggestions.
+package editml
+
+import (
+	"strings"
+	"testing"
+)
+
+// patchBase is the document the test patches were made against.
+const patchBase = "Title\n\nThe quick brown fox jumps.\nSecond line here.\nThird line.\n\nLast para.\n"
+
+// unifiedPatch is the output of git diff for an edit of patchBase.
+const unifiedPatch = `diff --git a/f.txt b/f.txt
+index fa264b7..fbd8f50 100644
+--- a/f.txt
++++ b/f.txt
+@@ -1,7 +1,7 @@
+ Title
+ 
+-The quick brown fox jumps.
+-Second line here.
++The slow brown fox jumps.
+ Third line.
++New line.
+ 
+ Last para.
+`
+
+// TestImportPatchUnified tests that removed and added lines become deletions and additions.
+func TestImportPatchUnified(t *testing.T) {
+	output, issues := ImportPatch(patchBase, unifiedPatch, "jd")
+	if len(issues) > 0 {
+		t.Fatalf("ImportPatch: unexpected issues: %v", issues)
+	}
+	expected := "Title\n\n{-The quick brown fox jumps.\nSecond line here.\n-jd}{+The slow brown fox jumps.\n+jd}Third line.\n{+New line.\n+jd}\nLast para.\n"
+	if output != expected {
+		t.Errorf("ImportPatch:\nExpected: %q\nGot:      %q", expected, output)
+	}
+}
+
+// TestImportPatchWordDiff tests that removed and added words of a word diff become deletions and additions.
+func TestImportPatchWordDiff(t *testing.T) {
+	patch := "diff --git a/f.txt b/f.txt\n--- a/f.txt\n+++ b/f.txt\n@@ -1,7 +1,7 @@\n" +
+		"Title\n\nThe [-quick-]{+slow+} brown fox jumps.[-Second line here.-]\nThird line.\n{+New line.+}\n\nLast para.\n"
+	output, issues := ImportPatch(patchBase, patch, "jd")
+	if len(issues) > 0 {
+		t.Fatalf("ImportPatch: unexpected issues: %v", issues)
+	}
+	expected := "Title\n\nThe {-quick-jd}{+slow+jd} brown fox jumps.{-\nSecond line here.-jd}\nThird line.\n{+New line.\n+jd}\nLast para.\n"
+	if output != expected {
+		t.Errorf("ImportPatch:\nExpected: %q\nGot:      %q", expected, output)
+	}
+	doc, _ := ProcessDocument(output)
+	clean, _ := TransformDocument(doc, ProfileCleanView, nil)
+	if want := "Title\n\nThe slow brown fox jumps.\nThird line.\nNew line.\n\nLast para."; clean != want {
+		t.Errorf("ImportPatch: Clean View = %q, want %q", clean, want)
+	}
+}
+
+// TestImportPatchOffsetAndMarkup tests that hunks are found after the base has changed, keeping its markup.
+func TestImportPatchOffsetAndMarkup(t *testing.T) {
+	base := "Preface {+added later+ab}.\n\n" + patchBase
+	output, issues := ImportPatch(base, unifiedPatch, "")
+	if len(issues) > 0 {
+		t.Fatalf("ImportPatch: unexpected issues: %v", issues)
+	}
+	if !strings.HasPrefix(output, "Preface {+added later+ab}.\n\nTitle\n\n{-The quick brown fox jumps.\nSecond line here.\n-}{+The slow") {
+		t.Errorf("ImportPatch: hunk not applied after the preface: %q", output)
+	}
+}
+
+// TestImportPatchFailedHunks tests that hunks that do not apply are reported with their headers.
+func TestImportPatchFailedHunks(t *testing.T) {
+	patch := unifiedPatch + "@@ -20,2 +20,2 @@\n-Missing line.\n+Replacement.\n context\n"
+	output, issues := ImportPatch(patchBase, patch+"@@ -7 +7 @@\n-Last para.\n+Last {+para+}.\n", "jd")
+	var headers []string
+	for _, issue := range issues {
+		if issue.Code != IssueHunkFailed || issue.Severity != SeverityError {
+			t.Errorf("ImportPatch: unexpected issue %v", issue)
+		}
+		headers = append(headers, issue.Message)
+	}
+	if len(headers) != 2 || !strings.Contains(headers[0], "@@ -20,2 +20,2 @@") || !strings.Contains(headers[1], "@@ -7 +7 @@") {
+		t.Errorf("ImportPatch: expected failures of the last two hunks, got %v", headers)
+	}
+	if !strings.Contains(output, "{+The slow brown fox jumps.\n+jd}") || !strings.HasSuffix(output, "\nLast para.\n") {
+		t.Errorf("ImportPatch: expected only the first hunk to be applied: %q", output)
+	}
+}
+
+// TestImportPatchInsideMarkup tests that hunks changing text inside an existing construct are rejected, leaving it intact.
+func TestImportPatchInsideMarkup(t *testing.T) {
+	base := "one\n{+two\nthree+ws} end\nfour\n"
+	patch := "--- a/f.txt\n+++ b/f.txt\n@@ -1,3 +1,3 @@\n one\n-{+two\n+{+TWO\n three+ws} end\n@@ -4 +4 @@\n-four\n+FOUR\n"
+	output, issues := ImportPatch(base, patch, "jd")
+	if len(issues) != 1 || issues[0].Code != IssueHunkFailed || !strings.Contains(issues[0].Message, "@@ -1,3 +1,3 @@") {
+		t.Errorf("ImportPatch: issues = %v, want one %s error for the first hunk", issues, IssueHunkFailed)
+	}
+	if expected := "one\n{+two\nthree+ws} end\n{-four\n-jd}{+FOUR\n+jd}"; output != expected {
+		t.Errorf("ImportPatch:\nExpected: %q\nGot:      %q", expected, output)
+	}
+}
+
+// TestImportPatchWordDiffSpacing tests that words added between words share their whitespace, and removed words take theirs along.
+func TestImportPatchWordDiffSpacing(t *testing.T) {
+	base := "The fox jumps over the dog.\n"
+	testCases := []struct {
+		name     string
+		line     string
+		expected string
+	}{
+		{"insertion", "The fox jumps over the {+lazy+} dog.", "The fox jumps over the {+lazy +jd}dog.\n"},
+		{"deletion", "The fox jumps over [-the-] dog.", "The fox jumps over {-the -jd}dog.\n"},
+		{"replacement", "The [-fox-]{+cat+} jumps over the dog.", "The {-fox-jd}{+cat+jd} jumps over the dog.\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patch := "--- a/f.txt\n+++ b/f.txt\n@@ -1 +1 @@\n" + tc.line + "\n"
+			output, issues := ImportPatch(base, patch, "jd")
+			if len(issues) > 0 {
+				t.Fatalf("ImportPatch: unexpected issues: %v", issues)
+			}
+			if output != tc.expected {
+				t.Errorf("ImportPatch:\nExpected: %q\nGot:      %q", tc.expected, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// profile.go
+// package editml defines the public API for parsing and transforming EditML documents.
+package editml
+
+import (
+	"github.com/verkaro/editml-go/transformer"
+)
+
+// Renderer produces one output format from a resolved document. See
+// transformer.Renderer for the callback contract.
+type Renderer = transformer.Renderer
+
+// ProfileFactory creates a fresh Renderer each time a profile is used.
+type ProfileFactory = transformer.ProfileFactory
+
+// ProfileOptions carries profile-specific settings as key/value pairs.
+type ProfileOptions = transformer.Options
+
+// Names of the built-in transformation profiles.
+const (
+	ProfileCleanView = transformer.ProfileCleanView
+	// ProfileTemplate renders each node with a user-supplied template; the
+	// "file" option names the template file. See transformer.TemplateRenderer.
+	ProfileTemplate = transformer.ProfileTemplate
+	// ProfileTerminal renders edits with ANSI colors for terminal review; the
+	// "color" option is always or never (default). Callers decide whether
+	// their output is a terminal.
+	ProfileTerminal = transformer.ProfileTerminal
+	// ProfileMarkdown exports Markdown with comments as footnotes; options
+	// "mode" (show or apply) and "highlight" (mark or bold).
+	ProfileMarkdown = transformer.ProfileMarkdown
+	// ProfileChanges lists every edit with surrounding context; options
+	// "format" (text or markdown), "group" (order or editor) and "context"
+	// (words of context, default 5).
+	ProfileChanges = transformer.ProfileChanges
+	// ProfileOriginal renders the text before any edit is applied.
+	ProfileOriginal = transformer.ProfileOriginal
+	// ProfileDiff diffs the original text against the Clean View; options
+	// "format" (unified or side-by-side), "context" (lines, default 3),
+	// "width" (side-by-side, default 80), "from"/"to" (file labels) and
+	// "cleanup".
+	ProfileDiff = transformer.ProfileDiff
+	// ProfileMarkup writes the document back as EditML (the MarkupView of
+	// Spec 5.1); options "accept" and "reject" list edit numbers (as in
+	// ProfileChanges) or editor IDs to resolve. See ApplyDecisions.
+	ProfileMarkup = transformer.ProfileMarkup
+	// ProfileHTML renders an HTML preview with linked moves and copies and a
+	// summary of structural tags; options "fragment" and "title".
+	ProfileHTML = transformer.ProfileHTML
+	// ProfileCriticMarkup exports CriticMarkup; moves and copies are
+	// approximated with warnings. See ToCriticMarkup.
+	ProfileCriticMarkup = transformer.ProfileCriticMarkup
+	// ProfileDOCX exports a Word document with tracked changes and comments;
+	// options "author" (for edits without an editor ID) and "date". The
+	// output is binary. See ExportDOCX.
+	ProfileDOCX = transformer.ProfileDOCX
+	// ProfileODT exports an OpenDocument text file with tracked changes and
+	// annotations; options "author" and "date". The output is binary. See
+	// ExportODT.
+	ProfileODT = transformer.ProfileODT
+	// ProfileLaTeX exports LaTeX with the markup of the changes package;
+	// options "output" (document, body or preamble) and "class".
+	ProfileLaTeX = transformer.ProfileLaTeX
+	// ProfilePandoc exports Pandoc's JSON AST with edits as Pandoc
+	// track-changes spans, for "pandoc -f json"; options "author" and "date".
+	ProfilePandoc = transformer.ProfilePandoc
+	// ProfileLedger lists every edit as a machine-readable record with a
+	// stable ID, position and enclosing paragraph; option "format" (jsonl or
+	// csv). See transformer.LedgerEntry.
+	ProfileLedger = transformer.ProfileLedger
+)
+
+// RegisterProfile makes a custom rendering profile available under name, both
+// to TransformDocument and to the editml-tester --profile flag. It is intended
+// to be called from an init function and panics if name is already in use.
+func RegisterProfile(name string, factory ProfileFactory) {
+	transformer.RegisterProfile(name, factory)
+}
+
+// Profiles returns the names of all registered profiles, sorted.
+func Profiles() []string {
+	return transformer.Profiles()
+}
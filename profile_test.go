@@ -0,0 +1,109 @@
+// profile_test.go
+// package editml_test contains unit tests for the editml profile registry.
+package editml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/verkaro/editml-go/model"
+	"github.com/verkaro/editml-go/transformer"
+)
+
+// bracketRenderer is a minimal custom profile used to exercise the registry.
+// It wraps every inline edit in brackets naming its type and renders resolved
+// structural blocks at their targets.
+type bracketRenderer struct {
+	sb strings.Builder
+}
+
+func (r *bracketRenderer) Text(w *transformer.Walker, n model.TextNode) {
+	r.sb.WriteString(n.Text)
+}
+
+func (r *bracketRenderer) InlineEdit(w *transformer.Walker, n model.InlineEditNode) {
+	fmt.Fprintf(&r.sb, "[%s:%s]", n.EditType, n.Content)
+}
+
+func (r *bracketRenderer) StructuralSource(w *transformer.Walker, n model.StructuralSourceNode, src *transformer.ResolvedSource) {
+	fmt.Fprintf(&r.sb, "[source %s]", n.Tag)
+}
+
+func (r *bracketRenderer) StructuralTarget(w *transformer.Walker, n model.StructuralTargetNode, src *transformer.ResolvedSource) {
+	if src != nil && src.Resolved {
+		w.WalkBlock(src)
+		return
+	}
+	fmt.Fprintf(&r.sb, "[unresolved %s]", n.Tag)
+}
+
+func (r *bracketRenderer) Result() (string, error) {
+	return r.sb.String(), nil
+}
+
+func init() {
+	RegisterProfile("test-brackets", func(opts ProfileOptions) (Renderer, error) {
+		return &bracketRenderer{}, nil
+	})
+}
+
+// TestTransformDocumentCustomProfile tests that a registered profile is used by TransformDocument.
+func TestTransformDocumentCustomProfile(t *testing.T) {
+	inputText := "Hi {+there+ws}{mv~a {-b-}~T1} and {mv:T1} {cp:T2}"
+	expectedOutput := "Hi [addition:there][source T1] and a [deletion:b] [unresolved T2]"
+
+	doc, parseIssues := ProcessDocument(inputText)
+	if len(parseIssues) > 0 {
+		t.Fatalf("ProcessDocument(%q) returned unexpected issues: %v", inputText, parseIssues)
+	}
+
+	output, issues := TransformDocument(doc, "test-brackets", nil)
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	if output != expectedOutput {
+		t.Errorf("TransformDocument custom profile: output = %q, want %q", output, expectedOutput)
+	}
+
+	found := false
+	for _, name := range Profiles() {
+		if name == "test-brackets" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Profiles() = %v, want it to contain %q", Profiles(), "test-brackets")
+	}
+}
+
+// TestTransformDocumentUnknownProfile tests that an unknown profile is reported as an error issue.
+func TestTransformDocumentUnknownProfile(t *testing.T) {
+	doc, _ := ProcessDocument("text")
+	_, issues := TransformDocument(doc, "no-such-profile", nil)
+	if len(issues) != 1 || issues[0].Severity != SeverityError {
+		t.Fatalf("TransformDocument with unknown profile: issues = %v, want one error", issues)
+	}
+}
+
+// TestTransformDocumentCleanProfileMatchesCleanView tests that the registered
+// clean profile produces the same output as TransformCleanView.
+func TestTransformDocumentCleanProfileMatchesCleanView(t *testing.T) {
+	inputBytes, err := os.ReadFile(filepath.Join("testdata", "multiline.md"))
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+
+	doc, _ := ProcessDocument(string(inputBytes))
+	expectedOutput, _ := TransformCleanView(doc.Nodes)
+
+	output, issues := TransformDocument(doc, ProfileCleanView, nil)
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	if output != expectedOutput {
+		t.Errorf("TransformDocument(clean) = %q, want %q", output, expectedOutput)
+	}
+}
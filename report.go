@@ -0,0 +1,284 @@
+// report.go
+// package editml defines the public API for parsing and transforming EditML documents.
+package editml
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+)
+
+// FileIssues pairs the issues found in a file with its path, for reports
+// covering several files.
+type FileIssues struct {
+	Path   string // Path of the file, as it should appear in the report.
+	Issues []Issue
+}
+
+// issueDescriptions describes the issue codes, for the rules of SARIF
+// reports.
+var issueDescriptions = map[IssueCode]string{
+	IssueBlockParseError:      "The block content of a structural source cannot be parsed.",
+	IssueBlockResolveError:    "The block content of a structural source cannot be resolved.",
+	IssueOperationMismatch:    "A structural target's operation differs from its source's.",
+	IssueDuplicateSource:      "Several structural sources share a tag.",
+	IssueMultipleMoveTargets:  "A move has several targets.",
+	IssueUnsupportedConstruct: "The output format has no equivalent for a construct.",
+	IssueMalformedMarkup:      "Imported markup is not closed or is incomplete.",
+	IssueUnknownEditor:        "An author has no valid editor ID.",
+	IssueUnpairedMove:         "One side of an imported move has no counterpart.",
+	IssueNestedEdit:           "An imported edit is nested in an edit of another kind.",
+	IssueInvalidEditorID:      "An editor ID is not 1 to 5 letters and digits.",
+	IssueLineEndings:          "CRLF line endings were converted to LF.",
+	IssueInexactDiff:          "Generated EditML does not reproduce both versions exactly.",
+	IssueMalformedPatch:       "A patch hunk is shorter than its header says.",
+	IssueExtraFile:            "A patch changes more than one file.",
+	IssueHunkFailed:           "A patch hunk cannot be applied.",
+	IssueAnchorNotFound:       "The quote of an annotation is not found.",
+	IssueAnchorAmbiguous:      "The quote of an annotation is found in several places.",
+	IssueAnchorOverlap:        "The passage of an annotation is not plain text.",
+	IssueInvalidAnnotations:   "Annotations are not valid JSON.",
+	IssueDuplicatePassage:     "Two passages of a Twee story have the same name.",
+	IssueProtectedMarkup:      "Code or a comment holds text that reads as EditML.",
+}
+
+// issueRuleID is the rule ID of issues without a code.
+const issueRuleID = "editml"
+
+// severityRank orders severities: warnings rank above nothing, and errors
+// above warnings.
+func severityRank(s IssueSeverity) int {
+	switch s {
+	case SeverityError:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// ParseSeverityThreshold returns the severity named s, for use with
+// ExceedsThreshold: "error" or "warning", or "none" for a threshold no issue
+// reaches, which is returned as "".
+func ParseSeverityThreshold(s string) (IssueSeverity, error) {
+	switch s {
+	case string(SeverityError), string(SeverityWarning):
+		return IssueSeverity(s), nil
+	case "none":
+		return "", nil
+	}
+	return "", fmt.Errorf("invalid severity %q, want error, warning or none", s)
+}
+
+// ExceedsThreshold reports whether one of the issues is at least as severe as
+// threshold: with SeverityWarning, any issue does; with SeverityError, only
+// errors do; with "", none does. CI jobs use it to decide whether to fail.
+func ExceedsThreshold(issues []Issue, threshold IssueSeverity) bool {
+	if threshold == "" {
+		return false
+	}
+	for _, issue := range issues {
+		if severityRank(issue.Severity) >= severityRank(threshold) {
+			return true
+		}
+	}
+	return false
+}
+
+// sarifLog and the types below are the parts of the SARIF 2.1.0 object
+// model that reports use.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// SARIF returns the issues of files as a SARIF 2.1.0 log, for code-scanning
+// tools. Each issue code is a rule, with the code as its ID (issues without
+// a code share the rule "editml"); errors have the level "error" and
+// warnings "warning". Issues with a line have a region; columns count
+// Unicode code points, as in Issue, and end columns are just past the end,
+// as in SARIF. Paths are used as artifact URIs as they are.
+func SARIF(files []FileIssues) ([]byte, error) {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "editml",
+			InformationURI: "https://github.com/verkaro/editml-go",
+			Rules:          []sarifRule{},
+		}},
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+	rules := make(map[string]int) // Rule ID -> index in Rules.
+	for _, ruleID := range reportRuleIDs(files) {
+		description, ok := issueDescriptions[IssueCode(ruleID)]
+		if !ok {
+			description = "EditML issue."
+		}
+		rules[ruleID] = len(run.Tool.Driver.Rules)
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: ruleID, ShortDescription: sarifMessage{description}})
+	}
+	for _, f := range files {
+		for _, issue := range f.Issues {
+			ruleID := reportRuleID(issue)
+			location := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: f.Path}}
+			if issue.Line > 0 {
+				location.Region = &sarifRegion{StartLine: issue.Line, StartColumn: issue.Column}
+				if issue.EndLine > 0 {
+					location.Region.EndLine, location.Region.EndColumn = issue.EndLine, issue.EndColumn
+				}
+			}
+			level := "warning"
+			if issue.Severity == SeverityError {
+				level = "error"
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    ruleID,
+				RuleIndex: rules[ruleID],
+				Level:     level,
+				Message:   sarifMessage{issue.Message},
+				Locations: []sarifLocation{{PhysicalLocation: location}},
+			})
+		}
+	}
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// checkstyleReport and the types below are the elements of a checkstyle XML
+// report.
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// Checkstyle returns the issues of files as a checkstyle XML report, which
+// many CI systems can annotate changes with. Every file gets a <file>
+// element, issues or not. Issues without a line are reported at line 0,
+// the file itself; the source of each is "editml." followed by its rule ID,
+// as in SARIF.
+func Checkstyle(files []FileIssues) ([]byte, error) {
+	report := checkstyleReport{Version: "4.3"}
+	for _, f := range files {
+		file := checkstyleFile{Name: f.Path}
+		for _, issue := range f.Issues {
+			severity := "warning"
+			if issue.Severity == SeverityError {
+				severity = "error"
+			}
+			file.Errors = append(file.Errors, checkstyleError{
+				Line:     issue.Line,
+				Column:   issue.Column,
+				Severity: severity,
+				Message:  issue.Message,
+				Source:   "editml." + reportRuleID(issue),
+			})
+		}
+		report.Files = append(report.Files, file)
+	}
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(xml.Header), data...), '\n'), nil
+}
+
+// reportRuleID returns the rule ID of an issue in reports.
+func reportRuleID(issue Issue) string {
+	if issue.Code == "" {
+		return issueRuleID
+	}
+	return string(issue.Code)
+}
+
+// reportRuleIDs returns the rule IDs of the issues of files, sorted.
+func reportRuleIDs(files []FileIssues) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, f := range files {
+		for _, issue := range f.Issues {
+			if id := reportRuleID(issue); !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,136 @@
+// report_test.go
+// package editml_test contains unit tests for SARIF and checkstyle issue reports.
+package editml
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// reportIssuesOf returns the issues of transforming an EditML source to the Clean View.
+func reportIssuesOf(source string) []Issue {
+	doc, issues := ProcessDocument(source)
+	_, transformIssues := TransformDocument(doc, ProfileCleanView, nil)
+	return append(issues, transformIssues...)
+}
+
+// TestSARIF tests the rules, levels, paths and regions of a SARIF report.
+func TestSARIF(t *testing.T) {
+	issues := reportIssuesOf("Intro.\n{cp~x~T} {mv:T}\n")
+	issues = append(issues, Issue{Message: "No position", Severity: SeverityWarning})
+	data, err := SARIF([]FileIssues{{Path: "chapters/one.md", Issues: issues}, {Path: "two.md"}})
+	if err != nil {
+		t.Fatalf("SARIF: %v", err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			ColumnKind string `json:"columnKind"`
+			Results    []struct {
+				RuleID    string `json:"ruleId"`
+				RuleIndex int    `json:"ruleIndex"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region *sarifRegion `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("SARIF output is not valid JSON: %v\n%s", err, data)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("SARIF: unexpected log:\n%s", data)
+	}
+	run := log.Runs[0]
+	if run.ColumnKind != "unicodeCodePoints" {
+		t.Errorf("SARIF: columnKind = %q", run.ColumnKind)
+	}
+	if rules := run.Tool.Driver.Rules; len(rules) != 2 || rules[0].ID != "editml" || rules[1].ID != "operation-mismatch" {
+		t.Errorf("SARIF: unexpected rules %+v", rules)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("SARIF: expected 2 results, got %d:\n%s", len(run.Results), data)
+	}
+
+	mismatch := run.Results[0]
+	if mismatch.RuleID != "operation-mismatch" || mismatch.RuleIndex != 1 || mismatch.Level != "error" {
+		t.Errorf("SARIF: unexpected result %+v", mismatch)
+	}
+	location := mismatch.Locations[0].PhysicalLocation
+	if location.ArtifactLocation.URI != "chapters/one.md" {
+		t.Errorf("SARIF: uri = %q", location.ArtifactLocation.URI)
+	}
+	if want := (sarifRegion{StartLine: 2, StartColumn: 10, EndLine: 2, EndColumn: 16}); location.Region == nil || *location.Region != want {
+		t.Errorf("SARIF: region = %+v, want %+v", location.Region, want)
+	}
+
+	unpositioned := run.Results[1]
+	if unpositioned.RuleID != "editml" || unpositioned.Level != "warning" || unpositioned.Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("SARIF: unexpected result %+v", unpositioned)
+	}
+}
+
+// TestCheckstyle tests the checkstyle XML report.
+func TestCheckstyle(t *testing.T) {
+	issues := reportIssuesOf("{cp~x~T} {mv:T}")
+	issues = append(issues, Issue{Message: `Say "hi" & <bye>`, Severity: SeverityWarning, Code: IssueUnknownEditor})
+	data, err := Checkstyle([]FileIssues{{Path: "one.md", Issues: issues}, {Path: "two.md"}})
+	if err != nil {
+		t.Fatalf("Checkstyle: %v", err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<checkstyle version="4.3">
+  <file name="one.md">
+    <error line="1" column="10" severity="error" message="Transformation error: move target &#34;T&#34; does not match copy source" source="editml.operation-mismatch"></error>
+    <error line="0" severity="warning" message="Say &#34;hi&#34; &amp; &lt;bye&gt;" source="editml.unknown-editor"></error>
+  </file>
+  <file name="two.md"></file>
+</checkstyle>
+`
+	if string(data) != expected {
+		t.Errorf("Checkstyle:\nExpected:\n%s\nGot:\n%s", expected, data)
+	}
+}
+
+// TestExceedsThreshold tests the severity thresholds used to fail CI jobs.
+func TestExceedsThreshold(t *testing.T) {
+	warnings := []Issue{{Severity: SeverityWarning}}
+	errors := []Issue{{Severity: SeverityWarning}, {Severity: SeverityError}}
+	cases := []struct {
+		threshold string
+		issues    []Issue
+		want      bool
+	}{
+		{"error", nil, false},
+		{"error", warnings, false},
+		{"error", errors, true},
+		{"warning", warnings, true},
+		{"none", errors, false},
+	}
+	for _, c := range cases {
+		threshold, err := ParseSeverityThreshold(c.threshold)
+		if err != nil {
+			t.Fatalf("ParseSeverityThreshold(%q): %v", c.threshold, err)
+		}
+		if got := ExceedsThreshold(c.issues, threshold); got != c.want {
+			t.Errorf("ExceedsThreshold(%v, %q) = %v, want %v", c.issues, c.threshold, got, c.want)
+		}
+	}
+	if _, err := ParseSeverityThreshold("fatal"); err == nil {
+		t.Errorf("ParseSeverityThreshold(%q): expected an error", "fatal")
+	}
+}
@@ -0,0 +1,68 @@
+// sourcemap_test.go
+// package editml_test contains unit tests for node positions and Clean View source maps.
+package editml
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCleanViewSourceMap tests that every verbatim segment of the source map
+// points at identical input text, with and without cleanup.
+func TestCleanViewSourceMap(t *testing.T) {
+	inputBytes, err := os.ReadFile(filepath.Join("testdata", "multiline.md"))
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+	inputText := string(inputBytes)
+	doc, _ := ProcessDocument(inputText)
+
+	for _, cleanup := range []bool{false, true} {
+		output, sourceMap, issues := TransformCleanViewWithSourceMap(doc, CleanViewOptions{Cleanup: cleanup})
+		if len(issues) > 0 {
+			t.Fatalf("TransformCleanViewWithSourceMap returned unexpected issues: %v", issues)
+		}
+		if len(sourceMap) == 0 {
+			t.Fatalf("TransformCleanViewWithSourceMap (cleanup=%v) returned an empty source map", cleanup)
+		}
+		covered := 0
+		for _, seg := range sourceMap {
+			if seg.Generated {
+				continue
+			}
+			got, want := output[seg.OutStart:seg.OutEnd], inputText[seg.SrcStart:seg.SrcEnd]
+			if got != want {
+				t.Errorf("cleanup=%v: segment %+v maps %q to %q", cleanup, seg, got, want)
+			}
+			covered += seg.OutEnd - seg.OutStart
+		}
+		if covered != len(output) {
+			t.Errorf("cleanup=%v: source map covers %d of %d output bytes", cleanup, covered, len(output))
+		}
+	}
+}
+
+// TestNonASCIIPositions tests that spans and the source map count bytes, not
+// runes, for input with multi-byte characters and debug comments.
+func TestNonASCIIPositions(t *testing.T) {
+	inputText := "%% note\nCafé {+été+ws} naïve.\n"
+	doc, _ := ProcessDocument(inputText)
+	if len(doc.Nodes) != 3 || len(doc.Spans) != 3 {
+		t.Fatalf("ProcessDocument returned %d nodes and %d spans, want 3", len(doc.Nodes), len(doc.Spans))
+	}
+	span := doc.Spans[1]
+	if got, want := inputText[span.Start.Offset:span.End.Offset], "{+été+ws}"; got != want {
+		t.Errorf("addition span covers %q, want %q", got, want)
+	}
+	if span.Start.Line != 2 || span.Start.Column != 6 || span.End.Column != 15 {
+		t.Errorf("addition span = %+v, want line 2, columns 6 to 15", span)
+	}
+
+	output, sourceMap, _ := TransformCleanViewWithSourceMap(doc, CleanViewOptions{})
+	for _, seg := range sourceMap {
+		if got, want := output[seg.OutStart:seg.OutEnd], inputText[seg.SrcStart:seg.SrcEnd]; !seg.Generated && got != want {
+			t.Errorf("segment %+v maps %q to %q", seg, got, want)
+		}
+	}
+}
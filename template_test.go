@@ -0,0 +1,76 @@
+// template_test.go
+// package editml_test contains unit tests for the template profile.
+package editml
+
+import (
+	htmltemplate "html/template"
+	"path/filepath"
+	"testing"
+	texttemplate "text/template"
+
+	"github.com/verkaro/editml-go/transformer"
+)
+
+// TestTemplateProfileFile tests the template profile with a text/template file.
+func TestTemplateProfileFile(t *testing.T) {
+	inputText := "Hi {+there+ws}, {-old-jd} text {>why<xy}. {m~blk {+x+}~T}{m:T}"
+	expectedOutput := "Hi [ADD ws: there], [DEL jd: old] text [NOTE xy: why]. [MOVED T: blk [ADD: x]]"
+
+	doc, _ := ProcessDocument(inputText)
+	output, issues := TransformDocument(doc, ProfileTemplate, ProfileOptions{"file": filepath.Join("testdata", "review.tmpl")})
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument returned unexpected issues: %v", issues)
+	}
+	if output != expectedOutput {
+		t.Errorf("template profile: output = %q, want %q", output, expectedOutput)
+	}
+}
+
+// TestTemplateProfileRequiresFile tests that the template profile reports a missing file option.
+func TestTemplateProfileRequiresFile(t *testing.T) {
+	doc, _ := ProcessDocument("text")
+	if _, issues := TransformDocument(doc, ProfileTemplate, nil); len(issues) != 1 {
+		t.Errorf("template profile without file: issues = %v, want one error", issues)
+	}
+}
+
+// TestTemplateRendererPositions tests that templates receive node positions.
+func TestTemplateRendererPositions(t *testing.T) {
+	set := texttemplate.Must(texttemplate.New("pos").Parse(
+		`{{define "addition"}}<{{.Kind}}@{{.Start.Line}}:{{.Start.Column}}>{{end}}`))
+	doc, _ := ProcessDocument("%% comment\nline one\nsay {+hi+}")
+
+	resolved, err := transformer.ResolveDocument(doc)
+	if err != nil {
+		t.Fatalf("ResolveDocument returned error: %v", err)
+	}
+	output, err := transformer.Render(resolved, transformer.NewTemplateRenderer(set))
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if expected := "line one\nsay <addition@3:5>"; output != expected {
+		t.Errorf("template positions: output = %q, want %q", output, expected)
+	}
+}
+
+// TestTemplateRendererHTML tests that html/template sets escape node data once.
+func TestTemplateRendererHTML(t *testing.T) {
+	set := htmltemplate.Must(htmltemplate.New("review").Parse(
+		`{{define "addition"}}<ins class="ed-{{.EditorID}}">{{.Content}}</ins>{{end}}` +
+			`{{define "copy-target"}}<div>{{.Block}}</div>{{end}}` +
+			`{{define "document"}}<body>{{.Body}}</body>{{end}}`))
+	doc, _ := ProcessDocument("a < b {+& c+ws} {cp~x {+<y>+}~T}{cp:T}")
+
+	resolved, err := transformer.ResolveDocument(doc)
+	if err != nil {
+		t.Fatalf("ResolveDocument returned error: %v", err)
+	}
+	output, err := transformer.Render(resolved, transformer.NewTemplateRenderer(set))
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	expected := `<body>a &lt; b <ins class="ed-ws">&amp; c</ins> x <ins class="ed-">&lt;y&gt;</ins><div>x <ins class="ed-">&lt;y&gt;</ins></div></body>`
+	if output != expected {
+		t.Errorf("HTML template: output = %q, want %q", output, expected)
+	}
+}
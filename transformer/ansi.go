@@ -0,0 +1,157 @@
+// transformer/ansi.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// ANSI SGR sequences used by the terminal profile.
+const (
+	ansiReset     = "\x1b[0m"
+	ansiAddition  = "\x1b[32;4m" // Green, underlined.
+	ansiDeletion  = "\x1b[31;9m" // Red, struck through.
+	ansiComment   = "\x1b[2m"    // Dim.
+	ansiHighlight = "\x1b[7m"    // Inverse.
+	ansiLabel     = "\x1b[36m"   // Cyan, for structural labels.
+	ansiWarning   = "\x1b[33m"   // Yellow, for unresolved markup.
+	ansiError     = "\x1b[31;1m" // Bold red, for structural conflicts.
+)
+
+// ANSIOptions configures the terminal profile.
+type ANSIOptions struct {
+	// Color enables ANSI escape sequences. Without color, edits are shown
+	// with plain-text markers such as [+added+] and [-deleted-].
+	Color bool
+}
+
+// ANSIRenderer renders a document for review in a terminal: additions green
+// and underlined, deletions red and struck through, comments dimmed with their
+// editor ID, highlights inverse, and structural operations labelled with their
+// tags.
+type ANSIRenderer struct {
+	opts ANSIOptions
+	sb   strings.Builder
+}
+
+// NewANSIRenderer returns a Renderer for the terminal profile.
+func NewANSIRenderer(opts ANSIOptions) *ANSIRenderer {
+	return &ANSIRenderer{opts: opts}
+}
+
+// Text writes plain text, with control characters made visible.
+func (r *ANSIRenderer) Text(w *Walker, n model.TextNode) {
+	r.sb.WriteString(sanitizeTerminal(n.Text))
+}
+
+// InlineEdit writes the edit content with the style of its type.
+func (r *ANSIRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	switch n.EditType {
+	case model.EditTypeAddition:
+		r.styled(ansiAddition, "[+", n.Content, "+]")
+	case model.EditTypeDeletion:
+		r.styled(ansiDeletion, "[-", n.Content, "-]")
+	case model.EditTypeComment:
+		// Comments are bracketed in both modes so they do not read as prose.
+		content := n.Content
+		if n.EditorID != "" {
+			content = n.EditorID + ": " + content
+		}
+		r.styled(ansiComment, "", "[# "+content+"]", "")
+	case model.EditTypeHighlight:
+		r.styled(ansiHighlight, "[=", n.Content, "=]")
+	}
+}
+
+// StructuralSource labels the source. A moved block is shown at its target;
+// a copied block is shown in place.
+func (r *ANSIRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	switch {
+	case src == nil || src.HasBlockError():
+		r.styled(ansiError, "", fmt.Sprintf("{%s~%s~%s}", n.Operation, n.BlockContent, n.Tag), "")
+	case !src.Resolved:
+		r.styled(ansiWarning, "", fmt.Sprintf("{%s~%s~%s}", n.Operation, n.BlockContent, n.Tag), "")
+	case n.Operation == model.OperationMove:
+		r.styled(ansiLabel, "", fmt.Sprintf("[moved to %s]", n.Tag), "")
+	default:
+		r.styled(ansiLabel, "", fmt.Sprintf("[copy %s]", n.Tag), "")
+		w.WalkBlock(src)
+	}
+}
+
+// StructuralTarget labels the target and shows the moved or copied block.
+func (r *ANSIRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	switch {
+	case src == nil:
+		r.styled(ansiWarning, "", fmt.Sprintf("{%s:%s}", n.Operation, n.Tag), "")
+	case src.Node.Operation != n.Operation || src.HasBlockError():
+		r.styled(ansiError, "", fmt.Sprintf("{%s:%s}", n.Operation, n.Tag), "")
+	case n.Operation == model.OperationMove:
+		r.styled(ansiLabel, "", fmt.Sprintf("[moved from %s]", n.Tag), "")
+		w.WalkBlock(src)
+	default:
+		r.styled(ansiLabel, "", fmt.Sprintf("[copied from %s]", n.Tag), "")
+		w.WalkBlock(src)
+	}
+}
+
+// Result returns the rendered terminal output.
+func (r *ANSIRenderer) Result() (string, error) {
+	return r.sb.String(), nil
+}
+
+// styled writes text with the given SGR style when color is enabled, or
+// between the plain-text markers open and close otherwise. Control characters
+// in text are made visible first (see sanitizeTerminal). Styles are reset
+// before each line break and reapplied after it, so a multiline edit does not
+// bleed into pagers that render line by line.
+func (r *ANSIRenderer) styled(style, open, text, close string) {
+	text = sanitizeTerminal(text)
+	if !r.opts.Color {
+		r.sb.WriteString(open + text + close)
+		return
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			r.sb.WriteByte('\n')
+		}
+		if line == "" {
+			continue
+		}
+		r.sb.WriteString(style + line + ansiReset)
+	}
+}
+
+// sanitizeTerminal returns text with the control characters that a terminal
+// would interpret replaced by visible ones, so that a document cannot inject
+// escape sequences: C0 controls other than line feed and tab, and DEL, in
+// caret notation ("^[" for ESC), and C1 controls, which some terminals read
+// as escape sequences too, as U+FFFD.
+func sanitizeTerminal(text string) string {
+	if !strings.ContainsFunc(text, isTerminalControl) {
+		return text
+	}
+	var sb strings.Builder
+	for _, c := range text {
+		switch {
+		case !isTerminalControl(c):
+			sb.WriteRune(c)
+		case c < 0x20 || c == 0x7f:
+			sb.WriteByte('^')
+			sb.WriteByte(byte(c) ^ 0x40)
+		default:
+			sb.WriteRune('\uFFFD')
+		}
+	}
+	return sb.String()
+}
+
+// isTerminalControl reports whether c is a control character that
+// sanitizeTerminal replaces.
+func isTerminalControl(c rune) bool {
+	return (c < 0x20 && c != '\n' && c != '\t') || (c >= 0x7f && c <= 0x9f)
+}
@@ -0,0 +1,302 @@
+// transformer/changes.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// Output formats and groupings of the changes profile.
+const (
+	ChangesFormatText     = "text"
+	ChangesFormatMarkdown = "markdown"
+
+	ChangesGroupOrder  = "order"  // One list in document order.
+	ChangesGroupEditor = "editor" // One list per editor ID.
+)
+
+// ChangesOptions configures the changes profile.
+type ChangesOptions struct {
+	Format       string // ChangesFormatText (default) or ChangesFormatMarkdown.
+	Group        string // ChangesGroupOrder (default) or ChangesGroupEditor.
+	ContextWords int    // Words of context shown before and after each change.
+}
+
+// ChangeEntry is one edit listed by the changes profile.
+type ChangeEntry struct {
+	Number   int    // 1-based position of the entry in document order.
+	Kind     string // Edit type, or "move-source", "copy-target" etc. for structural nodes.
+	EditorID string // Editor ID of an inline edit, if any.
+	Line     int    // Line of the edit in the input, or 0 if unknown.
+	Content  string // Edit content, or block content of a structural source.
+	Tag      string // Tag of a structural node.
+
+	// Status is the resolution status of a structural node; empty for inline edits.
+	Status ResolutionStatus
+
+	Before string // Up to ContextWords words of text preceding the edit.
+	After  string // Up to ContextWords words of text following the edit.
+}
+
+// ChangesRenderer produces a review sheet: one entry per inline edit and
+// structural operation, with surrounding context, so that an editor can
+// triage suggestions without reading the whole manuscript.
+//
+// Edits inside a structural block are listed once, at the block's source.
+type ChangesRenderer struct {
+	opts    ChangesOptions
+	entries []ChangeEntry
+	text    strings.Builder  // All text seen so far, for context.
+	pending []pendingContext // Entries still collecting After context.
+}
+
+// pendingContext is an entry still collecting After context. The entry is
+// identified by its index in entries, which does not change when pending
+// entries are dropped.
+type pendingContext struct {
+	entry int // Index of the entry in ChangesRenderer.entries.
+	mark  int // Text length from which the context starts, or -1 if it has not started yet.
+}
+
+// DefaultContextWords is the context size used when ChangesOptions.ContextWords is 0.
+const DefaultContextWords = 5
+
+// changesContextBytes is the most text searched per word of context, so that
+// collecting context stays fast in long documents.
+const changesContextBytes = 64
+
+// NewChangesRenderer returns a Renderer for the changes profile.
+func NewChangesRenderer(opts ChangesOptions) (*ChangesRenderer, error) {
+	switch opts.Format {
+	case "":
+		opts.Format = ChangesFormatText
+	case ChangesFormatText, ChangesFormatMarkdown:
+	default:
+		return nil, fmt.Errorf("invalid format %q, want %s or %s", opts.Format, ChangesFormatText, ChangesFormatMarkdown)
+	}
+	switch opts.Group {
+	case "":
+		opts.Group = ChangesGroupOrder
+	case ChangesGroupOrder, ChangesGroupEditor:
+	default:
+		return nil, fmt.Errorf("invalid grouping %q, want %s or %s", opts.Group, ChangesGroupOrder, ChangesGroupEditor)
+	}
+	if opts.ContextWords <= 0 {
+		opts.ContextWords = DefaultContextWords
+	}
+	return &ChangesRenderer{opts: opts}, nil
+}
+
+// Text records text as context for the surrounding entries.
+func (r *ChangesRenderer) Text(w *Walker, n model.TextNode) {
+	r.text.WriteString(n.Text)
+}
+
+// InlineEdit adds an entry for the edit.
+func (r *ChangesRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	r.add(w, ChangeEntry{Kind: string(n.EditType), EditorID: n.EditorID, Content: n.Content})
+}
+
+// StructuralSource adds an entry for the source, followed by entries for the
+// edits inside its block.
+func (r *ChangesRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	r.add(w, ChangeEntry{
+		Kind:    n.Operation + "-source",
+		Content: n.BlockContent,
+		Tag:     n.Tag,
+		Status:  w.Document().SourceStatus(n.Tag),
+	})
+	// The source's trailing context starts after its own block text.
+	entry := len(r.entries) - 1
+	r.setMark(entry, -1)
+	w.WalkBlock(src)
+	r.setMark(entry, r.text.Len())
+}
+
+// StructuralTarget adds an entry for the target.
+func (r *ChangesRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	r.add(w, ChangeEntry{
+		Kind:   n.Operation + "-target",
+		Tag:    n.Tag,
+		Status: w.Document().TargetStatus(n),
+	})
+}
+
+// Entries returns the collected entries in document order. It is valid after
+// the walk has completed.
+func (r *ChangesRenderer) Entries() []ChangeEntry {
+	r.flushContext()
+	return r.entries
+}
+
+// Result returns the formatted review sheet.
+func (r *ChangesRenderer) Result() (string, error) {
+	entries := r.Entries()
+	groups := []changeGroup{{entries: entries}}
+	if r.opts.Group == ChangesGroupEditor {
+		groups = groupChangesByEditor(entries)
+	}
+
+	var sb strings.Builder
+	if r.opts.Format == ChangesFormatMarkdown {
+		fmt.Fprintf(&sb, "# Changes (%d)\n", len(entries))
+		for _, g := range groups {
+			if g.title != "" {
+				fmt.Fprintf(&sb, "\n## %s\n", g.title)
+			}
+			sb.WriteString("\n")
+			for _, e := range g.entries {
+				fmt.Fprintf(&sb, "%d. %s  \n   %s\n", e.Number, changeHeading(e, "**", "`"), changeExcerpt(e, "**", "**"))
+			}
+		}
+		return sb.String(), nil
+	}
+
+	fmt.Fprintf(&sb, "Changes (%d)\n", len(entries))
+	for _, g := range groups {
+		if g.title != "" {
+			fmt.Fprintf(&sb, "\n== %s ==\n", g.title)
+		}
+		for _, e := range g.entries {
+			fmt.Fprintf(&sb, "\n#%d %s\n    %s\n", e.Number, changeHeading(e, "", ""), changeExcerpt(e, ">>", "<<"))
+		}
+	}
+	return sb.String(), nil
+}
+
+// add appends an entry with its position and leading context.
+func (r *ChangesRenderer) add(w *Walker, e ChangeEntry) {
+	r.flushContext()
+	e.Number = len(r.entries) + 1
+	if span, ok := w.Span(); ok {
+		e.Line = span.Start.Line
+	}
+	text := r.text.String()
+	window := text[max(0, len(text)-r.opts.ContextWords*changesContextBytes):]
+	if i := strings.IndexFunc(window, unicode.IsSpace); i >= 0 && len(window) < len(text) {
+		window = window[i:] // Drop the word the window cuts.
+	}
+	e.Before = lastWords(window, r.opts.ContextWords)
+	r.entries = append(r.entries, e)
+	r.pending = append(r.pending, pendingContext{entry: len(r.entries) - 1, mark: r.text.Len()})
+}
+
+// setMark sets the text length from which the trailing context of the
+// pending entry with index entry starts.
+func (r *ChangesRenderer) setMark(entry, mark int) {
+	for i := range r.pending {
+		if r.pending[i].entry == entry {
+			r.pending[i].mark = mark
+			return
+		}
+	}
+}
+
+// flushContext fills in the trailing context of pending entries from the
+// text seen since they were added. Entries stay pending until enough words
+// have been seen.
+func (r *ChangesRenderer) flushContext() {
+	text := r.text.String()
+	pending := r.pending[:0]
+	for _, p := range r.pending {
+		if p.mark < 0 { // Context not started yet.
+			pending = append(pending, p)
+			continue
+		}
+		after := text[p.mark:]
+		size := r.opts.ContextWords * changesContextBytes
+		full := len(after) >= size
+		after = after[:min(len(after), size)]
+		r.entries[p.entry].After = firstWords(after, r.opts.ContextWords)
+		if !full && len(strings.Fields(after)) <= r.opts.ContextWords {
+			pending = append(pending, p)
+		}
+	}
+	r.pending = pending
+}
+
+// changeGroup is a titled list of entries.
+type changeGroup struct {
+	title   string
+	entries []ChangeEntry
+}
+
+// groupChangesByEditor groups entries by editor ID, sorted, with
+// unattributed entries last.
+func groupChangesByEditor(entries []ChangeEntry) []changeGroup {
+	byEditor := make(map[string][]ChangeEntry)
+	var editors []string
+	for _, e := range entries {
+		if _, seen := byEditor[e.EditorID]; !seen && e.EditorID != "" {
+			editors = append(editors, e.EditorID)
+		}
+		byEditor[e.EditorID] = append(byEditor[e.EditorID], e)
+	}
+	sort.Strings(editors)
+	var groups []changeGroup
+	for _, id := range editors {
+		groups = append(groups, changeGroup{title: "Editor " + id, entries: byEditor[id]})
+	}
+	if unattributed := byEditor[""]; len(unattributed) > 0 {
+		groups = append(groups, changeGroup{title: "Unattributed", entries: unattributed})
+	}
+	return groups
+}
+
+// changeHeading formats the kind, editor, tag, status and line of an entry.
+func changeHeading(e ChangeEntry, strong, code string) string {
+	heading := strong + e.Kind + strong
+	if e.EditorID != "" {
+		heading += " by " + code + e.EditorID + code
+	}
+	if e.Tag != "" {
+		heading += " " + code + e.Tag + code
+	}
+	if e.Status != "" {
+		heading += " (" + string(e.Status) + ")"
+	}
+	if e.Line > 0 {
+		heading += fmt.Sprintf(", line %d", e.Line)
+	}
+	return heading
+}
+
+// changeExcerpt formats an entry's content between its context, on one line.
+func changeExcerpt(e ChangeEntry, open, close string) string {
+	content := strings.Join(strings.Fields(e.Content), " ")
+	if content == "" {
+		content = "{" + strings.SplitN(e.Kind, "-", 2)[0] + ":" + e.Tag + "}"
+	}
+	parts := []string{}
+	if e.Before != "" {
+		parts = append(parts, "…"+e.Before)
+	}
+	parts = append(parts, open+content+close)
+	if e.After != "" {
+		parts = append(parts, e.After+"…")
+	}
+	return strings.Join(parts, " ")
+}
+
+// lastWords returns the last n whitespace-separated words of text.
+func lastWords(text string, n int) string {
+	words := strings.Fields(text)
+	if len(words) > n {
+		words = words[len(words)-n:]
+	}
+	return strings.Join(words, " ")
+}
+
+// firstWords returns the first n whitespace-separated words of text.
+func firstWords(text string, n int) string {
+	words := strings.Fields(text)
+	if len(words) > n {
+		words = words[:n]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,151 @@
+// transformer/cleanup.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+// cleanupMask computes which bytes of text to drop so that whitespace and
+// punctuation left behind by removed content reads naturally. Only bytes
+// adjacent to one of the boundaries are considered; a boundary is an output
+// offset where content was removed (a deletion, a comment, a moved-away block)
+// or where a moved or copied block was inserted. Everything else is left alone.
+//
+// At each boundary, the whitespace run on its left and the one on its right
+// are examined:
+//   - If both runs contain line breaks (a removed paragraph), the run with
+//     fewer line breaks is dropped, keeping indentation of the next line.
+//   - If only one run contains a line break, the other run is dropped, so no
+//     trailing or leading spaces are left on the line.
+//   - At the start or end of the output, the run facing the edge is dropped.
+//   - Before closing punctuation (. , ; : ! ? ) ]) or after an opening
+//     bracket, both runs are dropped ("is ." becomes "is.").
+//   - Otherwise two runs of spaces collapse into the left one.
+//
+// Finally, a separator (, ; :) left directly before other punctuation once
+// the whitespace between them is dropped is dropped too ("Hello, ." becomes
+// "Hello."), and so is the first of two equal separators ("a,, b"). A
+// separator that touched the removed content is kept ("3:{-00-}, ok"
+// becomes "3:, ok").
+func cleanupMask(text string, boundaries []int) []bool {
+	drop := make([]bool, len(text))
+
+	// prevKept returns the index of the last kept byte before i, or -1.
+	prevKept := func(i int) int {
+		for i--; i >= 0 && drop[i]; i-- {
+		}
+		return i
+	}
+	// nextKept returns the index of the first kept byte at or after i, or len(text).
+	nextKept := func(i int) int {
+		for ; i < len(text) && drop[i]; i++ {
+		}
+		return i
+	}
+	dropAll := func(indices []int) {
+		for _, i := range indices {
+			drop[i] = true
+		}
+	}
+
+	for _, b := range boundaries {
+		if b < 0 || b > len(text) {
+			continue
+		}
+		// Collect the kept whitespace on both sides, in text order.
+		var left, right []int
+		l := prevKept(b)
+		for ; l >= 0 && isCleanupSpace(text[l]); l = prevKept(l) {
+			left = append([]int{l}, left...)
+		}
+		r := nextKept(b)
+		for ; r < len(text) && isCleanupSpace(text[r]); r = nextKept(r + 1) {
+			right = append(right, r)
+		}
+		nl, nr := countNewlines(text, left), countNewlines(text, right)
+
+		switch {
+		case nl > 0 && nr > 0:
+			if nl >= nr {
+				// Drop the right run up to and including its last line break.
+				last := 0
+				for k, i := range right {
+					if text[i] == '\n' {
+						last = k
+					}
+				}
+				dropAll(right[:last+1])
+			} else {
+				// Drop the left run from its first line break on.
+				for k, i := range left {
+					if text[i] == '\n' || text[i] == '\r' {
+						dropAll(left[k:])
+						break
+					}
+				}
+			}
+		case nl > 0:
+			dropAll(right)
+		case nr > 0:
+			dropAll(left)
+		case l < 0:
+			dropAll(right)
+		case r >= len(text):
+			dropAll(left)
+		case isClosingPunct(text[r]) || isOpeningPunct(text[l]):
+			dropAll(left)
+			dropAll(right)
+		case len(left) > 0 && len(right) > 0:
+			dropAll(right)
+		}
+
+		// A separator left directly before punctuation is an artifact too,
+		// if whitespace between them was dropped or it is the same
+		// separator twice. One that touched the removed content, as in
+		// "3:{-00-},", is the author's and stays.
+		if nl == 0 && nr == 0 {
+			spaced := len(left) > 0
+			l, r = prevKept(b), nextKept(b)
+			if l >= 0 && r < len(text) && isSeparatorPunct(text[l]) && (isClosingPunct(text[r]) || isSeparatorPunct(text[r])) &&
+				(spaced || text[l] == text[r]) {
+				drop[l] = true
+			}
+		}
+	}
+	return drop
+}
+
+// isCleanupSpace reports whether c is whitespace that cleanup may remove.
+func isCleanupSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// countNewlines counts the line feeds among the bytes of text at indices.
+func countNewlines(text string, indices []int) int {
+	n := 0
+	for _, i := range indices {
+		if text[i] == '\n' {
+			n++
+		}
+	}
+	return n
+}
+
+// isClosingPunct reports whether c is punctuation that attaches to the
+// preceding word.
+func isClosingPunct(c byte) bool {
+	switch c {
+	case '.', ',', ';', ':', '!', '?', ')', ']':
+		return true
+	}
+	return false
+}
+
+// isOpeningPunct reports whether c is punctuation that attaches to the
+// following word.
+func isOpeningPunct(c byte) bool {
+	return c == '(' || c == '['
+}
+
+// isSeparatorPunct reports whether c separates clauses and is redundant when
+// followed by other punctuation.
+func isSeparatorPunct(c byte) bool {
+	return c == ',' || c == ';' || c == ':'
+}
@@ -0,0 +1,145 @@
+// transformer/criticmarkup.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// CriticMarkupRenderer exports a document as CriticMarkup: additions become
+// {++text++}, deletions {--text--}, highlights {==text==} and comments
+// {>>text<<}. A deletion directly followed by an addition from the same
+// editor becomes a substitution, {~~old~>new~~}. Editor IDs are written as
+// attributions: "{>>@id<<}" after an edit, "{>>@id: text<<}" for a comment.
+//
+// CriticMarkup has no moves or copies. A resolved move is exported as a
+// deletion of the block at its source and an addition of the block at each
+// target; a copy keeps its block at the source. Edits inside a block that is
+// added or deleted this way are applied (additions) or undone (deletions),
+// since CriticMarkup cannot nest. Every structural node, and every content
+// that CriticMarkup cannot delimit, is reported by Diagnostics.
+type CriticMarkupRenderer struct {
+	sb          strings.Builder
+	pending     *model.InlineEditNode // Deletion that may start a substitution.
+	diagnostics []Diagnostic
+}
+
+// NewCriticMarkupRenderer returns a Renderer for the "criticmarkup" profile.
+func NewCriticMarkupRenderer() *CriticMarkupRenderer {
+	return &CriticMarkupRenderer{}
+}
+
+// Text writes text unchanged.
+func (r *CriticMarkupRenderer) Text(w *Walker, n model.TextNode) {
+	r.flush()
+	r.sb.WriteString(n.Text)
+}
+
+// InlineEdit writes the CriticMarkup form of an edit. Deletions are held back
+// until the next node shows whether they start a substitution.
+func (r *CriticMarkupRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	if n.EditType == model.EditTypeAddition && r.pending != nil && r.pending.EditorID == n.EditorID {
+		r.check(w, r.pending.Content, "~>", "substitution")
+		r.check(w, n.Content, "~~}", "substitution")
+		fmt.Fprintf(&r.sb, "{~~%s~>%s~~}%s", r.pending.Content, n.Content, criticAttribution(n.EditorID))
+		r.pending = nil
+		return
+	}
+	r.flush()
+	switch n.EditType {
+	case model.EditTypeAddition:
+		r.check(w, n.Content, "++}", "addition")
+		r.sb.WriteString("{++" + n.Content + "++}" + criticAttribution(n.EditorID))
+	case model.EditTypeDeletion:
+		r.check(w, n.Content, "--}", "deletion")
+		r.pending = &n
+	case model.EditTypeHighlight:
+		r.check(w, n.Content, "==}", "highlight")
+		r.sb.WriteString("{==" + n.Content + "==}" + criticAttribution(n.EditorID))
+	case model.EditTypeComment:
+		r.check(w, n.Content, "<<}", "comment")
+		if n.EditorID != "" {
+			r.sb.WriteString("{>>@" + n.EditorID + ": " + n.Content + "<<}")
+		} else {
+			r.sb.WriteString("{>>" + n.Content + "<<}")
+		}
+	}
+}
+
+// StructuralSource deletes the block of a resolved move, and writes the block
+// in place otherwise.
+func (r *CriticMarkupRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	r.flush()
+	if n.Operation == model.OperationMove && src != nil && src.Block != nil && w.Document().SourceStatus(n.Tag) == StatusResolved {
+		r.warn(w, n.Tag, fmt.Sprintf("CriticMarkup has no moves: move source %q exported as a deletion", n.Tag))
+		text, _ := Render(src.Block, NewOriginalViewRenderer())
+		r.sb.WriteString("{--" + text + "--}")
+		return
+	}
+	r.warn(w, n.Tag, fmt.Sprintf("CriticMarkup has no moves or copies: %s source %q kept in place", n.Operation, n.Tag))
+	if src == nil || src.Block == nil {
+		r.sb.WriteString(n.BlockContent)
+		return
+	}
+	w.WalkBlock(src)
+	r.flush()
+}
+
+// StructuralTarget adds the block of a resolved move or copy, and writes
+// nothing otherwise.
+func (r *CriticMarkupRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	r.flush()
+	if src == nil || src.Block == nil || w.Document().TargetStatus(n) != StatusResolved {
+		r.warn(w, n.Tag, fmt.Sprintf("unresolved %s target %q omitted from CriticMarkup", n.Operation, n.Tag))
+		return
+	}
+	r.warn(w, n.Tag, fmt.Sprintf("CriticMarkup has no moves or copies: %s target %q exported as an addition", n.Operation, n.Tag))
+	text, _ := Render(src.Block, NewCleanViewRenderer(CleanViewOptions{}))
+	r.sb.WriteString("{++" + text + "++}")
+}
+
+// Result returns the CriticMarkup text.
+func (r *CriticMarkupRenderer) Result() (string, error) {
+	r.flush()
+	return r.sb.String(), nil
+}
+
+// Diagnostics returns warnings for the parts of the document that CriticMarkup
+// cannot represent exactly, in document order.
+func (r *CriticMarkupRenderer) Diagnostics() []Diagnostic {
+	return r.diagnostics
+}
+
+// flush writes a held-back deletion.
+func (r *CriticMarkupRenderer) flush() {
+	if r.pending == nil {
+		return
+	}
+	r.sb.WriteString("{--" + r.pending.Content + "--}" + criticAttribution(r.pending.EditorID))
+	r.pending = nil
+}
+
+// check reports content that contains the delimiter ending its construct.
+func (r *CriticMarkupRenderer) check(w *Walker, content, delimiter, kind string) {
+	if strings.Contains(content, delimiter) {
+		r.warn(w, "", fmt.Sprintf("%s content contains %q, which CriticMarkup cannot escape", kind, delimiter))
+	}
+}
+
+// warn records a warning for the node being rendered.
+func (r *CriticMarkupRenderer) warn(w *Walker, tag, message string) {
+	d := Diagnostic{Code: CodeUnsupportedConstruct, Tag: tag, Message: message, Warning: true}
+	d.Span, d.HasSpan = w.Span()
+	r.diagnostics = append(r.diagnostics, d)
+}
+
+// criticAttribution returns the comment that attributes an edit to editorID.
+func criticAttribution(editorID string) string {
+	if editorID == "" {
+		return ""
+	}
+	return "{>>@" + editorID + "<<}"
+}
@@ -0,0 +1,96 @@
+// transformer/diagnostics.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// DiagnosticCode is a machine-readable identifier for a structural problem.
+type DiagnosticCode string
+
+// Codes of the problems reported in ResolvedDocument.Diagnostics.
+const (
+	// CodeBlockParseError: the block content of a source could not be parsed.
+	CodeBlockParseError DiagnosticCode = "block-parse-error"
+	// CodeBlockResolveError: the block content of a source parsed, but its
+	// own structural edits could not be resolved.
+	CodeBlockResolveError DiagnosticCode = "block-resolve-error"
+	// CodeOperationMismatch: a target's operation differs from its source's,
+	// e.g. {move:T} for {copy~...~T} (Spec 3.4.3).
+	CodeOperationMismatch DiagnosticCode = "operation-mismatch"
+	// CodeDuplicateSource: several sources share a tag (Spec 3.4.3). The
+	// transformation is aborted; see ConflictError.
+	CodeDuplicateSource DiagnosticCode = "duplicate-source"
+	// CodeMultipleMoveTargets: a move has several targets (Spec 3.4.3). The
+	// transformation is aborted; see ConflictError.
+	CodeMultipleMoveTargets DiagnosticCode = "multiple-move-targets"
+	// CodeUnsupportedConstruct: the output format has no equivalent for a
+	// construct, which was approximated (see DiagnosticReporter).
+	CodeUnsupportedConstruct DiagnosticCode = "unsupported-construct"
+)
+
+// Diagnostic describes a structural construct that cannot be transformed as
+// written. Renderers render such constructs with a fallback (see
+// CleanViewOptions.Fallback) instead of failing.
+type Diagnostic struct {
+	Code    DiagnosticCode
+	Tag     string     // Tag of the structural node.
+	Message string     // Human-readable description.
+	Span    model.Span // Input range of the node, if HasSpan.
+	HasSpan bool
+	Warning bool // The output is usable, but less precise than the input.
+}
+
+// DiagnosticReporter is implemented by renderers that report problems of
+// their own, such as constructs their output format cannot represent.
+// Transform adds them to the diagnostics of the resolved document.
+type DiagnosticReporter interface {
+	Diagnostics() []Diagnostic
+}
+
+// ConflictError is the error ResolveDocument returns for the conflicts that
+// Spec 3.4.3 requires to abort the transformation. Its diagnostics describe
+// every occurrence of each conflicting tag, in input order.
+type ConflictError struct {
+	Diagnostics []Diagnostic
+}
+
+// Error lists the conflicting tags.
+func (e *ConflictError) Error() string {
+	var conflicts []string
+	seen := make(map[[2]string]bool)
+	for _, d := range e.Diagnostics {
+		key := [2]string{string(d.Code), d.Tag}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		switch d.Code {
+		case CodeDuplicateSource:
+			conflicts = append(conflicts, fmt.Sprintf("duplicate source tag %q", d.Tag))
+		case CodeMultipleMoveTargets:
+			conflicts = append(conflicts, fmt.Sprintf("multiple move targets for tag %q", d.Tag))
+		}
+	}
+	return "structural conflict: " + strings.Join(conflicts, "; ")
+}
+
+// diagnose appends a diagnostic for the node at index i of rd.
+func (rd *ResolvedDocument) diagnose(i int, code DiagnosticCode, tag, message string) {
+	d := Diagnostic{Code: code, Tag: tag, Message: message}
+	d.Span, d.HasSpan = rd.Span(i)
+	rd.Diagnostics = append(rd.Diagnostics, d)
+}
+
+// sortDiagnostics orders diagnostics by input position. Diagnostics without a
+// span keep their relative order.
+func sortDiagnostics(ds []Diagnostic) {
+	sort.SliceStable(ds, func(i, j int) bool {
+		return ds[i].HasSpan && ds[j].HasSpan && ds[i].Span.Start.Offset < ds[j].Span.Start.Offset
+	})
+}
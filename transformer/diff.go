@@ -0,0 +1,504 @@
+// transformer/diff.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// Output formats of the diff profile.
+const (
+	DiffFormatUnified    = "unified"      // Standard unified diff, applicable with patch.
+	DiffFormatSideBySide = "side-by-side" // Two plain-text columns.
+)
+
+// Defaults of the diff profile.
+const (
+	DefaultDiffContext = 3  // Lines of unchanged context around each hunk.
+	DefaultDiffWidth   = 80 // Total width of the side-by-side view.
+)
+
+// DiffOptions configures the diff profile.
+type DiffOptions struct {
+	Format  string // DiffFormatUnified (default) or DiffFormatSideBySide.
+	Context int    // Lines of context around each hunk; DefaultDiffContext if 0, none if negative.
+	Width   int    // Width of the side-by-side view; DefaultDiffWidth if 0.
+
+	// FromLabel and ToLabel name the two texts in the unified diff header.
+	// They default to "original" and "clean"; set both to the manuscript's
+	// file name to produce a patch that applies to it.
+	FromLabel, ToLabel string
+
+	// Clean configures the Clean View the original text is compared with.
+	Clean CleanViewOptions
+}
+
+// DiffRenderer compares the text before the edits (the "original" profile)
+// with the text after them (the Clean View) line by line. Each hunk is
+// labelled with the editor IDs of the additions and deletions that produced
+// it; labels need positions, so documents must come from
+// editml.ProcessDocument.
+//
+// The renderer only records the document during the walk and renders both
+// views in Result.
+type DiffRenderer struct {
+	opts DiffOptions
+	doc  *ResolvedDocument
+}
+
+// NewDiffRenderer returns a Renderer for the diff profile.
+func NewDiffRenderer(opts DiffOptions) (*DiffRenderer, error) {
+	switch opts.Format {
+	case "":
+		opts.Format = DiffFormatUnified
+	case DiffFormatUnified, DiffFormatSideBySide:
+	default:
+		return nil, fmt.Errorf("invalid format %q, want %s or %s", opts.Format, DiffFormatUnified, DiffFormatSideBySide)
+	}
+	switch {
+	case opts.Context == 0:
+		opts.Context = DefaultDiffContext
+	case opts.Context < 0:
+		opts.Context = 0
+	}
+	if opts.Width == 0 {
+		opts.Width = DefaultDiffWidth
+	}
+	if opts.Width < 10 {
+		return nil, fmt.Errorf("invalid width %d, want at least 10", opts.Width)
+	}
+	if opts.FromLabel == "" {
+		opts.FromLabel = "original"
+	}
+	if opts.ToLabel == "" {
+		opts.ToLabel = "clean"
+	}
+	return &DiffRenderer{opts: opts}, nil
+}
+
+// Text records the document being walked.
+func (r *DiffRenderer) Text(w *Walker, n model.TextNode) { r.doc = w.Document() }
+
+// InlineEdit records the document being walked.
+func (r *DiffRenderer) InlineEdit(w *Walker, n model.InlineEditNode) { r.doc = w.Document() }
+
+// StructuralSource records the document being walked.
+func (r *DiffRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	r.doc = w.Document()
+}
+
+// StructuralTarget records the document being walked.
+func (r *DiffRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	r.doc = w.Document()
+}
+
+// Result renders the original text and the Clean View and returns their
+// diff. It returns an empty string if the texts are identical.
+func (r *DiffRenderer) Result() (string, error) {
+	if r.doc == nil {
+		return "", nil
+	}
+	original := NewOriginalViewRenderer()
+	from, err := Render(r.doc, original)
+	if err != nil {
+		return "", err
+	}
+	clean := NewCleanViewRenderer(r.opts.Clean)
+	to, err := Render(r.doc, clean)
+	if err != nil {
+		return "", err
+	}
+
+	a, b := splitLines(from), splitLines(to)
+	ops := diffStrings(a, b)
+	hunks := groupHunks(ops, r.opts.Context)
+	if len(hunks) == 0 {
+		return "", nil
+	}
+	edits := collectEditRanges(r.doc)
+	fromLines, toLines := lineOffsets(a), lineOffsets(b)
+	fromMap, toMap := original.SourceMap(), clean.SourceMap()
+	for i := range hunks {
+		h := &hunks[i]
+		editors := make(map[string]bool)
+		for _, op := range h.ops {
+			switch op.Kind {
+			case diffDelete:
+				edits.label(editors, fromMap, fromLines[op.A], fromLines[op.A+1], model.EditTypeDeletion)
+			case diffInsert:
+				edits.label(editors, toMap, toLines[op.B], toLines[op.B+1], model.EditTypeAddition)
+			}
+		}
+		for id := range editors {
+			h.editors = append(h.editors, id)
+		}
+		sort.Strings(h.editors)
+	}
+
+	var sb strings.Builder
+	if r.opts.Format == DiffFormatSideBySide {
+		writeSideBySide(&sb, a, b, hunks, r.opts.Width)
+	} else {
+		writeUnified(&sb, a, b, hunks, r.opts.FromLabel, r.opts.ToLabel)
+	}
+	return sb.String(), nil
+}
+
+// diffKind is the kind of a diffOp.
+type diffKind byte
+
+const (
+	diffEqual  diffKind = '='
+	diffDelete diffKind = '-'
+	diffInsert diffKind = '+'
+)
+
+// diffOp is one step of an edit script turning a into b. A and B are the
+// positions in a and b at which the step applies: a[A] is the element kept or
+// deleted, b[B] the element kept or inserted.
+type diffOp struct {
+	Kind diffKind
+	A, B int
+}
+
+// diffStrings returns a shortest edit script turning a into b, using Myers'
+// O(ND) algorithm. Deletions come before insertions within each change. The
+// trace keeps, for each step d, only the diagonals -d-1 to d+1 that the
+// backward walk reads, so it takes O(D²) memory rather than O(D(N+M)).
+func diffStrings(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int // trace[d][k+d+1] is v[offset+k] before step d.
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	// Walk the trace backwards from (n, m) to recover the script.
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v, base := trace[d], d+1
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[base+k-1] < v[base+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[base+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{Kind: diffEqual, A: x, B: y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{Kind: diffInsert, A: x, B: y})
+		} else {
+			x--
+			ops = append(ops, diffOp{Kind: diffDelete, A: x, B: y})
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return orderChanges(ops)
+}
+
+// orderChanges moves the deletions of each run of changes before its
+// insertions, as diff tools print them, and renumbers their positions.
+func orderChanges(ops []diffOp) []diffOp {
+	for start := 0; start < len(ops); {
+		if ops[start].Kind == diffEqual {
+			start++
+			continue
+		}
+		a, b := ops[start].A, ops[start].B
+		var deleted, inserted int
+		end := start
+		for ; end < len(ops) && ops[end].Kind != diffEqual; end++ {
+			if ops[end].Kind == diffDelete {
+				deleted++
+			} else {
+				inserted++
+			}
+		}
+		for i := 0; i < deleted; i++ {
+			ops[start+i] = diffOp{Kind: diffDelete, A: a + i, B: b}
+		}
+		for i := 0; i < inserted; i++ {
+			ops[start+deleted+i] = diffOp{Kind: diffInsert, A: a + deleted, B: b + i}
+		}
+		start = end
+	}
+	return ops
+}
+
+// diffHunk is a run of changes together with its surrounding context.
+type diffHunk struct {
+	ops          []diffOp
+	aStart, aLen int // 0-based start and length of the hunk in a.
+	bStart, bLen int // 0-based start and length of the hunk in b.
+	editors      []string
+}
+
+// groupHunks splits an edit script into hunks, each with up to context
+// unchanged elements on either side. Changes separated by at most 2*context
+// unchanged elements share a hunk.
+func groupHunks(ops []diffOp, context int) []diffHunk {
+	var hunks []diffHunk
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == diffEqual {
+			i++
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		last := i // Last change in the hunk.
+		for j := i + 1; j < len(ops) && j-last-1 <= 2*context; j++ {
+			if ops[j].Kind != diffEqual {
+				last = j
+			}
+		}
+		end := last + 1 + context
+		if end > len(ops) {
+			end = len(ops)
+		}
+		h := diffHunk{ops: ops[start:end], aStart: ops[start].A, bStart: ops[start].B}
+		for _, op := range h.ops {
+			if op.Kind != diffInsert {
+				h.aLen++
+			}
+			if op.Kind != diffDelete {
+				h.bLen++
+			}
+		}
+		hunks = append(hunks, h)
+		i = end
+	}
+	return hunks
+}
+
+// splitLines splits text into lines, keeping each line's terminating newline.
+// The last line has no newline if text does not end with one.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineOffsets returns the byte offset of the start of each line, followed by
+// the offset of the end of the text.
+func lineOffsets(lines []string) []int {
+	offsets := make([]int, len(lines)+1)
+	for i, line := range lines {
+		offsets[i+1] = offsets[i] + len(line)
+	}
+	return offsets
+}
+
+// editRange is the input range of an attributed addition or deletion.
+type editRange struct {
+	start, end int
+	editType   model.EditType
+	editorID   string
+}
+
+// editRanges lists the attributed edits of a document.
+type editRanges []editRange
+
+// collectEditRanges returns the input ranges of every addition and deletion
+// with an editor ID in doc and in its structural blocks.
+func collectEditRanges(doc *ResolvedDocument) editRanges {
+	var ranges editRanges
+	for i, node := range doc.Nodes {
+		n, ok := node.(model.InlineEditNode)
+		if !ok || n.EditorID == "" {
+			continue
+		}
+		if span, known := doc.Span(i); known {
+			ranges = append(ranges, editRange{span.Start.Offset, span.End.Offset, n.EditType, n.EditorID})
+		}
+	}
+	for _, src := range doc.Sources {
+		if src.Block != nil {
+			ranges = append(ranges, collectEditRanges(src.Block)...)
+		}
+	}
+	return ranges
+}
+
+// label adds to editors the IDs of the edits of type editType that produced
+// output bytes [outStart, outEnd), according to the output's source map.
+// Generated output is not attributed.
+func (ranges editRanges) label(editors map[string]bool, m SourceMap, outStart, outEnd int, editType model.EditType) {
+	for _, seg := range m {
+		if seg.Generated || seg.OutEnd <= outStart || seg.OutStart >= outEnd {
+			continue
+		}
+		srcStart := seg.SrcStart + max(outStart-seg.OutStart, 0)
+		srcEnd := seg.SrcEnd - max(seg.OutEnd-outEnd, 0)
+		for _, e := range ranges {
+			if e.editType == editType && e.start < srcEnd && srcStart < e.end {
+				editors[e.editorID] = true
+			}
+		}
+	}
+}
+
+// hunkHeader formats the "@@ -a,b +c,d @@" line of a hunk, followed by its
+// editor IDs.
+func hunkHeader(h diffHunk) string {
+	header := fmt.Sprintf("@@ -%s +%s @@", unifiedRange(h.aStart, h.aLen), unifiedRange(h.bStart, h.bLen))
+	if len(h.editors) > 0 {
+		header += " " + strings.Join(h.editors, ", ")
+	}
+	return header
+}
+
+// unifiedRange formats a hunk range the way GNU diff does: the length is
+// omitted when it is 1, and an empty range starts at the line before it.
+func unifiedRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// writeUnified writes hunks as a unified diff of a and b.
+func writeUnified(sb *strings.Builder, a, b []string, hunks []diffHunk, fromLabel, toLabel string) {
+	fmt.Fprintf(sb, "--- %s\n+++ %s\n", fromLabel, toLabel)
+	for _, h := range hunks {
+		sb.WriteString(hunkHeader(h) + "\n")
+		for _, op := range h.ops {
+			line := b[op.B]
+			if op.Kind != diffInsert {
+				line = a[op.A]
+			}
+			prefix := byte(op.Kind)
+			if op.Kind == diffEqual {
+				prefix = ' '
+			}
+			sb.WriteByte(prefix)
+			sb.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+}
+
+// writeSideBySide writes hunks as two columns, the original text on the left
+// and the Clean View on the right. The gutter shows '|' for changed lines,
+// '<' for deleted and '>' for inserted ones. Long lines wrap within their
+// column.
+func writeSideBySide(sb *strings.Builder, a, b []string, hunks []diffHunk, width int) {
+	column := (width - 3) / 2
+	for i, h := range hunks {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(hunkHeader(h) + "\n")
+		for j := 0; j < len(h.ops); {
+			if h.ops[j].Kind == diffEqual {
+				writeSideBySideRow(sb, a[h.ops[j].A], b[h.ops[j].B], ' ', column)
+				j++
+				continue
+			}
+			var deleted, inserted []string
+			for ; j < len(h.ops) && h.ops[j].Kind != diffEqual; j++ {
+				if h.ops[j].Kind == diffDelete {
+					deleted = append(deleted, a[h.ops[j].A])
+				} else {
+					inserted = append(inserted, b[h.ops[j].B])
+				}
+			}
+			for k := 0; k < len(deleted) || k < len(inserted); k++ {
+				switch {
+				case k >= len(inserted):
+					writeSideBySideRow(sb, deleted[k], "", '<', column)
+				case k >= len(deleted):
+					writeSideBySideRow(sb, "", inserted[k], '>', column)
+				default:
+					writeSideBySideRow(sb, deleted[k], inserted[k], '|', column)
+				}
+			}
+		}
+	}
+}
+
+// writeSideBySideRow writes one line of each text, wrapped to column runes.
+func writeSideBySideRow(sb *strings.Builder, left, right string, gutter byte, column int) {
+	lefts, rights := wrapColumn(left, column), wrapColumn(right, column)
+	for i := 0; i < len(lefts) || i < len(rights); i++ {
+		var l, r string
+		if i < len(lefts) {
+			l = lefts[i]
+		}
+		if i < len(rights) {
+			r = rights[i]
+		}
+		row := l + strings.Repeat(" ", column-utf8.RuneCountInString(l)) + " " + string(gutter) + " " + r
+		sb.WriteString(strings.TrimRight(row, " ") + "\n")
+	}
+}
+
+// wrapColumn splits a line into chunks of at most column runes. Tabs are
+// expanded to four spaces and the line terminator is dropped.
+func wrapColumn(line string, column int) []string {
+	line = strings.TrimRight(line, "\r\n")
+	line = strings.ReplaceAll(line, "\t", "    ")
+	var chunks []string
+	for utf8.RuneCountInString(line) > column {
+		cut := 0
+		for i := 0; i < column; i++ {
+			_, size := utf8.DecodeRuneInString(line[cut:])
+			cut += size
+		}
+		chunks = append(chunks, line[:cut])
+		line = line[cut:]
+	}
+	return append(chunks, line)
+}
@@ -0,0 +1,373 @@
+// transformer/docx.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// DOCXOptions configures the DOCX export profile.
+type DOCXOptions struct {
+	// Author is the author of edits without an editor ID; "EditML" if empty.
+	Author string
+
+	// Date is the date recorded on every tracked change and comment. The zero
+	// value omits the date.
+	Date time.Time
+}
+
+// DOCXRenderer exports a document as a Word document (.docx) with native
+// tracked changes, so that edits can be accepted or rejected in Word. Result
+// returns the bytes of the ZIP package.
+//
+//   - Additions become insertions (w:ins) and deletions become deletions
+//     (w:del), authored by their editor ID.
+//   - Comments become Word comments by their editor. A comment directly
+//     following another edit is anchored to that edit's text; otherwise it is
+//     anchored at its position.
+//   - Highlights become yellow highlighting.
+//   - A resolved move becomes a tracked move (w:moveFrom/w:moveTo): the
+//     block's original text is moved from the source, and its Clean View
+//     arrives at each target. Word has no tracked copies, so a copy target
+//     becomes an insertion of the block's Clean View, reported by Diagnostics.
+//
+// Text is split into paragraphs at blank lines; single line breaks become
+// w:br. Tracking is switched on in the document settings, so further changes
+// made in Word are tracked too.
+type DOCXRenderer struct {
+	opts DOCXOptions
+
+	paras    []*docxParagraph
+	nextID   int          // Next revision ID.
+	anchor   *docxAnchor  // Start of the last edit, if nothing followed it.
+	comments bytes.Buffer // Contents of word/comments.xml.
+	comment  int          // Next comment ID.
+
+	diagnostics []Diagnostic
+}
+
+// docxParagraph is one w:p element under construction.
+type docxParagraph struct {
+	mark   string   // Run properties of the paragraph mark, for a tracked paragraph break.
+	pieces []string // Content XML, in order.
+}
+
+// docxAnchor is the position of a piece of content.
+type docxAnchor struct {
+	para, piece int
+}
+
+// docxParagraphBreak matches the blank lines between paragraphs.
+var docxParagraphBreak = regexp.MustCompile(`\n(?:[ \t]*\n)+`)
+
+// NewDOCXRenderer returns a Renderer for the DOCX export profile.
+func NewDOCXRenderer(opts DOCXOptions) *DOCXRenderer {
+	if opts.Author == "" {
+		opts.Author = "EditML"
+	}
+	return &DOCXRenderer{opts: opts, paras: []*docxParagraph{{}}, nextID: 1}
+}
+
+// Text writes plain runs.
+func (r *DOCXRenderer) Text(w *Walker, n model.TextNode) {
+	r.write(n.Text, docxPlain, "")
+	r.anchor = nil
+}
+
+// InlineEdit writes a tracked change, a comment or highlighted runs.
+func (r *DOCXRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	author := r.author(n.EditorID)
+	switch n.EditType {
+	case model.EditTypeAddition:
+		r.anchor = r.write(n.Content, docxInsert, author)
+	case model.EditTypeDeletion:
+		r.anchor = r.write(n.Content, docxDelete, author)
+	case model.EditTypeHighlight:
+		r.anchor = r.write(n.Content, docxHighlight, "")
+	case model.EditTypeComment:
+		r.addComment(n.Content, author)
+		r.anchor = nil
+	}
+}
+
+// StructuralSource writes the block of a resolved move as moved away, and the
+// block in place otherwise.
+func (r *DOCXRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	r.anchor = nil
+	if src == nil || src.Block == nil {
+		r.write(n.BlockContent, docxPlain, "")
+		return
+	}
+	if n.Operation != model.OperationMove || w.Document().SourceStatus(n.Tag) != StatusResolved {
+		w.WalkBlock(src)
+		r.anchor = nil
+		return
+	}
+	text, _ := Render(src.Block, NewOriginalViewRenderer())
+	r.writeMove(text, docxMoveFrom, n.Tag)
+}
+
+// StructuralTarget writes the block of a resolved move as moved here, or that
+// of a resolved copy as inserted. Unresolved targets render nothing.
+func (r *DOCXRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	r.anchor = nil
+	if src == nil || src.Block == nil || w.Document().TargetStatus(n) != StatusResolved {
+		return
+	}
+	text, _ := Render(src.Block, NewCleanViewRenderer(CleanViewOptions{}))
+	if n.Operation == model.OperationMove {
+		r.writeMove(text, docxMoveTo, n.Tag)
+		return
+	}
+	r.write(text, docxInsert, r.opts.Author)
+	d := Diagnostic{
+		Code:    CodeUnsupportedConstruct,
+		Tag:     n.Tag,
+		Message: fmt.Sprintf("Word has no tracked copies: copy target %q exported as an insertion", n.Tag),
+		Warning: true,
+	}
+	d.Span, d.HasSpan = w.Span()
+	r.diagnostics = append(r.diagnostics, d)
+}
+
+// Result returns the .docx package.
+func (r *DOCXRenderer) Result() (string, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, part := range []struct{ name, content string }{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxPackageRels},
+		{"word/_rels/document.xml.rels", docxDocumentRels},
+		{"word/document.xml", r.documentXML()},
+		{"word/comments.xml", docxXMLHeader + `<w:comments xmlns:w="` + docxNamespace + `">` + r.comments.String() + `</w:comments>`},
+		{"word/settings.xml", docxXMLHeader + `<w:settings xmlns:w="` + docxNamespace + `"><w:trackRevisions/></w:settings>`},
+	} {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: part.name, Method: zip.Deflate})
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write([]byte(part.content)); err != nil {
+			return "", err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Diagnostics returns warnings for the copies exported as insertions.
+func (r *DOCXRenderer) Diagnostics() []Diagnostic {
+	return r.diagnostics
+}
+
+// docxKind selects how write wraps runs.
+type docxKind int
+
+const (
+	docxPlain docxKind = iota
+	docxInsert
+	docxDelete
+	docxHighlight
+	docxMoveFrom
+	docxMoveTo
+)
+
+// write appends text as runs of the given kind, starting a new paragraph at
+// every blank line. Tracked content gets one revision element per paragraph,
+// and the paragraph marks between them are tracked as well. It returns the
+// position of the first piece written, or nil if text is empty.
+func (r *DOCXRenderer) write(text string, kind docxKind, author string) *docxAnchor {
+	var first *docxAnchor
+	for i, segment := range docxParagraphBreak.Split(text, -1) {
+		if i > 0 {
+			r.breakParagraph(kind, author)
+		}
+		if segment == "" {
+			continue
+		}
+		p := r.paras[len(r.paras)-1]
+		if first == nil {
+			first = &docxAnchor{len(r.paras) - 1, len(p.pieces)}
+		}
+		p.pieces = append(p.pieces, r.wrap(segment, kind, author))
+	}
+	return first
+}
+
+// wrap returns the XML for one paragraph's worth of content.
+func (r *DOCXRenderer) wrap(text string, kind docxKind, author string) string {
+	switch kind {
+	case docxInsert:
+		return fmt.Sprintf("<w:ins %s>%s</w:ins>", r.revision(author), docxRun(text, "", false))
+	case docxDelete:
+		return fmt.Sprintf("<w:del %s>%s</w:del>", r.revision(author), docxRun(text, "", true))
+	case docxMoveFrom:
+		return fmt.Sprintf("<w:moveFrom %s>%s</w:moveFrom>", r.revision(author), docxRun(text, "", true))
+	case docxMoveTo:
+		return fmt.Sprintf("<w:moveTo %s>%s</w:moveTo>", r.revision(author), docxRun(text, "", false))
+	case docxHighlight:
+		return docxRun(text, `<w:rPr><w:highlight w:val="yellow"/></w:rPr>`, false)
+	}
+	return docxRun(text, "", false)
+}
+
+// breakParagraph starts a new paragraph. For tracked content, the mark of the
+// paragraph being ended is tracked too, so that accepting or rejecting the
+// change also joins or splits the paragraphs.
+func (r *DOCXRenderer) breakParagraph(kind docxKind, author string) {
+	p := r.paras[len(r.paras)-1]
+	switch kind {
+	case docxInsert, docxMoveTo:
+		p.mark = fmt.Sprintf("<w:ins %s/>", r.revision(author))
+	case docxDelete, docxMoveFrom:
+		p.mark = fmt.Sprintf("<w:del %s/>", r.revision(author))
+	}
+	r.paras = append(r.paras, &docxParagraph{})
+}
+
+// writeMove writes one side of a tracked move, between named range markers
+// that pair the source with its targets.
+func (r *DOCXRenderer) writeMove(text string, kind docxKind, tag string) {
+	element := "w:moveToRange"
+	if kind == docxMoveFrom {
+		element = "w:moveFromRange"
+	}
+	id := r.nextID
+	r.nextID++
+	r.append(fmt.Sprintf(`<%sStart w:id="%d" w:name="%s" %s/>`, element, id, docxEscape(tag), r.attribution(r.opts.Author)))
+	r.write(text, kind, r.opts.Author)
+	r.append(fmt.Sprintf(`<%sEnd w:id="%d"/>`, element, id))
+}
+
+// addComment writes a comment to comments.xml and anchors it to the last edit
+// or, if there is none, to the current position.
+func (r *DOCXRenderer) addComment(text, author string) {
+	id := r.comment
+	r.comment++
+	start := fmt.Sprintf(`<w:commentRangeStart w:id="%d"/>`, id)
+	if a := r.anchor; a != nil {
+		p := r.paras[a.para]
+		p.pieces = append(p.pieces[:a.piece], append([]string{start}, p.pieces[a.piece:]...)...)
+	} else {
+		r.append(start)
+	}
+	r.append(fmt.Sprintf(`<w:commentRangeEnd w:id="%d"/><w:r><w:commentReference w:id="%d"/></w:r>`, id, id))
+
+	fmt.Fprintf(&r.comments, `<w:comment w:id="%d" %s w:initials="%s">`, id, r.attribution(author), docxEscape(author))
+	for _, segment := range docxParagraphBreak.Split(text, -1) {
+		r.comments.WriteString("<w:p>" + docxRun(segment, "", false) + "</w:p>")
+	}
+	r.comments.WriteString("</w:comment>")
+}
+
+// append adds a piece to the current paragraph.
+func (r *DOCXRenderer) append(piece string) {
+	p := r.paras[len(r.paras)-1]
+	p.pieces = append(p.pieces, piece)
+}
+
+// revision returns the attributes of a new revision element.
+func (r *DOCXRenderer) revision(author string) string {
+	id := r.nextID
+	r.nextID++
+	return fmt.Sprintf(`w:id="%d" %s`, id, r.attribution(author))
+}
+
+// attribution returns the author and date attributes of a change.
+func (r *DOCXRenderer) attribution(author string) string {
+	attrs := fmt.Sprintf(`w:author="%s"`, docxEscape(author))
+	if !r.opts.Date.IsZero() {
+		attrs += fmt.Sprintf(` w:date="%s"`, r.opts.Date.UTC().Format(time.RFC3339))
+	}
+	return attrs
+}
+
+// author returns the change author for an editor ID.
+func (r *DOCXRenderer) author(editorID string) string {
+	if editorID == "" {
+		return r.opts.Author
+	}
+	return editorID
+}
+
+// documentXML returns the contents of word/document.xml.
+func (r *DOCXRenderer) documentXML() string {
+	var sb strings.Builder
+	sb.WriteString(docxXMLHeader + `<w:document xmlns:w="` + docxNamespace + `"><w:body>`)
+	for _, p := range r.paras {
+		sb.WriteString("<w:p>")
+		if p.mark != "" {
+			sb.WriteString("<w:pPr><w:rPr>" + p.mark + "</w:rPr></w:pPr>")
+		}
+		for _, piece := range p.pieces {
+			sb.WriteString(piece)
+		}
+		sb.WriteString("</w:p>")
+	}
+	sb.WriteString("<w:sectPr/></w:body></w:document>")
+	return sb.String()
+}
+
+// docxRun returns a run holding text, with line breaks as w:br and tabs as
+// w:tab. Deleted text uses w:delText.
+func docxRun(text, props string, deleted bool) string {
+	element := "w:t"
+	if deleted {
+		element = "w:delText"
+	}
+	var sb strings.Builder
+	sb.WriteString("<w:r>" + props)
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			sb.WriteString("<w:br/>")
+		}
+		for j, chunk := range strings.Split(line, "\t") {
+			if j > 0 {
+				sb.WriteString("<w:tab/>")
+			}
+			if chunk != "" {
+				sb.WriteString(`<` + element + ` xml:space="preserve">` + docxEscape(chunk) + `</` + element + `>`)
+			}
+		}
+	}
+	sb.WriteString("</w:r>")
+	return sb.String()
+}
+
+// docxEscape escapes text for use in XML content and attribute values.
+func docxEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+const (
+	docxNamespace = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	docxXMLHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+	docxContentTypes = docxXMLHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+		`<Override PartName="/word/comments.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.comments+xml"/>` +
+		`<Override PartName="/word/settings.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.settings+xml"/>` +
+		`</Types>`
+
+	docxPackageRels = docxXMLHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+		`</Relationships>`
+
+	docxDocumentRels = docxXMLHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments" Target="comments.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/settings" Target="settings.xml"/>` +
+		`</Relationships>`
+)
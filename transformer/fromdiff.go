@@ -0,0 +1,398 @@
+// transformer/fromdiff.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// FromDiffOptions configures MarkupFromDiff.
+type FromDiffOptions struct {
+	// EditorID is written on every generated edit; none if empty.
+	EditorID string
+
+	// ProtectBraces keeps every opening curly brace of the unchanged text in
+	// a highlight, where it can be escaped, so that the text cannot read as
+	// markup. Without it such text is written as is, which is only exact if
+	// it does not happen to form an EditML construct.
+	ProtectBraces bool
+
+	// DetectMoves compares the texts paragraph by paragraph first, and writes
+	// paragraphs that were deleted in one place and added, identical or
+	// nearly identical, in another as a move, with the changes inside the
+	// paragraph as edits in the moved block.
+	DetectMoves bool
+}
+
+// MoveSimilarity is the similarity, between 0 and 1, from which a deleted and
+// an added paragraph are taken for a moved one when detecting moves. It is
+// the share of their words, spaces and punctuation that are unchanged.
+const MoveSimilarity = 0.75
+
+// MarkupFromDiff returns EditML source that turns original into revised: the
+// two texts are compared word by word (Myers' algorithm, on words,
+// whitespace and punctuation), the unchanged text is written as is, and
+// every change becomes a deletion of the original words followed by an
+// addition of the revised ones, with their content escaped (Spec 3.1).
+// Replacements separated by a single space are joined, so that a rewritten
+// phrase reads as one replacement.
+//
+// With opts.DetectMoves, paragraphs (text between blank lines) are matched
+// first. A deleted paragraph whose text reappears elsewhere with a
+// similarity of at least MoveSimilarity becomes a move source, {move~...~mN},
+// holding the word-level edits that turn it into the added paragraph, and
+// the added paragraph becomes its target, {move:mN}. Tags are numbered in
+// order of the sources. The blank lines around moved paragraphs stay outside
+// the moves.
+//
+// The source is written so that parsing it gives back both texts exactly:
+//
+//   - lines of unchanged text starting with "%%" keep the "%%" in a
+//     highlight, so that they are not removed as debug comments (Spec 3.2.1);
+//   - edits are split where a line of their content would start with "%%";
+//   - a newline is added after a final newline, which the parser drops.
+//
+// Carriage returns before line feeds are dropped by the parser wherever they
+// appear, so the texts should use line feeds only.
+func MarkupFromDiff(original, revised string, opts FromDiffOptions) string {
+	w := fromDiffWriter{opts: opts, lineStart: true}
+	if opts.DetectMoves {
+		w.diffParagraphs(paragraphUnits(original), paragraphUnits(revised))
+	} else {
+		w.diff(diffTokens(original), diffTokens(revised))
+	}
+	if strings.HasSuffix(w.sb.String(), "\n") {
+		w.sb.WriteByte('\n')
+	}
+	return w.sb.String()
+}
+
+// fromDiffWriter writes the source produced by MarkupFromDiff.
+type fromDiffWriter struct {
+	opts      FromDiffOptions
+	sb        strings.Builder
+	lineStart bool // Whether the next byte written starts a line of the source.
+
+	// structural maps the placeholder tokens standing for moved paragraphs
+	// to the markup of their move sources and targets.
+	structural map[string]string
+	moves      int
+}
+
+// diff writes the markup turning the tokens a into the tokens b. Tokens
+// found in w.structural are written as their markup.
+func (w *fromDiffWriter) diff(a, b []string) {
+	ops := diffStrings(a, b)
+	var unchanged, deleted, inserted strings.Builder
+	flush := func() {
+		w.edit(model.EditTypeDeletion, deleted.String())
+		w.edit(model.EditTypeAddition, inserted.String())
+		deleted.Reset()
+		inserted.Reset()
+	}
+	for i, op := range ops {
+		switch op.Kind {
+		case diffEqual:
+			token := a[op.A]
+			// Join replacements separated by a single space into one.
+			if token == " " && deleted.Len() > 0 && inserted.Len() > 0 && replacementFollows(a, b, ops[i+1:], w.structural) {
+				deleted.WriteString(token)
+				inserted.WriteString(token)
+				continue
+			}
+			flush()
+			unchanged.WriteString(token)
+		case diffDelete, diffInsert:
+			w.text(unchanged.String())
+			unchanged.Reset()
+			var token string
+			if op.Kind == diffDelete {
+				token = a[op.A]
+			} else {
+				token = b[op.B]
+			}
+			if markup, ok := w.structural[token]; ok {
+				flush()
+				w.write(markup)
+			} else if op.Kind == diffDelete {
+				deleted.WriteString(token)
+			} else {
+				inserted.WriteString(token)
+			}
+		}
+	}
+	flush()
+	w.text(unchanged.String())
+}
+
+// replacementFollows reports whether ops starts with a run of changes that
+// deletes and inserts text, with no moved paragraphs.
+func replacementFollows(a, b []string, ops []diffOp, structural map[string]string) bool {
+	var deletes, inserts bool
+	for _, op := range ops {
+		switch op.Kind {
+		case diffEqual:
+			return deletes && inserts
+		case diffDelete:
+			if _, ok := structural[a[op.A]]; ok {
+				return false
+			}
+			deletes = true
+		case diffInsert:
+			if _, ok := structural[b[op.B]]; ok {
+				return false
+			}
+			inserts = true
+		}
+	}
+	return deletes && inserts
+}
+
+// paragraphUnit is a paragraph together with the blank lines that follow it.
+type paragraphUnit struct {
+	text, separator string
+}
+
+// paragraphUnits splits text into paragraphs at blank lines. The newline
+// ending the text is a separator too.
+func paragraphUnits(text string) []paragraphUnit {
+	var units []paragraphUnit
+	for text != "" {
+		loc := docxParagraphBreak.FindStringIndex(text)
+		if loc == nil {
+			last := strings.TrimRight(text, "\n")
+			units = append(units, paragraphUnit{last, text[len(last):]})
+			break
+		}
+		units = append(units, paragraphUnit{text[:loc[0]], text[loc[0]:loc[1]]})
+		text = text[loc[1]:]
+	}
+	return units
+}
+
+// diffParagraphs writes the markup turning the paragraphs a into the
+// paragraphs b. Unchanged paragraphs are written as is, and each run of
+// changed paragraphs is compared word by word, with moved paragraphs
+// standing in as placeholders for their move sources and targets.
+func (w *fromDiffWriter) diffParagraphs(a, b []paragraphUnit) {
+	// Paragraphs are compared by their text only, so that a paragraph that
+	// moves to or from the end of the text, where it has no blank lines
+	// after it, is still found unchanged; separators are compared apart.
+	keys := func(units []paragraphUnit) []string {
+		k := make([]string, len(units))
+		for i, u := range units {
+			k[i] = u.text
+		}
+		return k
+	}
+	ops := diffStrings(keys(a), keys(b))
+	sources, targets := w.detectMoves(a, b, ops)
+
+	for i := 0; i < len(ops); {
+		if op := ops[i]; op.Kind == diffEqual {
+			w.text(a[op.A].text)
+			if sa, sb := a[op.A].separator, b[op.B].separator; sa == sb {
+				w.text(sa)
+			} else {
+				w.diff(diffTokens(sa), diffTokens(sb))
+			}
+			i++
+			continue
+		}
+		var aTokens, bTokens []string
+		for ; i < len(ops) && ops[i].Kind != diffEqual; i++ {
+			if op := ops[i]; op.Kind == diffDelete {
+				aTokens = appendUnitTokens(aTokens, a[op.A], sources[op.A])
+			} else {
+				bTokens = appendUnitTokens(bTokens, b[op.B], targets[op.B])
+			}
+		}
+		w.diff(aTokens, bTokens)
+	}
+}
+
+// appendUnitTokens appends the tokens of a paragraph to tokens: its words,
+// or the placeholder standing for it if it was moved, then its separator.
+func appendUnitTokens(tokens []string, u paragraphUnit, placeholder string) []string {
+	if placeholder != "" {
+		tokens = append(tokens, placeholder)
+	} else {
+		tokens = append(tokens, diffTokens(u.text)...)
+	}
+	return append(tokens, diffTokens(u.separator)...)
+}
+
+// detectMoves pairs each deleted paragraph with the most similar added
+// paragraph outside its own run of changes, if they are similar enough, and
+// registers the markup of the resulting moves. It returns the placeholders
+// of the paired paragraphs, by index in a and in b.
+func (w *fromDiffWriter) detectMoves(a, b []paragraphUnit, ops []diffOp) (sources, targets map[int]string) {
+	sources, targets = make(map[int]string), make(map[int]string)
+	var deleted, inserted []int // Paragraph indexes, with their runs of changes.
+	var deletedRun, insertedRun []int
+	run := 0
+	for i, op := range ops {
+		if op.Kind == diffEqual {
+			continue
+		}
+		if i > 0 && ops[i-1].Kind == diffEqual {
+			run++
+		}
+		if op.Kind == diffDelete && hasWord(a[op.A].text) {
+			deleted, deletedRun = append(deleted, op.A), append(deletedRun, run)
+		} else if op.Kind == diffInsert && hasWord(b[op.B].text) {
+			inserted, insertedRun = append(inserted, op.B), append(insertedRun, run)
+		}
+	}
+
+	for i, ai := range deleted {
+		best, bestSimilarity := -1, MoveSimilarity
+		for j, bj := range inserted {
+			if insertedRun[j] == deletedRun[i] || targets[bj] != "" {
+				continue
+			}
+			if s := similarity(a[ai].text, b[bj].text); s >= bestSimilarity {
+				best, bestSimilarity = bj, s
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		if w.structural == nil {
+			w.structural = make(map[string]string)
+		}
+		w.moves++
+		tag := fmt.Sprintf("m%d", w.moves)
+		block := fromDiffWriter{opts: w.opts}
+		block.diff(diffTokens(a[ai].text), diffTokens(b[best].text))
+		// Placeholders contain a NUL byte, which is always a token of its own
+		// in the texts, so they cannot equal a token of either text.
+		sources[ai], targets[best] = "\x00<"+tag, "\x00>"+tag
+		w.structural[sources[ai]] = "{move~" + EscapeBlock(block.sb.String()) + "~" + tag + "}"
+		w.structural[targets[best]] = "{move:" + tag + "}"
+	}
+	return sources, targets
+}
+
+// similarity returns the share of the tokens of x and y that a word-level
+// diff keeps unchanged, between 0 and 1.
+func similarity(x, y string) float64 {
+	a, b := diffTokens(x), diffTokens(y)
+	if len(a)+len(b) == 0 {
+		return 1
+	}
+	var equal int
+	for _, op := range diffStrings(a, b) {
+		if op.Kind == diffEqual {
+			equal++
+		}
+	}
+	return float64(2*equal) / float64(len(a)+len(b))
+}
+
+// hasWord reports whether text contains a letter or digit.
+func hasWord(text string) bool {
+	return strings.IndexFunc(text, isWordRune) >= 0
+}
+
+// text writes unchanged text, keeping a "%%" starting a line, and opening
+// braces if opts.ProtectBraces is set, in highlights.
+func (w *fromDiffWriter) text(s string) {
+	for s != "" {
+		switch {
+		case w.lineStart && strings.HasPrefix(s, "%%"):
+			w.highlight("%%")
+			s = s[2:]
+		case w.opts.ProtectBraces && s[0] == '{':
+			w.highlight("{")
+			s = s[1:]
+		default:
+			end := len(s)
+			if w.opts.ProtectBraces {
+				if i := strings.IndexByte(s, '{'); i > 0 {
+					end = i
+				}
+			}
+			if i := strings.Index(s[:end], "\n%%"); i >= 0 {
+				end = i + 1
+			}
+			w.write(s[:end])
+			s = s[end:]
+		}
+	}
+}
+
+// highlight writes s as an unattributed highlight, which both views show as
+// plain text.
+func (w *fromDiffWriter) highlight(s string) {
+	w.write(InlineMarkup(model.InlineEditNode{EditType: model.EditTypeHighlight, Content: s}))
+}
+
+// edit writes an attributed addition or deletion of content.
+func (w *fromDiffWriter) edit(editType model.EditType, content string) {
+	w.write(EditMarkup(editType, content, w.opts.EditorID))
+}
+
+// EditMarkup returns the markup of an edit of content by editorID, with its
+// content escaped (Spec 3.1). The edit is split into several before every
+// line of content that starts with "%%", which would otherwise be removed as
+// a debug comment (Spec 3.2.1). Empty content has no markup.
+func EditMarkup(editType model.EditType, content, editorID string) string {
+	var sb strings.Builder
+	for content != "" {
+		end := len(content)
+		if i := strings.Index(content[1:], "\n%%"); i >= 0 {
+			end = i + 2
+		}
+		sb.WriteString(InlineMarkup(model.InlineEditNode{EditType: editType, Content: content[:end], EditorID: editorID}))
+		content = content[end:]
+	}
+	return sb.String()
+}
+
+// write writes s to the source.
+func (w *fromDiffWriter) write(s string) {
+	if s == "" {
+		return
+	}
+	w.sb.WriteString(s)
+	w.lineStart = s[len(s)-1] == '\n'
+}
+
+// diffTokens splits text into the tokens MarkupFromDiff compares: runs of
+// letters and digits, runs of spaces and tabs, line breaks, and single other
+// characters. The tokens concatenate to text.
+func diffTokens(text string) []string {
+	var tokens []string
+	for text != "" {
+		c, size := utf8.DecodeRuneInString(text)
+		end := size
+		switch {
+		case isWordRune(c):
+			for end < len(text) {
+				next, n := utf8.DecodeRuneInString(text[end:])
+				if !isWordRune(next) {
+					break
+				}
+				end += n
+			}
+		case c == ' ' || c == '\t':
+			for end < len(text) && (text[end] == ' ' || text[end] == '\t') {
+				end++
+			}
+		}
+		tokens = append(tokens, text[:end])
+		text = text[end:]
+	}
+	return tokens
+}
+
+// isWordRune reports whether c is part of a word.
+func isWordRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.Is(unicode.Mn, c)
+}
@@ -0,0 +1,316 @@
+// transformer/html.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// HTMLOptions configures the HTML preview profile.
+type HTMLOptions struct {
+	// Fragment omits the <html> wrapper and the style sheet, for embedding the
+	// preview in another page.
+	Fragment bool
+
+	// Title is the document title; "EditML Preview" if empty.
+	Title string
+}
+
+// HTMLRenderer renders the HTMLPreview profile (Spec 5.1, 6). Inline edits
+// become <ins>, <del> and <span> elements with editml-* classes and the editor
+// ID in a data-editor attribute. Text is split into numbered paragraphs at
+// blank lines.
+//
+// Every structural source and target gets an anchor and links to its
+// counterparts ("moved to ¶ 42", "moved from ¶ 7"), a tooltip previewing the
+// block's Clean View, and a class for its resolution status. Blocks are shown
+// at their source. A summary table of all tags follows the text.
+type HTMLRenderer struct {
+	opts   HTMLOptions
+	pieces []htmlPiece
+
+	para   int  // Number of the current paragraph.
+	inPara bool // Whether a <p> element is open.
+
+	tags    []string                // Structural tags in order of first appearance.
+	sources map[string]htmlAnchor   // Tag -> source.
+	targets map[string][]htmlAnchor // Tag -> targets, in document order.
+	status  map[string]ResolutionStatus
+	ops     map[string]string // Tag -> operation.
+}
+
+// htmlPiece is either literal output or a link resolved once the whole
+// document has been walked.
+type htmlPiece struct {
+	text string
+	link func() string
+}
+
+// htmlAnchor records where a structural node was rendered.
+type htmlAnchor struct {
+	id   string
+	para int
+}
+
+// htmlParagraphBreak matches the blank lines between paragraphs.
+var htmlParagraphBreak = regexp.MustCompile(`\n(?:[ \t]*\n)+`)
+
+// NewHTMLRenderer returns a Renderer for the HTMLPreview profile.
+func NewHTMLRenderer(opts HTMLOptions) *HTMLRenderer {
+	if opts.Title == "" {
+		opts.Title = "EditML Preview"
+	}
+	return &HTMLRenderer{
+		opts:    opts,
+		sources: make(map[string]htmlAnchor),
+		targets: make(map[string][]htmlAnchor),
+		status:  make(map[string]ResolutionStatus),
+		ops:     make(map[string]string),
+	}
+}
+
+// Text writes escaped text. At the top level, blank lines end the current
+// paragraph; the blank lines themselves are kept between the elements.
+func (r *HTMLRenderer) Text(w *Walker, n model.TextNode) {
+	if w.Depth() > 0 {
+		r.write(html.EscapeString(n.Text))
+		return
+	}
+	last := 0
+	for _, loc := range htmlParagraphBreak.FindAllStringIndex(n.Text, -1) {
+		if loc[0] > last {
+			r.openParagraph()
+			r.write(html.EscapeString(n.Text[last:loc[0]]))
+		}
+		r.closeParagraph()
+		r.write(n.Text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	if last < len(n.Text) {
+		r.openParagraph()
+		r.write(html.EscapeString(n.Text[last:]))
+	}
+}
+
+// InlineEdit writes the edit as an <ins>, <del> or <span> element (Spec 6).
+func (r *HTMLRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	element := "span"
+	switch n.EditType {
+	case model.EditTypeAddition:
+		element = "ins"
+	case model.EditTypeDeletion:
+		element = "del"
+	}
+	r.openParagraph()
+	attrs := fmt.Sprintf(` class="editml-%s"`, n.EditType)
+	if n.EditorID != "" {
+		attrs += fmt.Sprintf(` data-editor="%s" title="%s"`, html.EscapeString(n.EditorID), html.EscapeString(n.EditorID))
+	}
+	r.write(fmt.Sprintf("<%s%s>%s</%s>", element, attrs, html.EscapeString(n.Content), element))
+}
+
+// StructuralSource writes the block with an anchor and links to its targets.
+func (r *HTMLRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	if w.Depth() > 0 { // Spec 3.4.3: structures cannot be nested.
+		r.write(html.EscapeString(fmt.Sprintf("{%s~%s~%s}", n.Operation, n.BlockContent, n.Tag)))
+		return
+	}
+	r.openParagraph()
+	status := w.Document().SourceStatus(n.Tag)
+	anchor := htmlAnchor{id: "editml-src-" + n.Tag, para: r.para}
+	r.addTag(n.Tag, n.Operation, status)
+	r.sources[n.Tag] = anchor
+
+	r.write(fmt.Sprintf(`<span class="editml-%s-source editml-%s" id="%s" data-tag="%s" title="%s">`,
+		n.Operation, status, anchor.id, n.Tag, html.EscapeString(blockPreview(src))))
+	if src != nil && src.Block != nil {
+		w.WalkBlock(src)
+	} else {
+		r.write(html.EscapeString(n.BlockContent))
+	}
+	r.write(`</span>`)
+
+	tag, op := n.Tag, n.Operation
+	r.deferLink(func() string {
+		var links []string
+		for _, t := range r.targets[tag] {
+			links = append(links, fmt.Sprintf(`<a href="#%s">¶ %d</a>`, t.id, t.para))
+		}
+		verb := map[string]string{model.OperationMove: "moved to", model.OperationCopy: "copied to"}[op]
+		switch {
+		case status == StatusConflicting:
+			return fmt.Sprintf(`<sup class="editml-link">%s %s: conflict</sup>`, op, tag)
+		case len(links) == 0:
+			return fmt.Sprintf(`<sup class="editml-link">%s %s: no target</sup>`, op, tag)
+		}
+		return fmt.Sprintf(`<sup class="editml-link">%s %s</sup>`, verb, strings.Join(links, ", "))
+	})
+}
+
+// StructuralTarget writes an anchor linking back to the source.
+func (r *HTMLRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	if w.Depth() > 0 {
+		r.write(html.EscapeString(fmt.Sprintf("{%s:%s}", n.Operation, n.Tag)))
+		return
+	}
+	r.openParagraph()
+	status := w.Document().TargetStatus(n)
+	if src == nil {
+		status = StatusUnresolved
+	}
+	r.addTag(n.Tag, n.Operation, status)
+	anchor := htmlAnchor{id: fmt.Sprintf("editml-tgt-%s-%d", n.Tag, len(r.targets[n.Tag])+1), para: r.para}
+	r.targets[n.Tag] = append(r.targets[n.Tag], anchor)
+
+	if status != StatusResolved {
+		r.write(fmt.Sprintf(`<span class="editml-%s-target editml-%s" id="%s" data-tag="%s">%s</span>`,
+			n.Operation, status, anchor.id, n.Tag, html.EscapeString(fmt.Sprintf("{%s:%s}", n.Operation, n.Tag))))
+		return
+	}
+	verb := map[string]string{model.OperationMove: "moved from", model.OperationCopy: "copied from"}[n.Operation]
+	r.write(fmt.Sprintf(`<a class="editml-%s-target editml-%s" id="%s" data-tag="%s" title="%s" href="#editml-src-%s">`,
+		n.Operation, status, anchor.id, n.Tag, html.EscapeString(blockPreview(src)), n.Tag))
+	tag := n.Tag
+	r.deferLink(func() string {
+		return fmt.Sprintf("%s ¶ %d", verb, r.sources[tag].para)
+	})
+	r.write("</a>")
+}
+
+// Result returns the HTML preview.
+func (r *HTMLRenderer) Result() (string, error) {
+	r.closeParagraph()
+	var body strings.Builder
+	for _, p := range r.pieces {
+		if p.link != nil {
+			body.WriteString(p.link())
+		} else {
+			body.WriteString(p.text)
+		}
+	}
+
+	var sb strings.Builder
+	if !r.opts.Fragment {
+		fmt.Fprintf(&sb, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n",
+			html.EscapeString(r.opts.Title), htmlStyle)
+	}
+	fmt.Fprintf(&sb, "<div class=\"editml\">\n%s\n</div>\n", body.String())
+	r.writeSummary(&sb)
+	if !r.opts.Fragment {
+		sb.WriteString("</body>\n</html>\n")
+	}
+	return sb.String(), nil
+}
+
+// writeSummary writes the table of structural tags with their status.
+func (r *HTMLRenderer) writeSummary(sb *strings.Builder) {
+	if len(r.tags) == 0 {
+		return
+	}
+	sb.WriteString("<table class=\"editml-summary\">\n<caption>Structural edits</caption>\n")
+	sb.WriteString("<thead><tr><th>Tag</th><th>Operation</th><th>Source</th><th>Targets</th><th>Status</th></tr></thead>\n<tbody>\n")
+	for _, tag := range r.tags {
+		source := "–"
+		if s, ok := r.sources[tag]; ok {
+			source = fmt.Sprintf(`<a href="#%s">¶ %d</a>`, s.id, s.para)
+		}
+		var targets []string
+		for _, t := range r.targets[tag] {
+			targets = append(targets, fmt.Sprintf(`<a href="#%s">¶ %d</a>`, t.id, t.para))
+		}
+		if len(targets) == 0 {
+			targets = []string{"–"}
+		}
+		fmt.Fprintf(sb, "<tr class=\"editml-%s\"><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			r.status[tag], tag, r.ops[tag], source, strings.Join(targets, ", "), r.status[tag])
+	}
+	sb.WriteString("</tbody>\n</table>\n")
+}
+
+// addTag records a structural tag in order of first appearance. The
+// status of a tag is that of its source, or of its targets if it has none;
+// a conflict anywhere makes the whole tag conflicting.
+func (r *HTMLRenderer) addTag(tag, op string, status ResolutionStatus) {
+	if _, seen := r.status[tag]; !seen {
+		r.tags = append(r.tags, tag)
+		r.ops[tag] = op
+		r.status[tag] = status
+		return
+	}
+	if status == StatusConflicting {
+		r.status[tag] = status
+	}
+}
+
+// write appends literal output.
+func (r *HTMLRenderer) write(s string) {
+	r.pieces = append(r.pieces, htmlPiece{text: s})
+}
+
+// deferLink appends output produced by link after the walk, when every
+// structural node has been numbered.
+func (r *HTMLRenderer) deferLink(link func() string) {
+	r.pieces = append(r.pieces, htmlPiece{link: link})
+}
+
+// openParagraph starts a new paragraph unless one is open.
+func (r *HTMLRenderer) openParagraph() {
+	if r.inPara {
+		return
+	}
+	r.para++
+	r.inPara = true
+	r.write(fmt.Sprintf(`<p id="editml-p%d">`, r.para))
+}
+
+// closeParagraph ends the open paragraph, if any.
+func (r *HTMLRenderer) closeParagraph() {
+	if r.inPara {
+		r.write("</p>")
+		r.inPara = false
+	}
+}
+
+// blockPreview returns the Clean View of a source's block, on one line and
+// shortened for use as a tooltip.
+func blockPreview(src *ResolvedSource) string {
+	if src == nil {
+		return ""
+	}
+	preview := src.Node.BlockContent
+	if src.Block != nil {
+		if text, err := Render(src.Block, NewCleanViewRenderer(CleanViewOptions{})); err == nil {
+			preview = text
+		}
+	}
+	preview = strings.Join(strings.Fields(preview), " ")
+	const maxPreview = 120
+	if utf8.RuneCountInString(preview) > maxPreview {
+		preview = string([]rune(preview)[:maxPreview-1]) + "…"
+	}
+	return preview
+}
+
+// htmlStyle is the style sheet of standalone HTML previews.
+const htmlStyle = `.editml { max-width: 42em; line-height: 1.5; }
+.editml ins { color: #116329; background: #dafbe1; text-decoration: underline; }
+.editml del { color: #82071e; background: #ffebe9; }
+.editml .editml-comment { color: #57606a; background: #f6f8fa; font-style: italic; border-left: 2px solid #d0d7de; padding: 0 .3em; }
+.editml .editml-comment::before { content: attr(data-editor) " "; font-weight: bold; font-style: normal; }
+.editml .editml-highlight { background: #fff8c5; }
+.editml [class*="-source"] { outline: 1px dashed #0969da; }
+.editml a[class*="-target"] { color: #0969da; font-size: smaller; }
+.editml .editml-link { color: #0969da; margin-left: .2em; }
+.editml .editml-unresolved { outline-color: #bf8700; color: #9a6700; }
+.editml .editml-conflicting { outline-color: #cf222e; color: #cf222e; }
+.editml-summary { border-collapse: collapse; margin-top: 2em; }
+.editml-summary th, .editml-summary td { border: 1px solid #d0d7de; padding: .2em .6em; text-align: left; }
+.editml-summary .editml-unresolved td:last-child { color: #9a6700; }
+.editml-summary .editml-conflicting td:last-child { color: #cf222e; }
+`
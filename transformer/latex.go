@@ -0,0 +1,230 @@
+// transformer/latex.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// Parts of the output the LaTeX profile can produce.
+const (
+	LaTeXDocument = "document" // A complete document: preamble, then the body.
+	LaTeXBody     = "body"     // The body only, for \input into a document.
+	LaTeXPreamble = "preamble" // The preamble snippet only.
+)
+
+// LaTeXOptions configures the LaTeX export profile.
+type LaTeXOptions struct {
+	// Output selects what Result returns: LaTeXDocument (the default),
+	// LaTeXBody or LaTeXPreamble.
+	Output string
+
+	// DocumentClass is the class of complete documents; "article" if empty.
+	DocumentClass string
+}
+
+// LaTeXRenderer exports a document as LaTeX using the markup commands of the
+// changes package: additions become \added, deletions \deleted, comments
+// \comment and highlights \highlight, with the editor ID as the id option. A
+// deletion directly followed by an addition from the same editor becomes
+// \replaced. All content is escaped for LaTeX.
+//
+// The commands of the changes package cannot span paragraphs, so an edit
+// containing blank lines is split into one command per paragraph. The
+// package has no moves or copies: a resolved move becomes a deletion at its
+// source and an addition at each target, and a copy an addition at each
+// target. These are reported by Diagnostics.
+//
+// The preamble loads the changes package and declares an author for every
+// editor ID in the document, in order of first appearance.
+type LaTeXRenderer struct {
+	opts    LaTeXOptions
+	sb      strings.Builder
+	pending *model.InlineEditNode // Deletion that may start a replacement.
+
+	editors     []string
+	seen        map[string]bool
+	diagnostics []Diagnostic
+}
+
+// NewLaTeXRenderer returns a Renderer for the LaTeX export profile. It returns
+// an error if opts.Output is not one of the LaTeX* constants.
+func NewLaTeXRenderer(opts LaTeXOptions) (*LaTeXRenderer, error) {
+	switch opts.Output {
+	case "":
+		opts.Output = LaTeXDocument
+	case LaTeXDocument, LaTeXBody, LaTeXPreamble:
+	default:
+		return nil, fmt.Errorf("invalid output %q, want document, body or preamble", opts.Output)
+	}
+	if opts.DocumentClass == "" {
+		opts.DocumentClass = "article"
+	}
+	return &LaTeXRenderer{opts: opts, seen: make(map[string]bool)}, nil
+}
+
+// Text writes escaped text.
+func (r *LaTeXRenderer) Text(w *Walker, n model.TextNode) {
+	r.flush()
+	r.sb.WriteString(EscapeLaTeX(n.Text))
+}
+
+// InlineEdit writes the changes command for an edit. Deletions are held back
+// until the next node shows whether they start a replacement.
+func (r *LaTeXRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	r.addEditor(n.EditorID)
+	if n.EditType == model.EditTypeAddition && r.pending != nil && r.pending.EditorID == n.EditorID &&
+		!latexParagraphBreak.MatchString(n.Content) && !latexParagraphBreak.MatchString(r.pending.Content) {
+		fmt.Fprintf(&r.sb, `\replaced%s{%s}{%s}`, latexID(n.EditorID), EscapeLaTeX(n.Content), EscapeLaTeX(r.pending.Content))
+		r.pending = nil
+		return
+	}
+	r.flush()
+	switch n.EditType {
+	case model.EditTypeAddition:
+		r.command("added", n.EditorID, n.Content)
+	case model.EditTypeDeletion:
+		r.pending = &n
+	case model.EditTypeComment:
+		r.command("comment", n.EditorID, n.Content)
+	case model.EditTypeHighlight:
+		r.command("highlight", n.EditorID, n.Content)
+	}
+}
+
+// StructuralSource writes the block of a resolved move as deleted, and the
+// block in place otherwise.
+func (r *LaTeXRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	r.flush()
+	if src == nil || src.Block == nil {
+		r.sb.WriteString(EscapeLaTeX(n.BlockContent))
+		return
+	}
+	if n.Operation != model.OperationMove || w.Document().SourceStatus(n.Tag) != StatusResolved {
+		w.WalkBlock(src)
+		r.flush()
+		return
+	}
+	text, _ := Render(src.Block, NewOriginalViewRenderer())
+	r.command("deleted", "", text)
+	r.warn(w, n.Tag, fmt.Sprintf("the changes package has no moves: move source %q exported as a deletion", n.Tag))
+}
+
+// StructuralTarget writes the block of a resolved move or copy as added.
+// Unresolved targets render nothing.
+func (r *LaTeXRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	r.flush()
+	if src == nil || src.Block == nil || w.Document().TargetStatus(n) != StatusResolved {
+		return
+	}
+	text, _ := Render(src.Block, NewCleanViewRenderer(CleanViewOptions{}))
+	r.command("added", "", text)
+	r.warn(w, n.Tag, fmt.Sprintf("the changes package has no moves or copies: %s target %q exported as an addition", n.Operation, n.Tag))
+}
+
+// Result returns the part of the output selected by LaTeXOptions.Output.
+func (r *LaTeXRenderer) Result() (string, error) {
+	r.flush()
+	switch r.opts.Output {
+	case LaTeXBody:
+		return r.sb.String(), nil
+	case LaTeXPreamble:
+		return r.Preamble(), nil
+	}
+	return `\documentclass{` + r.opts.DocumentClass + "}\n" +
+		"\\usepackage[T1]{fontenc}\n" +
+		r.Preamble() +
+		"\\begin{document}\n" + r.sb.String() + "\n\\end{document}\n", nil
+}
+
+// Preamble returns the preamble snippet: the changes package and an author
+// declaration for every editor ID in the document. It is complete once the
+// document has been rendered.
+func (r *LaTeXRenderer) Preamble() string {
+	var sb strings.Builder
+	sb.WriteString("\\usepackage{changes}\n")
+	for _, id := range r.editors {
+		fmt.Fprintf(&sb, "\\definechangesauthor[name={%s}]{%s}\n", id, id)
+	}
+	return sb.String()
+}
+
+// Diagnostics returns warnings for the moves and copies exported as
+// deletions and additions.
+func (r *LaTeXRenderer) Diagnostics() []Diagnostic {
+	return r.diagnostics
+}
+
+// latexParagraphBreak matches the blank lines between paragraphs.
+var latexParagraphBreak = regexp.MustCompile(`\n(?:[ \t]*\n)+`)
+
+// command writes \name[id=editorID]{content}, once per paragraph of content.
+func (r *LaTeXRenderer) command(name, editorID, content string) {
+	paragraphs := latexParagraphBreak.Split(content, -1)
+	breaks := latexParagraphBreak.FindAllString(content, -1)
+	for i, paragraph := range paragraphs {
+		if i > 0 {
+			r.sb.WriteString(breaks[i-1])
+		}
+		if paragraph != "" || len(paragraphs) == 1 {
+			fmt.Fprintf(&r.sb, `\%s%s{%s}`, name, latexID(editorID), EscapeLaTeX(paragraph))
+		}
+	}
+}
+
+// flush writes a held-back deletion.
+func (r *LaTeXRenderer) flush() {
+	if r.pending == nil {
+		return
+	}
+	n := r.pending
+	r.pending = nil
+	r.command("deleted", n.EditorID, n.Content)
+}
+
+// addEditor records an editor ID for the preamble.
+func (r *LaTeXRenderer) addEditor(id string) {
+	if id != "" && !r.seen[id] {
+		r.seen[id] = true
+		r.editors = append(r.editors, id)
+	}
+}
+
+// warn records a warning for the node being rendered.
+func (r *LaTeXRenderer) warn(w *Walker, tag, message string) {
+	d := Diagnostic{Code: CodeUnsupportedConstruct, Tag: tag, Message: message, Warning: true}
+	d.Span, d.HasSpan = w.Span()
+	r.diagnostics = append(r.diagnostics, d)
+}
+
+// latexID returns the option list naming an editor, or "" for none.
+func latexID(editorID string) string {
+	if editorID == "" {
+		return ""
+	}
+	return "[id=" + editorID + "]"
+}
+
+// latexEscapes maps LaTeX special characters to their escaped forms.
+var latexEscapes = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`#`, `\#`,
+	`%`, `\%`,
+	`_`, `\_`,
+	`^`, `\textasciicircum{}`,
+	`~`, `\textasciitilde{}`,
+)
+
+// EscapeLaTeX escapes the LaTeX special characters in s, so that it is
+// typeset as written.
+func EscapeLaTeX(s string) string {
+	return latexEscapes.Replace(s)
+}
@@ -0,0 +1,249 @@
+// transformer/ledger.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// Output formats of the ledger profile.
+const (
+	LedgerFormatJSONL = "jsonl" // One JSON object per line (JSON Lines).
+	LedgerFormatCSV   = "csv"   // RFC 4180 CSV with a header row.
+)
+
+// LedgerOptions configures the ledger profile.
+type LedgerOptions struct {
+	Format string // LedgerFormatJSONL (default) or LedgerFormatCSV.
+}
+
+// LedgerEntry is one edit listed by the ledger profile.
+type LedgerEntry struct {
+	// ID identifies the edit across re-parses: it is derived from the edit's
+	// kind, editor, tag, content and the words of source text around it in
+	// its paragraph, not from its position, so it stays the same as long as
+	// that part of the document is unchanged. Identical edits in identical surroundings
+	// are told apart by a "-2", "-3"... suffix, in document order.
+	ID string `json:"id"`
+
+	Kind     string `json:"type"`   // Edit type, or "move-source", "copy-target" etc. for structural nodes.
+	EditorID string `json:"editor"` // Editor ID of an inline edit, if any.
+	Content  string `json:"content"`
+	Tag      string `json:"tag"`
+
+	// Status is the resolution status of a structural node; empty for
+	// inline edits.
+	Status ResolutionStatus `json:"status"`
+
+	// Start and end of the edit's markup in the input (1-based; the end
+	// column is just past the markup), or 0 if positions are unknown.
+	StartLine   int `json:"start_line"`
+	StartColumn int `json:"start_column"`
+	EndLine     int `json:"end_line"`
+	EndColumn   int `json:"end_column"`
+
+	// Paragraph is the source of the paragraphs containing the edit: the
+	// input between the blank lines around it, markup included. Paragraphs
+	// reaching more than ledgerParagraphBytes before or after the edit are
+	// cut there, with "…" marking the cut. It is empty if positions are
+	// unknown.
+	Paragraph string `json:"paragraph"`
+}
+
+// ledgerColumns is the header row of the CSV format, in the order of the
+// LedgerEntry fields.
+var ledgerColumns = []string{"id", "type", "editor", "content", "tag", "status", "start_line", "start_column", "end_line", "end_column", "paragraph"}
+
+// ledgerContextWords is the number of words of source text on either side of
+// an edit that its ID depends on, and ledgerContextBytes the most input
+// searched for them. ledgerParagraphBytes is the most input of its paragraph
+// an entry keeps on either side of the edit, so that a document without
+// blank lines does not repeat itself in every entry.
+const (
+	ledgerContextWords   = 5
+	ledgerContextBytes   = 256
+	ledgerParagraphBytes = 1024
+)
+
+// LedgerRenderer lists every inline edit and structural operation of a
+// document as machine-readable records, for tracking suggestions in a
+// spreadsheet or database. Like the changes profile, it lists the edits
+// inside a structural block once, after the block's source.
+//
+// Positions, paragraphs and stable IDs need spans, so documents should come
+// from editml.ProcessDocument; without them IDs depend on the edit alone.
+type LedgerRenderer struct {
+	opts    LedgerOptions
+	entries []LedgerEntry
+	seen    map[string]int // Number of entries per ID hash, for duplicates.
+	breaks  [][]int        // Paragraph breaks of the input, found on first use.
+	found   bool           // Whether breaks were found, possibly none.
+}
+
+// NewLedgerRenderer returns a Renderer for the ledger profile.
+func NewLedgerRenderer(opts LedgerOptions) (*LedgerRenderer, error) {
+	switch opts.Format {
+	case "":
+		opts.Format = LedgerFormatJSONL
+	case LedgerFormatJSONL, LedgerFormatCSV:
+	default:
+		return nil, fmt.Errorf("invalid format %q, want %s or %s", opts.Format, LedgerFormatJSONL, LedgerFormatCSV)
+	}
+	return &LedgerRenderer{opts: opts, seen: make(map[string]int)}, nil
+}
+
+// Text does nothing: the ledger lists edits only.
+func (r *LedgerRenderer) Text(w *Walker, n model.TextNode) {}
+
+// InlineEdit adds an entry for the edit.
+func (r *LedgerRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	r.add(w, LedgerEntry{Kind: string(n.EditType), EditorID: n.EditorID, Content: n.Content})
+}
+
+// StructuralSource adds an entry for the source, followed by entries for the
+// edits inside its block.
+func (r *LedgerRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	r.add(w, LedgerEntry{
+		Kind:    n.Operation + "-source",
+		Content: n.BlockContent,
+		Tag:     n.Tag,
+		Status:  w.Document().SourceStatus(n.Tag),
+	})
+	w.WalkBlock(src)
+}
+
+// StructuralTarget adds an entry for the target.
+func (r *LedgerRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	r.add(w, LedgerEntry{
+		Kind:   n.Operation + "-target",
+		Tag:    n.Tag,
+		Status: w.Document().TargetStatus(n),
+	})
+}
+
+// Entries returns the collected entries in document order. It is valid after
+// the walk has completed.
+func (r *LedgerRenderer) Entries() []LedgerEntry {
+	return r.entries
+}
+
+// Result returns the entries in the selected format.
+func (r *LedgerRenderer) Result() (string, error) {
+	var sb strings.Builder
+	if r.opts.Format == LedgerFormatCSV {
+		cw := csv.NewWriter(&sb)
+		cw.UseCRLF = true // RFC 4180 line breaks.
+		if err := cw.Write(ledgerColumns); err != nil {
+			return "", err
+		}
+		for _, e := range r.entries {
+			record := []string{e.ID, e.Kind, e.EditorID, e.Content, e.Tag, string(e.Status),
+				ledgerInt(e.StartLine), ledgerInt(e.StartColumn), ledgerInt(e.EndLine), ledgerInt(e.EndColumn), e.Paragraph}
+			if err := cw.Write(record); err != nil {
+				return "", err
+			}
+		}
+		cw.Flush()
+		return sb.String(), cw.Error()
+	}
+	for _, e := range r.entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return "", err
+		}
+		sb.Write(data)
+		sb.WriteByte('\n')
+	}
+	return sb.String(), nil
+}
+
+// add appends an entry with its position, paragraph and ID.
+func (r *LedgerRenderer) add(w *Walker, e LedgerEntry) {
+	var before, markup, after string
+	if span, ok := w.Span(); ok {
+		input := w.Document().Input
+		e.StartLine, e.StartColumn = span.Start.Line, span.Start.Column
+		e.EndLine, e.EndColumn = span.End.Line, span.End.Column
+		if !r.found {
+			r.breaks = docxParagraphBreak.FindAllStringIndex(input, -1)
+			r.found = true
+		}
+		from, to := enclosingParagraphs(input, r.breaks, span.Start.Offset, span.End.Offset)
+		e.Paragraph = paragraphExcerpt(input, from, to, span.Start.Offset, span.End.Offset)
+		// Context words are taken from the paragraph only, and from a window
+		// of it, so that long documents stay fast.
+		before = lastWords(input[max(from, span.Start.Offset-ledgerContextBytes):span.Start.Offset], ledgerContextWords)
+		markup = input[span.Start.Offset:span.End.Offset]
+		after = firstWords(input[span.End.Offset:min(to, span.End.Offset+ledgerContextBytes)], ledgerContextWords)
+	}
+
+	h := sha256.New()
+	for _, part := range []string{e.Kind, e.EditorID, e.Tag, e.Content, before, markup, after} {
+		fmt.Fprintf(h, "%d:%s;", len(part), part)
+	}
+	id := "e" + hex.EncodeToString(h.Sum(nil))[:12]
+	r.seen[id]++
+	if n := r.seen[id]; n > 1 {
+		id = fmt.Sprintf("%s-%d", id, n)
+	}
+	e.ID = id
+	r.entries = append(r.entries, e)
+}
+
+// paragraphExcerpt returns the paragraph input[from:to] around the edit from
+// start to end, cut at rune boundaries ledgerParagraphBytes before and after
+// the edit, with "…" in place of the text cut.
+func paragraphExcerpt(input string, from, to, start, end int) string {
+	cutFrom, cutTo := max(from, start-ledgerParagraphBytes), min(to, end+ledgerParagraphBytes)
+	for cutFrom > from && !utf8.RuneStart(input[cutFrom]) {
+		cutFrom--
+	}
+	for cutTo < to && !utf8.RuneStart(input[cutTo]) {
+		cutTo++
+	}
+	excerpt := input[cutFrom:cutTo]
+	if cutFrom > from {
+		excerpt = "…" + excerpt
+	}
+	if cutTo < to {
+		excerpt += "…"
+	}
+	return excerpt
+}
+
+// enclosingParagraphs returns the range of the paragraphs of input
+// containing the range from start to end, without the blank lines around
+// them. breaks are the paragraph breaks of input.
+func enclosingParagraphs(input string, breaks [][]int, start, end int) (from, to int) {
+	from, to = 0, len(input)
+	// The first break ending after start is the first one that can follow it.
+	i := sort.Search(len(breaks), func(i int) bool { return breaks[i][1] > start })
+	if i > 0 {
+		from = breaks[i-1][1]
+	}
+	for ; i < len(breaks); i++ {
+		if breaks[i][0] >= end {
+			to = breaks[i][0]
+			break
+		}
+	}
+	return from, to
+}
+
+// ledgerInt formats a position for the CSV format; unknown positions are
+// left empty.
+func ledgerInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprint(n)
+}
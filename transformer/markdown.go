@@ -0,0 +1,157 @@
+// transformer/markdown.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// Highlight styles for the Markdown profile.
+const (
+	MarkdownHighlightMark = "mark" // ==text== (widely supported extension)
+	MarkdownHighlightBold = "bold" // **text** (plain CommonMark)
+)
+
+// MarkdownOptions configures the Markdown profile.
+type MarkdownOptions struct {
+	// Apply renders additions as plain text and drops deletions, like the
+	// Clean View. Otherwise suggestions are shown: additions as <ins>…</ins>
+	// and deletions as ~~…~~.
+	Apply bool
+
+	// HighlightStyle is MarkdownHighlightMark (the default) or
+	// MarkdownHighlightBold.
+	HighlightStyle string
+}
+
+// MarkdownRenderer renders a document as Markdown for readers with ordinary
+// Markdown viewers. Comments become numbered footnotes attributed to their
+// editor, and highlights and suggestions use Markdown-native markup.
+// Structural edits are resolved as in the Clean View.
+type MarkdownRenderer struct {
+	opts      MarkdownOptions
+	sb        strings.Builder
+	footnotes []string
+}
+
+// NewMarkdownRenderer returns a Renderer for the Markdown profile.
+func NewMarkdownRenderer(opts MarkdownOptions) (*MarkdownRenderer, error) {
+	switch opts.HighlightStyle {
+	case "":
+		opts.HighlightStyle = MarkdownHighlightMark
+	case MarkdownHighlightMark, MarkdownHighlightBold:
+	default:
+		return nil, fmt.Errorf("invalid highlight style %q, want %s or %s", opts.HighlightStyle, MarkdownHighlightMark, MarkdownHighlightBold)
+	}
+	return &MarkdownRenderer{opts: opts}, nil
+}
+
+// Text writes plain text unchanged; it is already Markdown.
+func (r *MarkdownRenderer) Text(w *Walker, n model.TextNode) {
+	r.sb.WriteString(n.Text)
+}
+
+// InlineEdit writes the Markdown form of an edit.
+func (r *MarkdownRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	switch n.EditType {
+	case model.EditTypeAddition:
+		if r.opts.Apply {
+			r.sb.WriteString(n.Content)
+		} else {
+			r.sb.WriteString(wrapMarkdownInline("<ins>", "</ins>", n.Content))
+		}
+	case model.EditTypeDeletion:
+		if !r.opts.Apply {
+			content := strings.ReplaceAll(n.Content, "~", `\~`)
+			r.sb.WriteString(wrapMarkdownInline("~~", "~~", content))
+		}
+	case model.EditTypeComment:
+		r.footnotes = append(r.footnotes, markdownFootnote(n))
+		fmt.Fprintf(&r.sb, "[^%d]", len(r.footnotes))
+	case model.EditTypeHighlight:
+		if r.opts.HighlightStyle == MarkdownHighlightBold {
+			r.sb.WriteString(wrapMarkdownInline("**", "**", n.Content))
+		} else {
+			content := strings.ReplaceAll(n.Content, "==", `\=\=`)
+			r.sb.WriteString(wrapMarkdownInline("==", "==", content))
+		}
+	}
+}
+
+// StructuralSource renders a copy source in place, drops a resolved move
+// source, and keeps unresolved markup literally.
+func (r *MarkdownRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	switch {
+	case src == nil || src.HasBlockError() || !src.Resolved:
+		fmt.Fprintf(&r.sb, "{%s~%s~%s}", n.Operation, n.BlockContent, n.Tag)
+	case n.Operation == model.OperationCopy:
+		w.WalkBlock(src)
+	}
+}
+
+// StructuralTarget renders the source's block content at the target.
+func (r *MarkdownRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	if src == nil || src.HasBlockError() || !src.Resolved || src.Node.Operation != n.Operation {
+		fmt.Fprintf(&r.sb, "{%s:%s}", n.Operation, n.Tag)
+		return
+	}
+	w.WalkBlock(src)
+}
+
+// Result returns the Markdown document with the footnote definitions appended.
+func (r *MarkdownRenderer) Result() (string, error) {
+	body := r.sb.String()
+	if len(r.footnotes) == 0 {
+		return body, nil
+	}
+	var sb strings.Builder
+	sb.WriteString(strings.TrimRight(body, "\n"))
+	sb.WriteString("\n\n")
+	for i, note := range r.footnotes {
+		fmt.Fprintf(&sb, "[^%d]: %s\n", i+1, note)
+	}
+	return sb.String(), nil
+}
+
+// markdownParagraphBreak matches a blank line, which ends an inline span.
+var markdownParagraphBreak = regexp.MustCompile(`\n[ \t]*\n`)
+
+// wrapMarkdownInline wraps content in inline delimiters so that it renders as
+// a CommonMark span: surrounding whitespace is kept outside the delimiters
+// (which must touch non-space characters), and each paragraph of multiline
+// content is wrapped separately.
+func wrapMarkdownInline(open, close, content string) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range markdownParagraphBreak.FindAllStringIndex(content, -1) {
+		sb.WriteString(wrapMarkdownParagraph(open, close, content[last:loc[0]]))
+		sb.WriteString(content[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	sb.WriteString(wrapMarkdownParagraph(open, close, content[last:]))
+	return sb.String()
+}
+
+// wrapMarkdownParagraph wraps a single paragraph of inline content.
+func wrapMarkdownParagraph(open, close, content string) string {
+	core := strings.TrimSpace(content)
+	if core == "" {
+		return content
+	}
+	start := strings.Index(content, core)
+	return content[:start] + open + core + close + content[start+len(core):]
+}
+
+// markdownFootnote formats a comment as the text of a footnote definition.
+// Footnote definitions are single-line, so line breaks become spaces.
+func markdownFootnote(n model.InlineEditNode) string {
+	text := strings.Join(strings.Fields(n.Content), " ")
+	if n.EditorID != "" {
+		return fmt.Sprintf("**%s:** %s", n.EditorID, text)
+	}
+	return text
+}
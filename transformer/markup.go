@@ -0,0 +1,460 @@
+// transformer/markup.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/verkaro/editml-go/model"
+	"github.com/verkaro/editml-go/parser"
+)
+
+// Decision is a reviewer's verdict on one edit.
+type Decision string
+
+// Decisions understood by MarkupRenderer.
+const (
+	DecisionPending Decision = ""       // Keep the edit as markup.
+	DecisionAccept  Decision = "accept" // Apply the edit, as the Clean View does.
+	DecisionReject  Decision = "reject" // Undo the edit, as the original profile does.
+)
+
+// Decider returns the decision for one edit. Edits are numbered as in the
+// changes profile, so the numbers of a review sheet can be used directly.
+type Decider func(e ChangeEntry) Decision
+
+// DecideByNumber returns a Decider that looks edits up by their number.
+func DecideByNumber(decisions map[int]Decision) Decider {
+	return func(e ChangeEntry) Decision { return decisions[e.Number] }
+}
+
+// DecideByEditor returns a Decider that looks edits up by their editor ID.
+func DecideByEditor(decisions map[string]Decision) Decider {
+	return func(e ChangeEntry) Decision {
+		if e.EditorID == "" {
+			return DecisionPending
+		}
+		return decisions[e.EditorID]
+	}
+}
+
+// MarkupRenderer writes a document back as EditML source (the MarkupView of
+// Spec 5.1), resolving the edits a Decider has decided on:
+//
+//   - accepted edits are applied: additions and highlights become text, and
+//     deletions and comments disappear;
+//   - rejected edits are undone: deletions and highlights become text, and
+//     additions and comments disappear;
+//   - pending edits stay as markup, with their content escaped (Spec 3.1).
+//
+// Text that would read as markup where resolved edits meet their neighbours,
+// such as the "{-a-}" left by accepting "{{+-a-+}}", is held in unattributed
+// highlights, so that the Clean View of the output reads as decided.
+//
+// A move or copy is decided as a unit by the decision for its source, or, if
+// that is pending, by the first decided target. Accepting a resolved unit
+// moves or copies the block to its targets; rejecting it leaves the block at
+// its source. Edits inside the block are decided on their own. Units that
+// cannot be applied (unresolved or conflicting tags) are kept as markup unless
+// rejected.
+//
+// The parts of a document with spans that no decision touches, debug comment
+// lines included, are copied from its input as written. Without a Decider
+// every edit is pending, and the output is the input unchanged.
+type MarkupRenderer struct {
+	decide Decider
+	doc    *ResolvedDocument
+
+	number    int
+	decisions map[markupKey]Decision
+	units     map[*ResolvedSource]Decision
+	targets   map[*ResolvedSource]Decision // First decided target of each unit.
+	decided   map[*ResolvedDocument]bool   // Documents with decided edits.
+}
+
+// markupKey identifies a node: the document it belongs to (the top level or
+// a structural block) and its index there.
+type markupKey struct {
+	doc   *ResolvedDocument
+	index int
+}
+
+// NewMarkupRenderer returns a Renderer for the markup profile. decide may be
+// nil.
+func NewMarkupRenderer(decide Decider) *MarkupRenderer {
+	return &MarkupRenderer{
+		decide:    decide,
+		decisions: make(map[markupKey]Decision),
+		units:     make(map[*ResolvedSource]Decision),
+		targets:   make(map[*ResolvedSource]Decision),
+		decided:   make(map[*ResolvedDocument]bool),
+	}
+}
+
+// The walk numbers the edits like ChangesRenderer and collects the decisions;
+// the output is written in Result, once every unit has been decided.
+
+// Text records the document being walked.
+func (r *MarkupRenderer) Text(w *Walker, n model.TextNode) {
+	r.record(w)
+}
+
+// InlineEdit collects the decision for the edit.
+func (r *MarkupRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	r.record(w)
+	r.decisions[markupKey{w.doc, w.index}] = r.decideEntry(w, ChangeEntry{Kind: string(n.EditType), EditorID: n.EditorID, Content: n.Content})
+}
+
+// StructuralSource collects the decision for the unit, then for the edits in
+// its block.
+func (r *MarkupRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	r.record(w)
+	d := r.decideEntry(w, ChangeEntry{
+		Kind:    n.Operation + "-source",
+		Content: n.BlockContent,
+		Tag:     n.Tag,
+		Status:  w.Document().SourceStatus(n.Tag),
+	})
+	if src != nil {
+		r.units[src] = d
+	}
+	w.WalkBlock(src)
+}
+
+// StructuralTarget collects the decision for the target.
+func (r *MarkupRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	r.record(w)
+	d := r.decideEntry(w, ChangeEntry{
+		Kind:   n.Operation + "-target",
+		Tag:    n.Tag,
+		Status: w.Document().TargetStatus(n),
+	})
+	if src != nil && r.targets[src] == DecisionPending {
+		r.targets[src] = d
+	}
+}
+
+// Result returns the EditML source with the decided edits resolved.
+func (r *MarkupRenderer) Result() (string, error) {
+	if r.doc == nil {
+		return "", nil
+	}
+	for src, d := range r.targets {
+		if r.units[src] == DecisionPending {
+			r.units[src] = d
+		}
+	}
+	m := &markupWriter{r: r, stack: []*markupBuffer{{}}}
+	if len(r.doc.Spans) == len(r.doc.Nodes) && r.doc.Input != "" {
+		m.copying = true
+		m.comments = parser.DebugCommentStarts(r.doc.Input, r.doc.Protected)
+	}
+	return Render(r.doc, m)
+}
+
+// record remembers the top-level document.
+func (r *MarkupRenderer) record(w *Walker) {
+	if w.parent == nil {
+		r.doc = w.doc
+	}
+}
+
+// decideEntry numbers e and returns the decision for it.
+func (r *MarkupRenderer) decideEntry(w *Walker, e ChangeEntry) Decision {
+	r.number++
+	e.Number = r.number
+	if span, ok := w.Span(); ok {
+		e.Line = span.Start.Line
+	}
+	if r.decide == nil {
+		return DecisionPending
+	}
+	d := r.decide(e)
+	if d != DecisionPending {
+		r.decided[w.doc] = true
+	}
+	return d
+}
+
+// markupWriter is the Renderer that writes the output of a MarkupRenderer.
+// At the top level of a document with spans, untouched nodes and the input
+// between nodes, such as debug comment lines, are copied from the input as
+// written; only decided nodes are written anew.
+type markupWriter struct {
+	r     *MarkupRenderer
+	stack []*markupBuffer // Output buffers; the last one receives output.
+
+	copying  bool  // Whether the top level is copied from r.doc.Input.
+	pos      int   // Offset in r.doc.Input up to which it has been written.
+	comments []int // Offsets of the debug comment lines of r.doc.Input.
+}
+
+// markupBuffer collects EditML source, along with the offsets at which the
+// writer meant constructs and debug comment lines to start and the ranges
+// that hold host-language text, so that markup formed where resolved text
+// meets its neighbours can be held as text (see parser.ProtectUnintended).
+type markupBuffer struct {
+	sb        strings.Builder
+	intended  []int
+	protected []model.Range
+}
+
+// markup writes markup that is meant to be read as such.
+func (b *markupBuffer) markup(s string) {
+	b.intended = append(b.intended, b.sb.Len())
+	b.sb.WriteString(s)
+}
+
+// text writes s, which is meant to be read as text; protected holds the
+// ranges of s that are host-language text.
+func (b *markupBuffer) text(s string, protected []model.Range) {
+	for _, r := range protected {
+		b.protected = append(b.protected, model.Range{Start: b.sb.Len() + r.Start, End: b.sb.Len() + r.End})
+	}
+	b.sb.WriteString(s)
+}
+
+// String returns the collected source with unintended markup held as text.
+// comments tells whether debug comment lines are read, which they are not in
+// block content.
+func (b *markupBuffer) String(comments bool) string {
+	return parser.ProtectUnintended(b.sb.String(), b.intended, b.protected, comments)
+}
+
+func (m *markupWriter) out() *markupBuffer {
+	return m.stack[len(m.stack)-1]
+}
+
+// copied copies the input between the previous top-level node and the node
+// being rendered, then the node's own input if the node is untouched and its
+// input reads back as n. It reports whether the node was copied; if not, the
+// caller writes it. Only top-level nodes with known spans are copied.
+func (m *markupWriter) copied(w *Walker, n model.Node, untouched bool) bool {
+	span, ok := w.Span()
+	if !m.copying || w.parent != nil || !ok || span.Start.Offset < m.pos {
+		return false
+	}
+	m.gap(span.Start.Offset)
+	m.pos = span.End.Offset
+	if !untouched || !readsAs(m.r.doc.Input[span.Start.Offset:span.End.Offset], n) {
+		return false
+	}
+	_, isText := n.(model.TextNode)
+	m.verbatim(span.Start.Offset, span.End.Offset, !isText)
+	return true
+}
+
+// gap copies the input from pos up to end, which lies between nodes, if it
+// only holds debug comment lines and line breaks. Importers such as
+// FromCriticMarkup leave out input for other reasons, which stays out.
+func (m *markupWriter) gap(end int) {
+	if strings.Trim(parser.SkipDebugComments(m.r.doc.Input[m.pos:end]), "\r\n") == "" {
+		m.verbatim(m.pos, end, false)
+	}
+}
+
+// verbatim writes the input from start to end, along with the debug comment
+// lines and protected ranges it holds. construct tells whether it is a node
+// that is meant to be read as markup.
+func (m *markupWriter) verbatim(start, end int, construct bool) {
+	b := m.out()
+	base := b.sb.Len() - start
+	if construct {
+		b.intended = append(b.intended, b.sb.Len())
+	}
+	for _, c := range m.comments {
+		if c >= start && c < end {
+			b.intended = append(b.intended, base+c)
+		}
+	}
+	for _, r := range m.r.doc.Protected {
+		if r.Start < end && r.End > start {
+			b.protected = append(b.protected, model.Range{Start: base + max(r.Start, start), End: base + min(r.End, end)})
+		}
+	}
+	b.sb.WriteString(m.r.doc.Input[start:end])
+}
+
+// readsAs reports whether source, the input of a node, reads back as n.
+func readsAs(source string, n model.Node) bool {
+	switch n := n.(type) {
+	case model.TextNode:
+		// Text runs across the debug comment lines between its lines; the
+		// trailing "x" keeps the line break before a last comment line.
+		return n.Text == source || parser.SkipDebugComments(source+"x") == n.Text+"x"
+	case model.InlineEditNode:
+		if InlineMarkup(n) == source {
+			return true
+		}
+	}
+	nodes, err := parser.ParseEditMLToNodes(parser.SkipDebugComments(source))
+	return err == nil && len(nodes) == 1 && nodes[0] == n
+}
+
+// localProtected returns the protected ranges of the content of the node
+// being rendered.
+func localProtected(w *Walker) []model.Range {
+	if span, ok := w.Span(); ok && span.Content != nil {
+		return parser.LocalRanges(w.doc.Protected, span.Content)
+	}
+	return nil
+}
+
+// Text writes text unchanged: text nodes hold the input as written.
+func (m *markupWriter) Text(w *Walker, n model.TextNode) {
+	if !m.copied(w, n, true) {
+		m.out().text(n.Text, localProtected(w))
+	}
+}
+
+// InlineEdit writes the edit resolved according to its decision.
+func (m *markupWriter) InlineEdit(w *Walker, n model.InlineEditNode) {
+	d := m.r.decisions[markupKey{w.doc, w.index}]
+	if m.copied(w, n, d == DecisionPending) {
+		return
+	}
+	switch {
+	case d == DecisionPending:
+		m.out().markup(InlineMarkup(n))
+	case n.EditType == model.EditTypeHighlight,
+		n.EditType == model.EditTypeAddition && d == DecisionAccept,
+		n.EditType == model.EditTypeDeletion && d == DecisionReject:
+		m.out().text(n.Content, localProtected(w))
+	}
+}
+
+// StructuralSource writes the block in place, at the targets only, or as
+// markup, according to the unit's decision.
+func (m *markupWriter) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	if src == nil || src.Block == nil {
+		if !m.copied(w, n, true) {
+			m.out().markup(fmt.Sprintf("{%s~%s~%s}", n.Operation, EscapeBlock(n.BlockContent), n.Tag))
+		}
+		return
+	}
+	switch d := m.r.units[src]; {
+	case d == DecisionReject:
+		m.copied(w, n, false)
+		w.WalkBlock(src)
+	case d == DecisionAccept && w.doc.SourceStatus(n.Tag) == StatusResolved:
+		m.copied(w, n, false)
+		if n.Operation == model.OperationCopy {
+			w.WalkBlock(src)
+		}
+	default:
+		if m.copied(w, n, !m.r.decided[src.Block]) {
+			return
+		}
+		m.stack = append(m.stack, &markupBuffer{})
+		w.WalkBlock(src)
+		block := m.out().String(false)
+		m.stack = m.stack[:len(m.stack)-1]
+		m.out().markup(fmt.Sprintf("{%s~%s~%s}", n.Operation, EscapeBlock(block), n.Tag))
+	}
+}
+
+// StructuralTarget writes the block, nothing, or the target markup, according
+// to the unit's decision.
+func (m *markupWriter) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	if src == nil || src.Block == nil || w.doc.SourceStatus(n.Tag) != StatusResolved {
+		if !m.copied(w, n, true) {
+			m.out().markup(fmt.Sprintf("{%s:%s}", n.Operation, n.Tag))
+		}
+		return
+	}
+	switch m.r.units[src] {
+	case DecisionAccept:
+		m.copied(w, n, false)
+		w.WalkBlock(src)
+	case DecisionReject:
+		m.copied(w, n, false)
+	default:
+		if !m.copied(w, n, true) {
+			m.out().markup(fmt.Sprintf("{%s:%s}", n.Operation, n.Tag))
+		}
+	}
+}
+
+// Result returns the written output, with the rest of the input copied.
+func (m *markupWriter) Result() (string, error) {
+	if m.copying {
+		m.gap(len(m.r.doc.Input))
+	}
+	return m.stack[0].String(true), nil
+}
+
+// inlineOperators holds the opening and closing operator of each edit type
+// (Spec 3.3.1).
+var inlineOperators = map[model.EditType][2]string{
+	model.EditTypeAddition:  {"+", "+"},
+	model.EditTypeDeletion:  {"-", "-"},
+	model.EditTypeComment:   {">", "<"},
+	model.EditTypeHighlight: {"=", "="},
+}
+
+// InlineMarkup returns the EditML markup for an inline edit, escaping its
+// content (Spec 3.1, 3.3.1).
+func InlineMarkup(n model.InlineEditNode) string {
+	ops := inlineOperators[n.EditType]
+	return "{" + ops[0] + EscapeInline(n.Content, n.EditType) + ops[1] + n.EditorID + "}"
+}
+
+// EscapeInline escapes content for use inside an inline edit of the given
+// type: backslashes, curly braces and the edit's closing operator are
+// escaped, which the parser reverses.
+func EscapeInline(content string, editType model.EditType) string {
+	return escapeChars(content, `\{}`+inlineOperators[editType][1])
+}
+
+// EscapeBlock escapes content for use as the block content of a structural
+// source: backslashes and tildes are escaped (Spec 3.4.1).
+func EscapeBlock(content string) string {
+	return escapeChars(content, `\~`)
+}
+
+// escapeChars precedes every occurrence of a character in special with a
+// backslash.
+func escapeChars(s string, special string) string {
+	if !strings.ContainsAny(s, special) {
+		return s
+	}
+	var sb strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(special, c) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// optionsDecider returns the Decider for the "accept" and "reject" options
+// of the markup profile. Each option is a comma-separated list of edit
+// numbers, as listed by the changes profile, and editor IDs; numbers take
+// precedence over editor IDs.
+func optionsDecider(opts Options) Decider {
+	byNumber := make(map[int]Decision)
+	byEditor := make(map[string]Decision)
+	for _, d := range []Decision{DecisionAccept, DecisionReject} {
+		for _, item := range strings.Split(opts[string(d)], ",") {
+			item = strings.TrimPrefix(strings.TrimSpace(item), "#")
+			if item == "" {
+				continue
+			}
+			if n, err := strconv.Atoi(item); err == nil {
+				byNumber[n] = d
+			} else {
+				byEditor[item] = d
+			}
+		}
+	}
+	byID := DecideByEditor(byEditor)
+	return func(e ChangeEntry) Decision {
+		if d, ok := byNumber[e.Number]; ok {
+			return d
+		}
+		return byID(e)
+	}
+}
@@ -0,0 +1,328 @@
+// transformer/odt.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// ODTOptions configures the OpenDocument export profile.
+type ODTOptions struct {
+	// Author is the author of edits without an editor ID; "EditML" if empty.
+	Author string
+
+	// Date is the date recorded on every tracked change and comment. The zero
+	// value omits the date.
+	Date time.Time
+}
+
+// ODTRenderer exports a document as an OpenDocument text file (.odt) with
+// tracked changes, as written by LibreOffice. Result returns the bytes of the
+// ZIP package.
+//
+//   - Additions become insertions and deletions become deletions in the
+//     text:tracked-changes list, created by their editor ID.
+//   - Comments become office:annotation elements by their editor. A comment
+//     directly following another edit is anchored to that edit's text.
+//   - Highlights become text with a yellow background.
+//   - OpenDocument has no tracked moves or copies: a resolved move becomes a
+//     deletion at its source and an insertion at each target, and a copy an
+//     insertion at each target. These are reported by Diagnostics.
+//
+// Text is split into paragraphs at blank lines, as in the DOCX profile, and
+// change recording is switched on.
+type ODTRenderer struct {
+	opts ODTOptions
+	date string // The dc:date element of changes and comments, if any.
+
+	paras   [][]string // Content XML of each text:p, in order.
+	regions []string   // text:changed-region elements.
+	anchor  *docxAnchor
+	notes   int // Number of anchored annotations.
+
+	diagnostics []Diagnostic
+}
+
+// NewODTRenderer returns a Renderer for the OpenDocument export profile.
+func NewODTRenderer(opts ODTOptions) *ODTRenderer {
+	if opts.Author == "" {
+		opts.Author = "EditML"
+	}
+	r := &ODTRenderer{opts: opts, paras: [][]string{nil}}
+	if !opts.Date.IsZero() {
+		r.date = "<dc:date>" + opts.Date.UTC().Format("2006-01-02T15:04:05") + "</dc:date>"
+	}
+	return r
+}
+
+// Text writes plain text.
+func (r *ODTRenderer) Text(w *Walker, n model.TextNode) {
+	r.writeText(n.Text, "")
+	r.anchor = nil
+}
+
+// InlineEdit writes a tracked change, an annotation or highlighted text.
+func (r *ODTRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	author := r.author(n.EditorID)
+	switch n.EditType {
+	case model.EditTypeAddition:
+		r.anchor = r.insert(n.Content, author)
+	case model.EditTypeDeletion:
+		r.anchor = r.delete(n.Content, author)
+	case model.EditTypeHighlight:
+		r.anchor = r.writeText(n.Content, odtHighlightStyle)
+	case model.EditTypeComment:
+		r.annotate(n.Content, author)
+		r.anchor = nil
+	}
+}
+
+// StructuralSource writes the block of a resolved move as deleted, and the
+// block in place otherwise.
+func (r *ODTRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	r.anchor = nil
+	if src == nil || src.Block == nil {
+		r.writeText(n.BlockContent, "")
+		return
+	}
+	if n.Operation != model.OperationMove || w.Document().SourceStatus(n.Tag) != StatusResolved {
+		w.WalkBlock(src)
+		r.anchor = nil
+		return
+	}
+	text, _ := Render(src.Block, NewOriginalViewRenderer())
+	r.delete(text, r.opts.Author)
+	r.warn(w, n.Tag, fmt.Sprintf("OpenDocument has no tracked moves: move source %q exported as a deletion", n.Tag))
+}
+
+// StructuralTarget writes the block of a resolved move or copy as inserted.
+// Unresolved targets render nothing.
+func (r *ODTRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	r.anchor = nil
+	if src == nil || src.Block == nil || w.Document().TargetStatus(n) != StatusResolved {
+		return
+	}
+	text, _ := Render(src.Block, NewCleanViewRenderer(CleanViewOptions{}))
+	r.insert(text, r.opts.Author)
+	r.warn(w, n.Tag, fmt.Sprintf("OpenDocument has no tracked moves or copies: %s target %q exported as an insertion", n.Operation, n.Tag))
+}
+
+// Result returns the .odt package.
+func (r *ODTRenderer) Result() (string, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, part := range []struct{ name, content string }{
+		{"mimetype", odtMimeType}, // First and stored, so that the file type can be recognized.
+		{"META-INF/manifest.xml", odtManifest},
+		{"content.xml", r.contentXML()},
+		{"styles.xml", docxXMLHeader + `<office:document-styles ` + odtNamespaces + ` office:version="1.2"/>`},
+	} {
+		method := zip.Deflate
+		if part.name == "mimetype" {
+			method = zip.Store
+		}
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: part.name, Method: method})
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write([]byte(part.content)); err != nil {
+			return "", err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Diagnostics returns warnings for the moves and copies exported as
+// deletions and insertions.
+func (r *ODTRenderer) Diagnostics() []Diagnostic {
+	return r.diagnostics
+}
+
+// writeText appends text, in a span of the given style if style is not
+// empty, starting a new paragraph at every blank line. It returns the
+// position of the first piece written, or nil if text is empty.
+func (r *ODTRenderer) writeText(text, style string) *docxAnchor {
+	var first *docxAnchor
+	for i, segment := range docxParagraphBreak.Split(text, -1) {
+		if i > 0 {
+			r.paras = append(r.paras, nil)
+		}
+		if segment == "" {
+			continue
+		}
+		p := len(r.paras) - 1
+		if first == nil {
+			first = &docxAnchor{p, len(r.paras[p])}
+		}
+		content := odtText(segment)
+		if style != "" {
+			content = `<text:span text:style-name="` + style + `">` + content + `</text:span>`
+		}
+		r.append(content)
+	}
+	return first
+}
+
+// insert writes text between the start and end marks of a new insertion.
+func (r *ODTRenderer) insert(text, author string) *docxAnchor {
+	id := r.region("<text:insertion>" + r.changeInfo(author) + "</text:insertion>")
+	first := &docxAnchor{len(r.paras) - 1, len(r.paras[len(r.paras)-1])}
+	r.append(`<text:change-start text:change-id="` + id + `"/>`)
+	r.writeText(text, "")
+	r.append(`<text:change-end text:change-id="` + id + `"/>`)
+	return first
+}
+
+// delete records text as a deletion, which the document body marks with a
+// single point: the text, including any paragraph breaks, lives in the
+// changed region.
+func (r *ODTRenderer) delete(text, author string) *docxAnchor {
+	var deleted strings.Builder
+	for _, segment := range docxParagraphBreak.Split(text, -1) {
+		deleted.WriteString("<text:p>" + odtText(segment) + "</text:p>")
+	}
+	id := r.region("<text:deletion>" + r.changeInfo(author) + deleted.String() + "</text:deletion>")
+	first := &docxAnchor{len(r.paras) - 1, len(r.paras[len(r.paras)-1])}
+	r.append(`<text:change text:change-id="` + id + `"/>`)
+	return first
+}
+
+// annotate writes a comment, anchored to the last edit if there is one.
+func (r *ODTRenderer) annotate(text, author string) {
+	var body strings.Builder
+	body.WriteString("<dc:creator>" + docxEscape(author) + "</dc:creator>" + r.date)
+	for _, segment := range docxParagraphBreak.Split(text, -1) {
+		body.WriteString("<text:p>" + odtText(segment) + "</text:p>")
+	}
+	a := r.anchor
+	if a == nil {
+		r.append("<office:annotation>" + body.String() + "</office:annotation>")
+		return
+	}
+	r.notes++
+	name := fmt.Sprintf("editml-note-%d", r.notes)
+	start := `<office:annotation office:name="` + name + `">` + body.String() + "</office:annotation>"
+	p := r.paras[a.para]
+	r.paras[a.para] = append(p[:a.piece], append([]string{start}, p[a.piece:]...)...)
+	r.append(`<office:annotation-end office:name="` + name + `"/>`)
+}
+
+// region adds a changed region and returns its ID.
+func (r *ODTRenderer) region(change string) string {
+	id := fmt.Sprintf("ct%d", len(r.regions)+1)
+	r.regions = append(r.regions, `<text:changed-region xml:id="`+id+`" text:id="`+id+`">`+change+`</text:changed-region>`)
+	return id
+}
+
+// changeInfo returns the author and date of a change.
+func (r *ODTRenderer) changeInfo(author string) string {
+	return "<office:change-info><dc:creator>" + docxEscape(author) + "</dc:creator>" + r.date + "</office:change-info>"
+}
+
+// append adds a piece to the current paragraph.
+func (r *ODTRenderer) append(piece string) {
+	r.paras[len(r.paras)-1] = append(r.paras[len(r.paras)-1], piece)
+}
+
+// author returns the change author for an editor ID.
+func (r *ODTRenderer) author(editorID string) string {
+	if editorID == "" {
+		return r.opts.Author
+	}
+	return editorID
+}
+
+// warn records a warning for the node being rendered.
+func (r *ODTRenderer) warn(w *Walker, tag, message string) {
+	d := Diagnostic{Code: CodeUnsupportedConstruct, Tag: tag, Message: message, Warning: true}
+	d.Span, d.HasSpan = w.Span()
+	r.diagnostics = append(r.diagnostics, d)
+}
+
+// contentXML returns the contents of content.xml.
+func (r *ODTRenderer) contentXML() string {
+	var sb strings.Builder
+	sb.WriteString(docxXMLHeader + `<office:document-content ` + odtNamespaces + ` office:version="1.2">`)
+	sb.WriteString(`<office:automatic-styles><style:style style:name="` + odtHighlightStyle + `" style:family="text">` +
+		`<style:text-properties fo:background-color="#ffff00"/></style:style></office:automatic-styles>`)
+	sb.WriteString(`<office:body><office:text><text:tracked-changes text:track-changes="true">`)
+	for _, region := range r.regions {
+		sb.WriteString(region)
+	}
+	sb.WriteString("</text:tracked-changes>")
+	for _, p := range r.paras {
+		sb.WriteString("<text:p>" + strings.Join(p, "") + "</text:p>")
+	}
+	sb.WriteString("</office:text></office:body></office:document-content>")
+	return sb.String()
+}
+
+// odtText returns text as paragraph content: line breaks become
+// text:line-break, tabs text:tab, and runs of spaces, which OpenDocument would
+// collapse, text:s. Leading spaces are always written as text:s, since the
+// text may start a paragraph, where even a single space would be dropped.
+func odtText(text string) string {
+	var sb strings.Builder
+	atStart := true
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			sb.WriteString("<text:line-break/>")
+			atStart = true
+		}
+		for j, chunk := range strings.Split(line, "\t") {
+			if j > 0 {
+				sb.WriteString("<text:tab/>")
+				atStart = false
+			}
+			for len(chunk) > 0 {
+				spaces := len(chunk) - len(strings.TrimLeft(chunk, " "))
+				switch {
+				case spaces == 0:
+					word := chunk
+					if k := strings.Index(chunk, " "); k >= 0 {
+						word = chunk[:k]
+					}
+					sb.WriteString(docxEscape(word))
+					chunk = chunk[len(word):]
+				case atStart:
+					sb.WriteString(fmt.Sprintf(`<text:s text:c="%d"/>`, spaces))
+					chunk = chunk[spaces:]
+				default:
+					sb.WriteString(" ")
+					if spaces > 1 {
+						sb.WriteString(fmt.Sprintf(`<text:s text:c="%d"/>`, spaces-1))
+					}
+					chunk = chunk[spaces:]
+				}
+				atStart = false
+			}
+		}
+	}
+	return sb.String()
+}
+
+const (
+	odtMimeType       = "application/vnd.oasis.opendocument.text"
+	odtHighlightStyle = "EditMLHighlight"
+
+	odtNamespaces = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" ` +
+		`xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0" ` +
+		`xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" ` +
+		`xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/"`
+
+	odtManifest = docxXMLHeader + `<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">` +
+		`<manifest:file-entry manifest:full-path="/" manifest:version="1.2" manifest:media-type="` + odtMimeType + `"/>` +
+		`<manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>` +
+		`<manifest:file-entry manifest:full-path="styles.xml" manifest:media-type="text/xml"/>` +
+		`</manifest:manifest>`
+)
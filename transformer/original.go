@@ -0,0 +1,63 @@
+// transformer/original.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"github.com/verkaro/editml-go/model"
+)
+
+// OriginalViewRenderer renders the text as it was before any edit was
+// applied: deletions are kept, additions and comments are dropped, highlights
+// become plain text, and every structural block stays at its source. It is the
+// counterpart of the Clean View.
+type OriginalViewRenderer struct {
+	out outputBuffer
+}
+
+// NewOriginalViewRenderer returns a Renderer for the "original" profile.
+func NewOriginalViewRenderer() *OriginalViewRenderer {
+	return &OriginalViewRenderer{}
+}
+
+// Text writes plain text unchanged.
+func (r *OriginalViewRenderer) Text(w *Walker, n model.TextNode) {
+	span, _ := w.Span()
+	r.out.writeCopied(n.Text, span.Content)
+}
+
+// InlineEdit keeps the content of deletions and highlights and omits
+// additions and comments.
+func (r *OriginalViewRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	switch n.EditType {
+	case model.EditTypeDeletion, model.EditTypeHighlight:
+		span, _ := w.Span()
+		r.out.writeCopied(n.Content, span.Content)
+	}
+}
+
+// StructuralSource renders the block content in place, whether or not the
+// operation resolves. Block content that failed to parse is written as is.
+func (r *OriginalViewRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	if src != nil && src.Block != nil {
+		w.WalkBlock(src)
+		return
+	}
+	span, _ := w.Span()
+	r.out.writeCopied(n.BlockContent, span.Content)
+}
+
+// StructuralTarget renders nothing: targets only receive content once the
+// edits are applied.
+func (r *OriginalViewRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+}
+
+// Result returns the original text.
+func (r *OriginalViewRenderer) Result() (string, error) {
+	return r.out.String(), nil
+}
+
+// SourceMap returns the mapping from the text returned by Result to the
+// input, for documents parsed with positions (see editml.ProcessDocument).
+func (r *OriginalViewRenderer) SourceMap() SourceMap {
+	return r.out.sourceMap()
+}
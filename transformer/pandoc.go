@@ -0,0 +1,302 @@
+// transformer/pandoc.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// PandocAPIVersion is the version of the pandoc-types JSON format written by
+// the Pandoc profile (Pandoc 3.1 and later).
+var PandocAPIVersion = []int{1, 23, 1}
+
+// PandocOptions configures the Pandoc JSON export profile.
+type PandocOptions struct {
+	// Author is the author of edits without an editor ID; "EditML" if empty.
+	Author string
+
+	// Date is recorded on every change and comment. The zero value omits it.
+	Date time.Time
+}
+
+// PandocRenderer exports a document as Pandoc's JSON AST, for
+// "pandoc -f json". Edits use the representation of Pandoc's own
+// track-changes support (as read from DOCX with --track-changes=all), so that
+// they survive conversion to formats that support them:
+//
+//   - additions become Span elements with class "insertion" and deletions
+//     with class "deletion", with an "author" attribute;
+//   - a comment becomes a Span with class "comment-start" holding the comment
+//     text, and an empty "comment-end" Span with the same ID. A comment
+//     directly following another edit covers that edit; otherwise both
+//     spans are at its position;
+//   - highlights become Spans with class "mark";
+//   - paragraph breaks inside additions and deletions end the paragraph with
+//     a "paragraph-insertion" or "paragraph-deletion" Span.
+//
+// Pandoc has no moves or copies: a resolved move becomes a deletion at its
+// source and an insertion at each target, and a copy an insertion at each
+// target. These are reported by Diagnostics.
+//
+// Text is split into paragraphs (Para) at blank lines and into Str, Space and
+// SoftBreak elements; it is not parsed as Markdown.
+type PandocRenderer struct {
+	opts PandocOptions
+
+	paras    [][]pandocElement
+	anchor   *docxAnchor
+	comments int
+
+	diagnostics []Diagnostic
+}
+
+// pandocElement is a Pandoc AST element in JSON form: a tag and its contents.
+type pandocElement struct {
+	T string `json:"t"`
+	C any    `json:"c,omitempty"`
+}
+
+// NewPandocRenderer returns a Renderer for the Pandoc JSON export profile.
+func NewPandocRenderer(opts PandocOptions) *PandocRenderer {
+	if opts.Author == "" {
+		opts.Author = "EditML"
+	}
+	return &PandocRenderer{opts: opts, paras: [][]pandocElement{nil}}
+}
+
+// Text writes plain inlines.
+func (r *PandocRenderer) Text(w *Walker, n model.TextNode) {
+	r.write(n.Text, nil)
+	r.anchor = nil
+}
+
+// InlineEdit writes a change, comment or mark span.
+func (r *PandocRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	author := r.author(n.EditorID)
+	switch n.EditType {
+	case model.EditTypeAddition:
+		r.anchor = r.write(n.Content, r.change("insertion", author))
+	case model.EditTypeDeletion:
+		r.anchor = r.write(n.Content, r.change("deletion", author))
+	case model.EditTypeHighlight:
+		attrs := [][2]string{}
+		if n.EditorID != "" {
+			attrs = append(attrs, [2]string{"author", n.EditorID})
+		}
+		r.anchor = r.write(n.Content, pandocAttr("", "mark", attrs))
+	case model.EditTypeComment:
+		r.comment(n.Content, author)
+		r.anchor = nil
+	}
+}
+
+// StructuralSource writes the block of a resolved move as deleted, and the
+// block in place otherwise.
+func (r *PandocRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	r.anchor = nil
+	if src == nil || src.Block == nil {
+		r.write(n.BlockContent, nil)
+		return
+	}
+	if n.Operation != model.OperationMove || w.Document().SourceStatus(n.Tag) != StatusResolved {
+		w.WalkBlock(src)
+		r.anchor = nil
+		return
+	}
+	text, _ := Render(src.Block, NewOriginalViewRenderer())
+	r.write(text, r.change("deletion", r.opts.Author))
+	r.warn(w, n.Tag, fmt.Sprintf("Pandoc has no tracked moves: move source %q exported as a deletion", n.Tag))
+}
+
+// StructuralTarget writes the block of a resolved move or copy as inserted.
+// Unresolved targets render nothing.
+func (r *PandocRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	r.anchor = nil
+	if src == nil || src.Block == nil || w.Document().TargetStatus(n) != StatusResolved {
+		return
+	}
+	text, _ := Render(src.Block, NewCleanViewRenderer(CleanViewOptions{}))
+	r.write(text, r.change("insertion", r.opts.Author))
+	r.warn(w, n.Tag, fmt.Sprintf("Pandoc has no tracked moves or copies: %s target %q exported as an insertion", n.Operation, n.Tag))
+}
+
+// Result returns the JSON document.
+func (r *PandocRenderer) Result() (string, error) {
+	blocks := []pandocElement{}
+	for _, p := range r.paras {
+		if len(p) > 0 {
+			blocks = append(blocks, pandocElement{T: "Para", C: p})
+		}
+	}
+	data, err := json.Marshal(struct {
+		APIVersion []int           `json:"pandoc-api-version"`
+		Meta       map[string]any  `json:"meta"`
+		Blocks     []pandocElement `json:"blocks"`
+	}{PandocAPIVersion, map[string]any{}, blocks})
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// Diagnostics returns warnings for the moves and copies exported as
+// deletions and insertions.
+func (r *PandocRenderer) Diagnostics() []Diagnostic {
+	return r.diagnostics
+}
+
+// write appends text as inlines, wrapped in a Span with attr if attr is not
+// nil, starting a new paragraph at every blank line. A wrapped paragraph
+// break is recorded with a "paragraph-" Span of the same class. It returns
+// the position of the first element written, or nil if text is empty.
+func (r *PandocRenderer) write(text string, attr []any) *docxAnchor {
+	var first *docxAnchor
+	for i, segment := range docxParagraphBreak.Split(text, -1) {
+		if i > 0 {
+			if attr != nil {
+				r.append(pandocSpan(pandocParagraphAttr(attr), nil))
+			}
+			r.paras = append(r.paras, nil)
+		}
+		if segment == "" {
+			continue
+		}
+		p := len(r.paras) - 1
+		if first == nil {
+			first = &docxAnchor{p, len(r.paras[p])}
+		}
+		inlines := pandocInlines(segment)
+		if attr == nil {
+			r.paras[p] = append(r.paras[p], inlines...)
+		} else {
+			r.append(pandocSpan(attr, inlines))
+		}
+	}
+	return first
+}
+
+// comment writes a comment-start Span holding the comment, before the last
+// edit if there is one, and the matching comment-end Span.
+func (r *PandocRenderer) comment(text, author string) {
+	id := fmt.Sprint(r.comments)
+	r.comments++
+	var inlines []pandocElement
+	for i, segment := range docxParagraphBreak.Split(text, -1) {
+		if i > 0 {
+			inlines = append(inlines, pandocElement{T: "LineBreak"})
+		}
+		inlines = append(inlines, pandocInlines(segment)...)
+	}
+	start := pandocSpan(pandocAttr(id, "comment-start", r.attributes(author)), inlines)
+	if a := r.anchor; a != nil {
+		p := r.paras[a.para]
+		r.paras[a.para] = append(p[:a.piece], append([]pandocElement{start}, p[a.piece:]...)...)
+	} else {
+		r.append(start)
+	}
+	r.append(pandocSpan(pandocAttr(id, "comment-end", nil), nil))
+}
+
+// change returns the attributes of a change Span.
+func (r *PandocRenderer) change(class, author string) []any {
+	return pandocAttr("", class, r.attributes(author))
+}
+
+// attributes returns the author and date attributes of a change or comment.
+func (r *PandocRenderer) attributes(author string) [][2]string {
+	attrs := [][2]string{{"author", author}}
+	if !r.opts.Date.IsZero() {
+		attrs = append(attrs, [2]string{"date", r.opts.Date.UTC().Format(time.RFC3339)})
+	}
+	return attrs
+}
+
+// append adds an element to the current paragraph.
+func (r *PandocRenderer) append(e pandocElement) {
+	r.paras[len(r.paras)-1] = append(r.paras[len(r.paras)-1], e)
+}
+
+// author returns the change author for an editor ID.
+func (r *PandocRenderer) author(editorID string) string {
+	if editorID == "" {
+		return r.opts.Author
+	}
+	return editorID
+}
+
+// warn records a warning for the node being rendered.
+func (r *PandocRenderer) warn(w *Walker, tag, message string) {
+	d := Diagnostic{Code: CodeUnsupportedConstruct, Tag: tag, Message: message, Warning: true}
+	d.Span, d.HasSpan = w.Span()
+	r.diagnostics = append(r.diagnostics, d)
+}
+
+// pandocAttr returns a Pandoc Attr: an identifier, classes and key/value
+// attributes.
+func pandocAttr(id, class string, attrs [][2]string) []any {
+	if attrs == nil {
+		attrs = [][2]string{}
+	}
+	return []any{id, []string{class}, attrs}
+}
+
+// pandocParagraphAttr returns the Attr of the paragraph mark Span matching a
+// change Span.
+func pandocParagraphAttr(attr []any) []any {
+	return []any{attr[0], []string{"paragraph-" + attr[1].([]string)[0]}, attr[2]}
+}
+
+// pandocSpan returns a Span element.
+func pandocSpan(attr []any, inlines []pandocElement) pandocElement {
+	if inlines == nil {
+		inlines = []pandocElement{}
+	}
+	return pandocElement{T: "Span", C: []any{attr, inlines}}
+}
+
+// pandocInlines splits text into Str elements separated by Space (spaces and
+// tabs) and SoftBreak (line breaks) elements.
+func pandocInlines(text string) []pandocElement {
+	var inlines []pandocElement
+	var word strings.Builder
+	var gap string // "" if no whitespace is pending, else "Space" or "SoftBreak".
+	flush := func() {
+		if gap != "" {
+			inlines = append(inlines, pandocElement{T: gap})
+			gap = ""
+		}
+		if word.Len() > 0 {
+			inlines = append(inlines, pandocElement{T: "Str", C: word.String()})
+			word.Reset()
+		}
+	}
+	for _, c := range text {
+		switch {
+		case c == '\n':
+			if word.Len() > 0 {
+				flush()
+			}
+			gap = "SoftBreak"
+		case unicode.IsSpace(c):
+			if word.Len() > 0 {
+				flush()
+			}
+			if gap == "" {
+				gap = "Space"
+			}
+		default:
+			if gap != "" {
+				flush()
+			}
+			word.WriteRune(c)
+		}
+	}
+	flush()
+	return inlines
+}
@@ -0,0 +1,286 @@
+// transformer/registry.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// Options carries profile-specific settings as key/value pairs, for example
+// values passed on the command line. Each profile documents the keys it reads.
+type Options map[string]string
+
+// Bool reports whether key is set to a true value ("true", "1", "yes" or "on").
+func (o Options) Bool(key string) bool {
+	switch o[key] {
+	case "true", "1", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// Int returns the integer value of key, or def if the key is not set.
+func (o Options) Int(key string, def int) (int, error) {
+	value, ok := o[key]
+	if !ok || value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("option %q: invalid integer %q", key, value)
+	}
+	return n, nil
+}
+
+// Date returns the date value of key, in RFC 3339 or YYYY-MM-DD form, or the
+// zero time if the key is not set.
+func (o Options) Date(key string) (time.Time, error) {
+	value := o[key]
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("option %q: invalid date %q, want RFC 3339 or YYYY-MM-DD", key, value)
+	}
+	return date, nil
+}
+
+// ProfileFactory creates a fresh Renderer for one transformation. It returns an
+// error if opts contains invalid settings for the profile.
+type ProfileFactory func(opts Options) (Renderer, error)
+
+// Names of the profiles registered by this package.
+const (
+	ProfileCleanView    = "clean"
+	ProfileTemplate     = "template"
+	ProfileTerminal     = "terminal"
+	ProfileMarkdown     = "markdown"
+	ProfileChanges      = "changes"
+	ProfileOriginal     = "original"
+	ProfileDiff         = "diff"
+	ProfileMarkup       = "markup"
+	ProfileHTML         = "html"
+	ProfileCriticMarkup = "criticmarkup"
+	ProfileDOCX         = "docx"
+	ProfileODT          = "odt"
+	ProfileLaTeX        = "latex"
+	ProfilePandoc       = "pandoc"
+	ProfileLedger       = "ledger"
+)
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = make(map[string]ProfileFactory)
+)
+
+func init() {
+	RegisterProfile(ProfileCleanView, func(opts Options) (Renderer, error) {
+		fallback, err := ParseFallback(opts["fallback"])
+		if err != nil {
+			return nil, err
+		}
+		return NewCleanViewRenderer(CleanViewOptions{Cleanup: opts.Bool("cleanup"), Fallback: fallback}), nil
+	})
+	RegisterProfile(ProfileTemplate, func(opts Options) (Renderer, error) {
+		if opts["file"] == "" {
+			return nil, fmt.Errorf("profile %q requires the %q option", ProfileTemplate, "file")
+		}
+		set, err := LoadTemplateFile(opts["file"])
+		if err != nil {
+			return nil, err
+		}
+		return NewTemplateRenderer(set), nil
+	})
+	RegisterProfile(ProfileTerminal, func(opts Options) (Renderer, error) {
+		// Whether the output goes to a terminal is for the caller to decide.
+		var color bool
+		switch opts["color"] {
+		case "", "never":
+		case "always":
+			color = true
+		default:
+			return nil, fmt.Errorf("invalid color %q, want always or never", opts["color"])
+		}
+		return NewANSIRenderer(ANSIOptions{Color: color}), nil
+	})
+	RegisterProfile(ProfileMarkdown, func(opts Options) (Renderer, error) {
+		var apply bool
+		switch opts["mode"] {
+		case "", "show":
+		case "apply":
+			apply = true
+		default:
+			return nil, fmt.Errorf("invalid mode %q, want show or apply", opts["mode"])
+		}
+		r, err := NewMarkdownRenderer(MarkdownOptions{Apply: apply, HighlightStyle: opts["highlight"]})
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+	RegisterProfile(ProfileChanges, func(opts Options) (Renderer, error) {
+		contextWords, err := opts.Int("context", DefaultContextWords)
+		if err != nil {
+			return nil, err
+		}
+		r, err := NewChangesRenderer(ChangesOptions{Format: opts["format"], Group: opts["group"], ContextWords: contextWords})
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+	RegisterProfile(ProfileOriginal, func(opts Options) (Renderer, error) {
+		return NewOriginalViewRenderer(), nil
+	})
+	RegisterProfile(ProfileMarkup, func(opts Options) (Renderer, error) {
+		return NewMarkupRenderer(optionsDecider(opts)), nil
+	})
+	RegisterProfile(ProfileHTML, func(opts Options) (Renderer, error) {
+		return NewHTMLRenderer(HTMLOptions{Fragment: opts.Bool("fragment"), Title: opts["title"]}), nil
+	})
+	RegisterProfile(ProfileCriticMarkup, func(opts Options) (Renderer, error) {
+		return NewCriticMarkupRenderer(), nil
+	})
+	RegisterProfile(ProfileDOCX, func(opts Options) (Renderer, error) {
+		date, err := opts.Date("date")
+		if err != nil {
+			return nil, err
+		}
+		return NewDOCXRenderer(DOCXOptions{Author: opts["author"], Date: date}), nil
+	})
+	RegisterProfile(ProfileODT, func(opts Options) (Renderer, error) {
+		date, err := opts.Date("date")
+		if err != nil {
+			return nil, err
+		}
+		return NewODTRenderer(ODTOptions{Author: opts["author"], Date: date}), nil
+	})
+	RegisterProfile(ProfilePandoc, func(opts Options) (Renderer, error) {
+		date, err := opts.Date("date")
+		if err != nil {
+			return nil, err
+		}
+		return NewPandocRenderer(PandocOptions{Author: opts["author"], Date: date}), nil
+	})
+	RegisterProfile(ProfileLedger, func(opts Options) (Renderer, error) {
+		r, err := NewLedgerRenderer(LedgerOptions{Format: opts["format"]})
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+	RegisterProfile(ProfileLaTeX, func(opts Options) (Renderer, error) {
+		r, err := NewLaTeXRenderer(LaTeXOptions{Output: opts["output"], DocumentClass: opts["class"]})
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+	RegisterProfile(ProfileDiff, func(opts Options) (Renderer, error) {
+		context, err := opts.Int("context", DefaultDiffContext)
+		if err != nil {
+			return nil, err
+		}
+		if context == 0 {
+			context = -1 // Options ask for no context explicitly.
+		}
+		width, err := opts.Int("width", DefaultDiffWidth)
+		if err != nil {
+			return nil, err
+		}
+		fallback, err := ParseFallback(opts["fallback"])
+		if err != nil {
+			return nil, err
+		}
+		r, err := NewDiffRenderer(DiffOptions{
+			Format:    opts["format"],
+			Context:   context,
+			Width:     width,
+			FromLabel: opts["from"],
+			ToLabel:   opts["to"],
+			Clean:     CleanViewOptions{Cleanup: opts.Bool("cleanup"), Fallback: fallback},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+}
+
+// RegisterProfile makes a rendering profile available under name.
+// It panics if name is empty, factory is nil, or a profile with the same name
+// is already registered.
+func RegisterProfile(name string, factory ProfileFactory) {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	if name == "" {
+		panic("transformer: RegisterProfile with empty name")
+	}
+	if factory == nil {
+		panic("transformer: RegisterProfile factory is nil for " + name)
+	}
+	if _, dup := profiles[name]; dup {
+		panic("transformer: RegisterProfile called twice for " + name)
+	}
+	profiles[name] = factory
+}
+
+// LookupProfile returns the factory registered under name.
+func LookupProfile(name string) (ProfileFactory, bool) {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	factory, ok := profiles[name]
+	return factory, ok
+}
+
+// Profiles returns the names of all registered profiles, sorted.
+func Profiles() []string {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewRenderer creates a renderer for the named profile.
+func NewRenderer(name string, opts Options) (Renderer, error) {
+	factory, ok := LookupProfile(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", name)
+	}
+	return factory(opts)
+}
+
+// Transform resolves doc and renders it with the named profile. It also
+// returns the structural problems found during resolution, which the profile
+// rendered with a fallback, and those reported by the profile's renderer.
+func Transform(doc *model.Document, profile string, opts Options) (string, []Diagnostic, error) {
+	r, err := NewRenderer(profile, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	resolved, err := ResolveDocument(doc)
+	if err != nil {
+		return "", nil, err
+	}
+	output, err := Render(resolved, r)
+	diagnostics := resolved.Diagnostics
+	if reporter, ok := r.(DiagnosticReporter); ok {
+		diagnostics = append(append([]Diagnostic{}, diagnostics...), reporter.Diagnostics()...)
+		sortDiagnostics(diagnostics)
+	}
+	return output, diagnostics, err
+}
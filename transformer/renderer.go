@@ -0,0 +1,95 @@
+// transformer/renderer.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// Renderer produces one output format from a resolved document. Render walks
+// the document in order and calls one method per node; Result is called once
+// the walk is complete and returns the rendered output.
+//
+// Structural callbacks receive the resolved source for the node's tag (nil if
+// the document has no source with that tag). A renderer that wants to output a
+// source's block content calls w.WalkBlock, which walks the block's nodes with
+// the same renderer.
+type Renderer interface {
+	Text(w *Walker, n model.TextNode)
+	InlineEdit(w *Walker, n model.InlineEditNode)
+	StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource)
+	StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource)
+	Result() (string, error)
+}
+
+// Walker drives a Renderer over a resolved document. Renderers receive the
+// walker in every callback so they can inspect the document being walked and
+// descend into structural block content.
+type Walker struct {
+	renderer Renderer
+	doc      *ResolvedDocument
+	parent   *Walker
+	index    int // Index in doc.Nodes of the node being rendered.
+}
+
+// Document returns the resolved document currently being walked. Inside a
+// block walk this is the block's own document.
+func (w *Walker) Document() *ResolvedDocument {
+	return w.doc
+}
+
+// Span returns the input span of the node being rendered, and whether it is
+// known. Spans are only known for documents parsed with positions, such as
+// those returned by editml.ProcessDocument.
+func (w *Walker) Span() (model.Span, bool) {
+	return w.doc.Span(w.index)
+}
+
+// Depth returns 0 for the top-level document and increases by one for each
+// nested WalkBlock.
+func (w *Walker) Depth() int {
+	depth := 0
+	for p := w.parent; p != nil; p = p.parent {
+		depth++
+	}
+	return depth
+}
+
+// WalkBlock walks the block content of src with the current renderer. It does
+// nothing if the block content failed to parse or resolve.
+func (w *Walker) WalkBlock(src *ResolvedSource) {
+	if src == nil || src.Block == nil {
+		return
+	}
+	child := &Walker{renderer: w.renderer, doc: src.Block, parent: w}
+	child.walk()
+}
+
+// walk dispatches every node of the walker's document to the renderer.
+func (w *Walker) walk() {
+	for i, node := range w.doc.Nodes {
+		w.index = i
+		switch n := node.(type) {
+		case model.TextNode:
+			w.renderer.Text(w, n)
+		case model.InlineEditNode:
+			w.renderer.InlineEdit(w, n)
+		case model.StructuralSourceNode:
+			w.renderer.StructuralSource(w, n, w.doc.Source(n.Tag))
+		case model.StructuralTargetNode:
+			w.renderer.StructuralTarget(w, n, w.doc.Source(n.Tag))
+		}
+	}
+}
+
+// Render walks doc with r and returns the renderer's result.
+func Render(doc *ResolvedDocument, r Renderer) (string, error) {
+	if doc == nil {
+		return "", fmt.Errorf("render: nil document")
+	}
+	w := &Walker{renderer: r, doc: doc}
+	w.walk()
+	return r.Result()
+}
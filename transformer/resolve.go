@@ -0,0 +1,247 @@
+// transformer/resolve.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+
+	"github.com/verkaro/editml-go/model"
+	"github.com/verkaro/editml-go/parser"
+)
+
+// ResolvedSource holds a structural source block together with the outcome of
+// structural resolution for its tag.
+type ResolvedSource struct {
+	Node model.StructuralSourceNode // The source node as found in the document.
+
+	// Block is the source's BlockContent parsed and resolved as a document of
+	// its own. It is nil if the block content could not be parsed or resolved.
+	Block *ResolvedDocument
+
+	// Resolved reports whether the operation has valid targets: exactly one move
+	// target for a move source, or at least one copy target for a copy source.
+	Resolved bool
+
+	ParseErr   error // Set if BlockContent could not be parsed into nodes.
+	ResolveErr error // Set if the parsed BlockContent could not be resolved.
+}
+
+// HasBlockError reports whether the source's block content failed to parse or resolve.
+func (rs *ResolvedSource) HasBlockError() bool {
+	return rs.ParseErr != nil || rs.ResolveErr != nil
+}
+
+// ResolvedDocument is the result of the structural resolution step. It pairs
+// the document nodes with every structural source and target, so that
+// renderers do not need to repeat the resolution logic.
+type ResolvedDocument struct {
+	Nodes   []model.Node                            // The nodes of the document, in order.
+	Sources map[string]*ResolvedSource              // Tag -> resolved source.
+	Targets map[string][]model.StructuralTargetNode // Tag -> targets, in document order.
+
+	// Spans holds the input range of each node, parallel to Nodes, and Input
+	// the input text they refer to. Spans is nil if positions are unknown. For
+	// a source's Block, spans refer to the outer document's input.
+	Spans []model.Span
+	Input string
+
+	// Protected lists the ranges of Input that hold host-language syntax
+	// rather than EditML (see model.Document).
+	Protected []model.Range
+
+	// Diagnostics lists, in document order, the structural constructs that
+	// cannot be transformed as written, including those inside blocks.
+	Diagnostics []Diagnostic
+}
+
+// Span returns the span of the node at index i, and whether it is known.
+func (rd *ResolvedDocument) Span(i int) (model.Span, bool) {
+	if i < 0 || i >= len(rd.Spans) {
+		return model.Span{}, false
+	}
+	return rd.Spans[i], true
+}
+
+// conflicts returns a *ConflictError describing every occurrence of the tags
+// with several sources or, among moves, several targets (Spec 3.4.3), given
+// their counts, or nil if there are none.
+func (rd *ResolvedDocument) conflicts(sourceCounts, moveTargetCounts map[string]int) error {
+	var diagnostics []Diagnostic
+	for i, node := range rd.Nodes {
+		var d Diagnostic
+		switch n := node.(type) {
+		case model.StructuralSourceNode:
+			if sourceCounts[n.Tag] < 2 {
+				continue
+			}
+			d = Diagnostic{Code: CodeDuplicateSource, Tag: n.Tag,
+				Message: fmt.Sprintf("duplicate source tag %q (one of %d sources)", n.Tag, sourceCounts[n.Tag])}
+		case model.StructuralTargetNode:
+			// Spec 3.4.3: Multiple move targets for the same tag is an error.
+			if n.Operation != model.OperationMove || moveTargetCounts[n.Tag] < 2 {
+				continue
+			}
+			d = Diagnostic{Code: CodeMultipleMoveTargets, Tag: n.Tag,
+				Message: fmt.Sprintf("multiple move targets for tag %q (one of %d targets)", n.Tag, moveTargetCounts[n.Tag])}
+		default:
+			continue
+		}
+		d.Span, d.HasSpan = rd.Span(i)
+		diagnostics = append(diagnostics, d)
+	}
+	if len(diagnostics) == 0 {
+		return nil
+	}
+	return &ConflictError{Diagnostics: diagnostics}
+}
+
+// Source returns the resolved source for tag, or nil if the document has no
+// source with that tag.
+func (rd *ResolvedDocument) Source(tag string) *ResolvedSource {
+	return rd.Sources[tag]
+}
+
+// Resolve runs the structural resolution step on nodes: it collects sources
+// and targets, parses and resolves each source's block content, and marks which
+// structural operations have valid counterparts.
+//
+// Conflicts that Spec 3.4.3 requires to abort the transformation (duplicate
+// source tags, multiple move targets for a tag) are returned as a
+// *ConflictError.
+func Resolve(nodes []model.Node) (*ResolvedDocument, error) {
+	return ResolveDocument(&model.Document{Nodes: nodes})
+}
+
+// ResolveDocument is Resolve for a parsed document. If the document carries
+// spans, they are kept in the result and spans are computed for the nodes of
+// every source's block content as well.
+func ResolveDocument(doc *model.Document) (*ResolvedDocument, error) {
+	nodes := doc.Nodes
+	rd := &ResolvedDocument{
+		Nodes:   nodes,
+		Sources: make(map[string]*ResolvedSource),
+		Targets: make(map[string][]model.StructuralTargetNode),
+		Input:   doc.Source,
+
+		Protected: doc.Protected,
+	}
+	if len(doc.Spans) == len(nodes) {
+		rd.Spans = doc.Spans
+	}
+	// Number of sources and move targets of every tag, for conflict detection.
+	sourceCounts := make(map[string]int)
+	moveTargetCounts := make(map[string]int)
+
+	// First pass: Collect sources, targets, and resolve source BlockContent.
+	for i, node := range nodes {
+		switch n := node.(type) {
+		case model.StructuralSourceNode:
+			// Check for duplicate source tags (Spec 3.4.3)
+			sourceCounts[n.Tag]++
+			if _, exists := rd.Sources[n.Tag]; exists {
+				continue
+			}
+			rs := &ResolvedSource{Node: n}
+			// The BlockContent itself can contain inline EditML.
+			// MVP: We re-parse the BlockContent string here.
+			// Future: If BlockContent is []model.Node in AST, this re-parsing isn't needed.
+			// Ranges protected in the document stay protected in the block.
+			var protected []model.Range
+			span, hasSpan := rd.Span(i)
+			if hasSpan && span.Content != nil {
+				protected = parser.LocalRanges(doc.Protected, span.Content)
+			}
+			subNodes, subSpans, err := parser.ParseProtectedWithSpans(n.BlockContent, protected)
+			sub := &model.Document{Nodes: subNodes, Source: rd.Input, Protected: doc.Protected}
+			if hasSpan && span.Content != nil {
+				sub.Spans = parser.RemapSpans(subSpans, span.Content, rd.Input)
+			}
+			if err != nil {
+				rs.ParseErr = err
+				rd.diagnose(i, CodeBlockParseError, n.Tag, fmt.Sprintf("cannot parse block content of %s source %q: %v", n.Operation, n.Tag, err))
+			} else if rs.Block, err = ResolveDocument(sub); err != nil {
+				// Spec 3.4.3 states bbstructure cannot be nested, so the sub-document
+				// is resolved on its own and never interacts with the outer tags.
+				rs.ResolveErr = err
+				rs.Block = nil
+				rd.diagnose(i, CodeBlockResolveError, n.Tag, fmt.Sprintf("cannot resolve block content of %s source %q: %v", n.Operation, n.Tag, err))
+			} else {
+				rd.Diagnostics = append(rd.Diagnostics, rs.Block.Diagnostics...)
+			}
+			rd.Sources[n.Tag] = rs
+
+		case model.StructuralTargetNode:
+			rd.Targets[n.Tag] = append(rd.Targets[n.Tag], n)
+			if n.Operation == model.OperationMove {
+				moveTargetCounts[n.Tag]++
+			}
+		}
+	}
+	if err := rd.conflicts(sourceCounts, moveTargetCounts); err != nil {
+		return nil, err
+	}
+
+	// Second pass: mark sources whose operation has a matching target.
+	for tag, rs := range rd.Sources {
+		for _, t := range rd.Targets[tag] {
+			if t.Operation == rs.Node.Operation {
+				rs.Resolved = true
+				break
+			}
+		}
+	}
+
+	// Third pass: report targets whose operation differs from their source's.
+	for i, node := range nodes {
+		if n, ok := node.(model.StructuralTargetNode); ok {
+			if rs := rd.Sources[n.Tag]; rs != nil && rs.Node.Operation != n.Operation {
+				rd.diagnose(i, CodeOperationMismatch, n.Tag, fmt.Sprintf("%s target %q does not match %s source", n.Operation, n.Tag, rs.Node.Operation))
+			}
+		}
+	}
+	sortDiagnostics(rd.Diagnostics)
+	return rd, nil
+}
+
+// ResolutionStatus summarizes how a structural tag was resolved.
+type ResolutionStatus string
+
+// Resolution statuses reported for structural nodes.
+const (
+	StatusResolved    ResolutionStatus = "resolved"    // Source and target(s) pair up.
+	StatusUnresolved  ResolutionStatus = "unresolved"  // The counterpart is missing (Spec 3.4.3, not an error).
+	StatusConflicting ResolutionStatus = "conflicting" // Operations disagree or the block content is invalid.
+)
+
+// SourceStatus returns the resolution status of the source with tag, or
+// StatusUnresolved if the document has no such source.
+func (rd *ResolvedDocument) SourceStatus(tag string) ResolutionStatus {
+	rs := rd.Sources[tag]
+	if rs == nil {
+		return StatusUnresolved
+	}
+	if rs.HasBlockError() {
+		return StatusConflicting
+	}
+	for _, t := range rd.Targets[tag] {
+		if t.Operation != rs.Node.Operation {
+			return StatusConflicting
+		}
+	}
+	if rs.Resolved {
+		return StatusResolved
+	}
+	return StatusUnresolved
+}
+
+// TargetStatus returns the resolution status of target n.
+func (rd *ResolvedDocument) TargetStatus(n model.StructuralTargetNode) ResolutionStatus {
+	rs := rd.Sources[n.Tag]
+	switch {
+	case rs == nil:
+		return StatusUnresolved
+	case rs.Node.Operation != n.Operation || rs.HasBlockError():
+		return StatusConflicting
+	}
+	return StatusResolved
+}
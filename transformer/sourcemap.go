@@ -0,0 +1,163 @@
+// transformer/sourcemap.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// SourceSegment maps a range of output bytes to the range of input bytes it
+// was produced from. Both ranges are half-open byte offsets.
+//
+// Unless Generated is set, the output range is a byte-for-byte copy of the
+// input range. Generated segments cover text produced by the transformation,
+// such as unresolved structural markup, and map to the whole node that
+// produced them.
+type SourceSegment struct {
+	OutStart, OutEnd int  // Range in the rendered output.
+	SrcStart, SrcEnd int  // Range in the original input.
+	Generated        bool // True if the output is not a copy of the input range.
+}
+
+// SourceMap lists, in output order, the segments of a rendered output whose
+// origin in the input is known.
+type SourceMap []SourceSegment
+
+// SourceOffset returns the input offset that produced the output byte at out.
+// For generated segments this is the start of the producing node.
+func (m SourceMap) SourceOffset(out int) (int, bool) {
+	i := sort.Search(len(m), func(i int) bool { return m[i].OutEnd > out })
+	if i == len(m) || out < m[i].OutStart {
+		return 0, false
+	}
+	seg := m[i]
+	if seg.Generated {
+		return seg.SrcStart, true
+	}
+	return seg.SrcStart + (out - seg.OutStart), true
+}
+
+// outputPiece is a run of output text together with where it came from.
+type outputPiece struct {
+	text string
+	// offsets holds the input offset of each byte of text, for text copied
+	// from the input. It is nil for generated text.
+	offsets []int
+	// span is the input range of the node that generated the text. It is only
+	// used for generated text; known is false if the span is unknown.
+	span  model.Span
+	known bool
+}
+
+// outputBuffer accumulates rendered output and tracks the input origin of
+// every byte, so that a SourceMap can be built after post-processing.
+type outputBuffer struct {
+	pieces []outputPiece
+	length int
+}
+
+// writeCopied writes text that was copied from the input. offsets holds the
+// input offset of each byte of text (it may be longer than text); a nil
+// offsets slice means the origin is unknown.
+func (b *outputBuffer) writeCopied(text string, offsets []int) {
+	if text == "" {
+		return
+	}
+	piece := outputPiece{text: text}
+	if len(offsets) >= len(text) {
+		piece.offsets = offsets[:len(text)]
+	}
+	b.pieces = append(b.pieces, piece)
+	b.length += len(text)
+}
+
+// writeGenerated writes text produced for the node spanning span.
+func (b *outputBuffer) writeGenerated(text string, span model.Span, known bool) {
+	if text == "" {
+		return
+	}
+	b.pieces = append(b.pieces, outputPiece{text: text, span: span, known: known})
+	b.length += len(text)
+}
+
+// Len returns the number of bytes written so far.
+func (b *outputBuffer) Len() int {
+	return b.length
+}
+
+// String returns the text written so far.
+func (b *outputBuffer) String() string {
+	var sb strings.Builder
+	sb.Grow(b.length)
+	for _, p := range b.pieces {
+		sb.WriteString(p.text)
+	}
+	return sb.String()
+}
+
+// drop removes the output bytes whose flag is set in mask (indexed by output
+// offset), keeping the origin of the remaining bytes intact.
+func (b *outputBuffer) drop(mask []bool) {
+	pos := 0
+	pieces := b.pieces[:0]
+	for _, p := range b.pieces {
+		var sb strings.Builder
+		var offsets []int
+		for i := 0; i < len(p.text); i++ {
+			if mask[pos+i] {
+				continue
+			}
+			sb.WriteByte(p.text[i])
+			if p.offsets != nil {
+				offsets = append(offsets, p.offsets[i])
+			}
+		}
+		pos += len(p.text)
+		if sb.Len() == 0 {
+			continue
+		}
+		p.text = sb.String()
+		if p.offsets != nil {
+			p.offsets = offsets
+		}
+		pieces = append(pieces, p)
+	}
+	b.pieces = pieces
+	b.length = 0
+	for _, p := range b.pieces {
+		b.length += len(p.text)
+	}
+}
+
+// sourceMap builds the SourceMap of the text written so far. Copied text is
+// split into verbatim segments wherever its input offsets are not contiguous.
+func (b *outputBuffer) sourceMap() SourceMap {
+	var m SourceMap
+	out := 0
+	for _, p := range b.pieces {
+		switch {
+		case p.offsets != nil:
+			start := 0
+			for i := 1; i <= len(p.text); i++ {
+				if i == len(p.text) || p.offsets[i] != p.offsets[i-1]+1 {
+					m = append(m, SourceSegment{
+						OutStart: out + start, OutEnd: out + i,
+						SrcStart: p.offsets[start], SrcEnd: p.offsets[i-1] + 1,
+					})
+					start = i
+				}
+			}
+		case p.known:
+			m = append(m, SourceSegment{
+				OutStart: out, OutEnd: out + len(p.text),
+				SrcStart: p.span.Start.Offset, SrcEnd: p.span.End.Offset,
+				Generated: true,
+			})
+		}
+		out += len(p.text)
+	}
+	return m
+}
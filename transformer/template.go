@@ -0,0 +1,236 @@
+// transformer/template.go
+// package transformer provides functionality to transform an EditML AST into output strings.
+package transformer
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/verkaro/editml-go/model"
+)
+
+// Template names looked up by the template profile, one per node kind.
+const (
+	TemplateText       = "text"
+	TemplateAddition   = "addition"
+	TemplateDeletion   = "deletion"
+	TemplateComment    = "comment"
+	TemplateHighlight  = "highlight"
+	TemplateMoveSource = "move-source"
+	TemplateMoveTarget = "move-target"
+	TemplateCopySource = "copy-source"
+	TemplateCopyTarget = "copy-target"
+	// TemplateDocument, if defined, wraps the whole output. It receives a
+	// TemplateDocumentData value.
+	TemplateDocument = "document"
+)
+
+// TemplateSet is a set of named templates. Both *text/template.Template and
+// *html/template.Template satisfy it; with html/template, node data is escaped
+// automatically.
+type TemplateSet interface {
+	ExecuteTemplate(wr io.Writer, name string, data any) error
+}
+
+// TemplateNode is the data passed to each node template.
+type TemplateNode struct {
+	Kind      string // The template name used for this node, e.g. "addition".
+	Content   string // Text, edit content, or block content of a structural source.
+	EditorID  string // Editor ID of an inline edit, if any.
+	Operation string // "move" or "copy" for structural nodes.
+	Tag       string // Tag of a structural node.
+
+	// Resolved reports whether a structural node has valid counterparts.
+	Resolved bool
+
+	// Block is the rendered block content of a resolved structural target or
+	// copy source, produced with the same templates; it is empty otherwise.
+	// With html/template it is an html/template.HTML value so it is not
+	// escaped twice.
+	Block any
+
+	// Default is what the Clean View would output for this node. Templates
+	// that are not defined render Default.
+	Default any
+
+	Start       model.Position // Start of the node in the input (if HasPosition).
+	End         model.Position // End of the node in the input (if HasPosition).
+	HasPosition bool           // True if the document was parsed with positions.
+	Depth       int            // 0 at top level, 1 inside a structural block.
+}
+
+// TemplateDocumentData is the data passed to the "document" template.
+type TemplateDocumentData struct {
+	Body any // The rendered nodes (html/template.HTML with html/template).
+}
+
+// TemplateRenderer renders each node with the template named after its kind.
+type TemplateRenderer struct {
+	set    TemplateSet
+	isHTML bool
+	stack  []*strings.Builder // Output buffers; the last one receives output.
+	err    error              // First template execution error.
+}
+
+// NewTemplateRenderer returns a Renderer that executes templates from set.
+func NewTemplateRenderer(set TemplateSet) *TemplateRenderer {
+	_, isHTML := set.(*htmltemplate.Template)
+	return &TemplateRenderer{set: set, isHTML: isHTML, stack: []*strings.Builder{{}}}
+}
+
+// LoadTemplateFile parses a template file for the template profile. Files
+// ending in .html or .htm are parsed with html/template, others with
+// text/template.
+func LoadTemplateFile(path string) (TemplateSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(path)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		return htmltemplate.New(name).Parse(string(data))
+	default:
+		return texttemplate.New(name).Parse(string(data))
+	}
+}
+
+// Text renders the "text" template.
+func (r *TemplateRenderer) Text(w *Walker, n model.TextNode) {
+	data := r.nodeData(w, TemplateText)
+	data.Content = n.Text
+	data.Default = r.plain(n.Text)
+	r.execute(data)
+}
+
+// InlineEdit renders the template named after the edit type.
+func (r *TemplateRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	data := r.nodeData(w, string(n.EditType))
+	data.Content = n.Content
+	data.EditorID = n.EditorID
+	switch n.EditType {
+	case model.EditTypeAddition, model.EditTypeHighlight:
+		data.Default = r.plain(n.Content)
+	default:
+		data.Default = r.raw("")
+	}
+	r.execute(data)
+}
+
+// StructuralSource renders the "move-source" or "copy-source" template.
+func (r *TemplateRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	data := r.nodeData(w, n.Operation+"-source")
+	data.Content = n.BlockContent
+	data.Operation = n.Operation
+	data.Tag = n.Tag
+	data.Resolved = src != nil && src.Resolved && !src.HasBlockError()
+	data.Block = r.raw("")
+	switch {
+	case !data.Resolved:
+		data.Default = r.plain(fmt.Sprintf("{%s~%s~%s}", n.Operation, n.BlockContent, n.Tag))
+	case n.Operation == model.OperationCopy:
+		data.Block = r.renderBlock(w, src)
+		data.Default = data.Block
+	default:
+		data.Default = r.raw("")
+	}
+	r.execute(data)
+}
+
+// StructuralTarget renders the "move-target" or "copy-target" template.
+func (r *TemplateRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	data := r.nodeData(w, n.Operation+"-target")
+	data.Operation = n.Operation
+	data.Tag = n.Tag
+	data.Resolved = src != nil && src.Resolved && !src.HasBlockError() && src.Node.Operation == n.Operation
+	data.Block = r.raw("")
+	data.Default = r.plain(fmt.Sprintf("{%s:%s}", n.Operation, n.Tag))
+	if data.Resolved {
+		data.Content = src.Node.BlockContent
+		data.Block = r.renderBlock(w, src)
+		data.Default = data.Block
+	}
+	r.execute(data)
+}
+
+// Result returns the rendered output, wrapped in the "document" template if
+// the set defines one.
+func (r *TemplateRenderer) Result() (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	body := r.stack[0].String()
+	if !r.defined(TemplateDocument) {
+		return body, nil
+	}
+	var sb strings.Builder
+	if err := r.set.ExecuteTemplate(&sb, TemplateDocument, TemplateDocumentData{Body: r.raw(body)}); err != nil {
+		return "", fmt.Errorf("template %q: %w", TemplateDocument, err)
+	}
+	return sb.String(), nil
+}
+
+// nodeData returns the common fields of the data for the node being rendered.
+func (r *TemplateRenderer) nodeData(w *Walker, kind string) TemplateNode {
+	data := TemplateNode{Kind: kind, Depth: w.Depth()}
+	if span, ok := w.Span(); ok {
+		data.Start, data.End, data.HasPosition = span.Start, span.End, true
+	}
+	return data
+}
+
+// renderBlock renders the block content of src into a separate buffer and
+// returns it.
+func (r *TemplateRenderer) renderBlock(w *Walker, src *ResolvedSource) any {
+	r.stack = append(r.stack, &strings.Builder{})
+	w.WalkBlock(src)
+	block := r.stack[len(r.stack)-1].String()
+	r.stack = r.stack[:len(r.stack)-1]
+	return r.raw(block)
+}
+
+// execute writes the node's template, or its Default if the set does not
+// define a template for the node's kind.
+func (r *TemplateRenderer) execute(data TemplateNode) {
+	out := r.stack[len(r.stack)-1]
+	if !r.defined(data.Kind) {
+		fmt.Fprint(out, data.Default)
+		return
+	}
+	if err := r.set.ExecuteTemplate(out, data.Kind, data); err != nil && r.err == nil {
+		r.err = fmt.Errorf("template %q: %w", data.Kind, err)
+	}
+}
+
+// defined reports whether the set defines a template called name.
+func (r *TemplateRenderer) defined(name string) bool {
+	switch set := r.set.(type) {
+	case *texttemplate.Template:
+		return set.Lookup(name) != nil
+	case *htmltemplate.Template:
+		return set.Lookup(name) != nil
+	}
+	return true
+}
+
+// plain prepares text for output outside a template, escaping it for
+// html/template sets.
+func (r *TemplateRenderer) plain(s string) any {
+	if r.isHTML {
+		return htmltemplate.HTML(htmltemplate.HTMLEscapeString(s))
+	}
+	return s
+}
+
+// raw marks already rendered output so html/template does not escape it again.
+func (r *TemplateRenderer) raw(s string) any {
+	if r.isHTML {
+		return htmltemplate.HTML(s)
+	}
+	return s
+}
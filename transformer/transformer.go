@@ -4,222 +4,223 @@ package transformer
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/verkaro/editml-go/model"
-	"github.com/verkaro/editml-go/parser"
 )
 
 // TransformToCleanView is the internal function that takes a slice of nodes (AST)
 // and applies transformations to produce a "Clean View" string.
 // It also returns any critical errors encountered during transformation.
-// This function is unexported and will be called by the public editml.TransformCleanView().
+// Constructs listed in the resolved document's Diagnostics are rendered as
+// literal markup; use Transform to obtain the diagnostics as well.
 func TransformToCleanView(nodes []model.Node) (string, error) { // Renamed from transformToCleanView
 	// This implementation is adapted from the Backburner POC's transformer.
 	// It focuses on "CleanView": additions applied, deletions/comments removed,
 	// highlights as plain text, structural edits resolved.
 
-	// --- Step 1: Pre-scan to collect structural operations and detect immediate conflicts ---
-	// This logic is similar to the Backburner POC.
-	type sourceDetail struct {
-		node             model.StructuralSourceNode
-		transformedBlock string // Pre-transformed content of the source block
-		isUsedAsMove     bool   // True if this source is part of a successful move operation
+	// --- Step 1: Resolve structural operations and detect immediate conflicts ---
+	doc, err := Resolve(nodes)
+	if err != nil {
+		return "", err
 	}
-	allSources := make(map[string]*sourceDetail) // Tag -> *sourceDetail
 
-	type targetDetail struct {
-		node model.StructuralTargetNode
+	// --- Step 2: Build the output string by applying transformations ---
+	return Render(doc, NewCleanViewRenderer(CleanViewOptions{}))
+}
+
+// CleanViewOptions configures the "Clean View" profile.
+type CleanViewOptions struct {
+	// Cleanup collapses whitespace and punctuation artifacts left at deletion,
+	// comment and move boundaries, e.g. "This is {-not seen-}." renders as
+	// "This is." instead of "This is .". Text away from those boundaries is
+	// never changed. Set with the "cleanup" profile option.
+	Cleanup bool
+
+	// Fallback selects how constructs reported in
+	// ResolvedDocument.Diagnostics are rendered; FallbackMarkup if empty.
+	// Set with the "fallback" profile option.
+	Fallback Fallback
+}
+
+// Fallback is a way to render a structural construct that cannot be
+// transformed, such as a target whose operation does not match its source.
+type Fallback string
+
+// Fallbacks supported by the Clean View.
+const (
+	FallbackMarkup  Fallback = "markup"  // The construct's EditML markup, literally.
+	FallbackContent Fallback = "content" // The block content of the construct's source.
+	FallbackOmit    Fallback = "omit"    // Nothing.
+)
+
+// ParseFallback returns the Fallback named s; an empty s means FallbackMarkup.
+func ParseFallback(s string) (Fallback, error) {
+	switch f := Fallback(s); f {
+	case "":
+		return FallbackMarkup, nil
+	case FallbackMarkup, FallbackContent, FallbackOmit:
+		return f, nil
 	}
-	allTargets := make(map[string][]targetDetail) // Tag -> []targetDetail
-
-	moveTargetCounts := make(map[string]int) // For move conflict detection (multiple move targets)
-
-	// First pass: Collect sources, targets, and pre-transform source BlockContent.
-	// Also, validate structural rules that can be checked at this stage.
-	for _, node := range nodes {
-		if srcNode, ok := node.(model.StructuralSourceNode); ok {
-			// Check for duplicate source tags (Spec 3.4.3)
-			if _, exists := allSources[srcNode.Tag]; exists {
-				// For MVP, this is a critical error.
-				// Future: Could be an editml.Issue with more detail.
-				return "", fmt.Errorf("structural conflict: duplicate source tag %q", srcNode.Tag)
-			}
-
-			// Pre-transform the block content of the source node.
-			// The BlockContent itself can contain inline EditML.
-			// MVP: We re-parse and transform the BlockContent string here.
-			// Future: If BlockContent is []model.Node in AST, this re-parsing isn't needed.
-			// Note: parser.ParseEditMLToNodes is already exported.
-			subParserNodes, parseErr := parser.ParseEditMLToNodes(srcNode.BlockContent)
-			transformedBlock := ""
-			if parseErr != nil {
-				// If BlockContent parsing fails, this is a problem for the structural operation.
-				// For CleanView, an error in content might mean the structural op is "broken".
-				// For MVP, we'll represent this as an error in the transformed block.
-				// Future: This should generate an editml.Issue.
-				transformedBlock = fmt.Sprintf("{%s~%s (ERROR_PARSING_CONTENT)~%s}", srcNode.Operation, srcNode.BlockContent, srcNode.Tag)
-			} else {
-				// Recursively call this transformer for the sub-nodes.
-				// IMPORTANT: This recursive call assumes that the sub-nodes (from BlockContent)
-				// do NOT contain further structural tags that could interact with the outer document's
-				// structural tags. Spec 3.4.3 states bbstructure cannot be nested, so the parser
-				// should ideally prevent this. If nested structural tags were parsed into subParserNodes,
-				// this could lead to complex behavior or infinite loops if not handled carefully.
-				// For MVP, we rely on the parser not producing nested structural tags within BlockContent's AST.
-				blockStr, transformErr := TransformToCleanView(subParserNodes) // Recursive call, now capitalized
-				if transformErr != nil {
-					transformedBlock = fmt.Sprintf("{%s~%s (ERROR_TRANSFORMING_CONTENT)~%s}", srcNode.Operation, srcNode.BlockContent, srcNode.Tag)
-				} else {
-					transformedBlock = blockStr
-				}
-			}
-			allSources[srcNode.Tag] = &sourceDetail{node: srcNode, transformedBlock: transformedBlock}
-
-		} else if targetNode, ok := node.(model.StructuralTargetNode); ok {
-			allTargets[targetNode.Tag] = append(allTargets[targetNode.Tag], targetDetail{node: targetNode})
-			if targetNode.Operation == model.OperationMove {
-				moveTargetCounts[targetNode.Tag]++
-				if moveTargetCounts[targetNode.Tag] > 1 {
-					// Spec 3.4.3: Multiple move targets for the same tag is an error.
-					return "", fmt.Errorf("structural conflict: multiple move targets for tag %q", targetNode.Tag)
-				}
-			}
-		}
+	return "", fmt.Errorf("invalid fallback %q, want %s, %s or %s", s, FallbackMarkup, FallbackContent, FallbackOmit)
+}
+
+// CleanViewRenderer renders the "Clean View" profile (Spec 5.1).
+type CleanViewRenderer struct {
+	opts       CleanViewOptions
+	out        outputBuffer
+	boundaries []int // Output offsets where content was removed or inserted.
+	done       bool  // Set once Result has applied cleanup.
+}
+
+// NewCleanViewRenderer returns a Renderer for the "Clean View" profile.
+func NewCleanViewRenderer(opts CleanViewOptions) *CleanViewRenderer {
+	return &CleanViewRenderer{opts: opts}
+}
+
+// Text writes plain text unchanged.
+func (r *CleanViewRenderer) Text(w *Walker, n model.TextNode) {
+	span, _ := w.Span()
+	r.out.writeCopied(n.Text, span.Content)
+}
+
+// InlineEdit applies additions and highlights and omits deletions and comments.
+func (r *CleanViewRenderer) InlineEdit(w *Walker, n model.InlineEditNode) {
+	span, _ := w.Span()
+	switch n.EditType {
+	case model.EditTypeAddition:
+		r.out.writeCopied(n.Content, span.Content) // Apply addition
+	case model.EditTypeDeletion:
+		// Omitted in CleanView
+		r.markBoundary()
+	case model.EditTypeComment:
+		// Omitted in CleanView
+		r.markBoundary()
+	case model.EditTypeHighlight:
+		r.out.writeCopied(n.Content, span.Content) // Highlight becomes plain text in CleanView
+	}
+}
+
+// StructuralSource renders a copy source in place and drops a resolved move
+// source, whose content is rendered at its target instead.
+func (r *CleanViewRenderer) StructuralSource(w *Walker, n model.StructuralSourceNode, src *ResolvedSource) {
+	if src == nil { // Should not happen if collected properly by Resolve
+		// This indicates an internal inconsistency; render the fallback.
+		r.writeFallback(w, fmt.Sprintf("{%s~%s~%s}", n.Operation, n.BlockContent, n.Tag), n.BlockContent, nil, true)
+		return
+	}
+
+	if src.HasBlockError() {
+		// An error in the block content is reflected where the source would
+		// render, for moves too, which are not applied; it is reported in
+		// ResolvedDocument.Diagnostics.
+		r.writeFallback(w, fmt.Sprintf("{%s~%s~%s}", n.Operation, n.BlockContent, n.Tag), n.BlockContent, nil, true)
+		return
 	}
 
-	// Mark move sources that have a valid target.
-	// These sources won't render their content at their original location.
-	for tag, srcDetail := range allSources {
-		if srcDetail.node.Operation == model.OperationMove {
-			if targets, hasTargets := allTargets[tag]; hasTargets && len(targets) > 0 {
-				// A move source is considered "used" if there's exactly one corresponding move target.
-				// The moveTargetCounts check above already ensures no more than one move target.
-				isMoveTargetForThisSource := false
-				for _, t := range targets {
-					if t.node.Operation == model.OperationMove {
-						isMoveTargetForThisSource = true
-						break
-					}
-				}
-				if isMoveTargetForThisSource && moveTargetCounts[tag] == 1 {
-					srcDetail.isUsedAsMove = true
-				}
-			}
+	switch n.Operation {
+	case model.OperationMove:
+		// If the move is resolved, content is rendered by the target node, so do nothing here.
+		if !src.Resolved {
+			// Unresolved move source (no valid single move target found for this move operation).
+			r.writeGenerated(w, fmt.Sprintf("{%s~%s~%s}", n.Operation, n.BlockContent, n.Tag))
+		} else {
+			r.markBoundary()
+		}
+	case model.OperationCopy:
+		// For copy, the source's content appears at its original location
+		// if it has valid targets. If no targets, it's an unresolved copy source.
+		if src.Resolved {
+			w.WalkBlock(src)
+		} else {
+			r.writeGenerated(w, fmt.Sprintf("{%s~%s~%s}", n.Operation, n.BlockContent, n.Tag))
 		}
 	}
+}
+
+// StructuralTarget renders the source's block content at the target, or the
+// target markup literally if it cannot be resolved.
+func (r *CleanViewRenderer) StructuralTarget(w *Walker, n model.StructuralTargetNode, src *ResolvedSource) {
+	if src == nil {
+		// Unresolved target (no source defined for this tag).
+		// Spec 5.1.1: "unresolved tags preserved as literal text."
+		r.writeGenerated(w, fmt.Sprintf("{%s:%s}", n.Operation, n.Tag))
+		return
+	}
 
-	// --- Step 2: Build the output string by applying transformations ---
-	var sb strings.Builder
-
-	for _, node := range nodes {
-		switch n := node.(type) {
-		case model.TextNode:
-			sb.WriteString(n.Text)
-		case model.InlineEditNode:
-			switch n.EditType {
-			case model.EditTypeAddition:
-				sb.WriteString(n.Content) // Apply addition
-			case model.EditTypeDeletion:
-				// Omitted in CleanView
-			case model.EditTypeComment:
-				// Omitted in CleanView
-			case model.EditTypeHighlight:
-				sb.WriteString(n.Content) // Highlight becomes plain text in CleanView
-			}
-		case model.StructuralSourceNode:
-			srcDetail, sourceExists := allSources[n.Tag]
-			if !sourceExists { // Should not happen if collected properly in Step 1
-				// This indicates an internal inconsistency.
-				// For MVP, render a placeholder indicating the error.
-				// Future: This should be an internal error, potentially an editml.Issue.
-				sb.WriteString(fmt.Sprintf("{%s~%s~%s (ERROR_SOURCE_NOT_FOUND_IN_MAP)}", n.Operation, n.BlockContent, n.Tag))
-				continue
-			}
-
-			if n.Operation == model.OperationMove {
-				if !srcDetail.isUsedAsMove {
-					// Unresolved move source (no valid single move target found for this move operation)
-					// or if the source's block content had errors during its transformation.
-					// Spec 5.1.1: "unresolved tags preserved as literal text."
-					// If transformedBlock contains an error message, use that; otherwise, render literally.
-					if strings.Contains(srcDetail.transformedBlock, "(ERROR_") {
-						sb.WriteString(srcDetail.transformedBlock)
-					} else {
-						sb.WriteString(fmt.Sprintf("{%s~%s~%s}", n.Operation, n.BlockContent, n.Tag))
-					}
-				}
-				// If it isUsedAsMove, content is rendered by the target node, so do nothing here.
-			} else if n.Operation == model.OperationCopy {
-				// For copy, the source's (transformed) content appears at its original location
-				// if it has valid targets. If no targets, it's an unresolved copy source.
-				// Spec 5.1.1: "unresolved tags preserved as literal text."
-				targetsForThisCopy, hasTargets := allTargets[n.Tag]
-				isValidCopyOperation := false
-				if hasTargets {
-					for _, t := range targetsForThisCopy {
-						if t.node.Operation == model.OperationCopy { // Ensure target is also a copy op
-							isValidCopyOperation = true
-							break
-						}
-					}
-				}
-
-				if !isValidCopyOperation || strings.Contains(srcDetail.transformedBlock, "(ERROR_") {
-					// No valid copy targets, or error in block content: render copy source tag literally.
-					if strings.Contains(srcDetail.transformedBlock, "(ERROR_") {
-						sb.WriteString(srcDetail.transformedBlock)
-					} else {
-						sb.WriteString(fmt.Sprintf("{%s~%s~%s}", n.Operation, n.BlockContent, n.Tag))
-					}
-				} else {
-					// Has valid copy targets and no error in block: render its transformed content at original location.
-					sb.WriteString(srcDetail.transformedBlock)
-				}
-			}
-
-		case model.StructuralTargetNode:
-			srcDetail, sourceExists := allSources[n.Tag]
-			if !sourceExists {
-				// Unresolved target (no source defined for this tag).
-				// Spec 5.1.1: "unresolved tags preserved as literal text."
-				sb.WriteString(fmt.Sprintf("{%s:%s}", n.Operation, n.Tag))
-				continue
-			}
-
-			// Check if the source and target operations match (e.g., move target for move source).
-			// Spec 3.4.3: "No Dual Operation Type for a Tag" implies target op should match source op.
-			if n.Operation != srcDetail.node.Operation {
-				// This is a structural conflict.
-				// Future: This should be an editml.Issue.
-				// For MVP, render a placeholder.
-				sb.WriteString(fmt.Sprintf("{%s:%s (ERROR_OPERATION_MISMATCH_WITH_SOURCE %s)}", n.Operation, n.Tag, srcDetail.node.Operation))
-				continue
-			}
-
-			// If the source block had transformation errors, reflect that at the target.
-			if strings.Contains(srcDetail.transformedBlock, "(ERROR_") {
-				sb.WriteString(srcDetail.transformedBlock)
-				continue
-			}
-
-			if n.Operation == model.OperationMove {
-				// Content was marked by isUsedAsMove on the source.
-				// The actual rendering of moved content happens here at the target.
-				// The pre-scan already confirmed only one valid move target if isUsedAsMove is true.
-				if srcDetail.isUsedAsMove { // Double check if this move target corresponds to a used move source
-					sb.WriteString(srcDetail.transformedBlock)
-				} else {
-					// This case implies a move target whose corresponding move source was not "used"
-					// (e.g. source was invalid, or this target is somehow orphaned despite matching tag).
-					// Render as unresolved.
-					sb.WriteString(fmt.Sprintf("{%s:%s}", n.Operation, n.Tag))
-				}
-			} else if n.Operation == model.OperationCopy {
-				// For copy, write the pre-transformed content at each valid copy target.
-				sb.WriteString(srcDetail.transformedBlock)
-			}
+	// Check if the source and target operations match (e.g., move target for move source).
+	// Spec 3.4.3: "No Dual Operation Type for a Tag" implies target op should match source op.
+	// This is a structural conflict, reported in ResolvedDocument.Diagnostics.
+	if n.Operation == model.OperationMove && src.Node.Operation == model.OperationMove &&
+		src.HasBlockError() && r.opts.Fallback == FallbackContent {
+		// The content of a move that is not applied is kept at its source.
+		r.markBoundary()
+		return
+	}
+	if n.Operation != src.Node.Operation || src.HasBlockError() {
+		r.writeFallback(w, fmt.Sprintf("{%s:%s}", n.Operation, n.Tag), src.Node.BlockContent, src, false)
+		return
+	}
+
+	// Resolve already guarantees at most one move target, so a matching
+	// target of a resolved source always receives the block content.
+	if src.Resolved {
+		r.markBoundary()
+		w.WalkBlock(src)
+		r.markBoundary()
+	} else {
+		r.writeGenerated(w, fmt.Sprintf("{%s:%s}", n.Operation, n.Tag))
+	}
+}
+
+// Result returns the Clean View text, with cleanup applied if enabled.
+func (r *CleanViewRenderer) Result() (string, error) {
+	if r.opts.Cleanup && !r.done {
+		r.out.drop(cleanupMask(r.out.String(), r.boundaries))
+		r.done = true
+	}
+	return r.out.String(), nil
+}
+
+// SourceMap returns the mapping from the Clean View text returned by Result to
+// the input, for documents parsed with positions (see editml.ProcessDocument).
+// Output that cannot be traced to the input is not covered by the map.
+func (r *CleanViewRenderer) SourceMap() SourceMap {
+	return r.out.sourceMap()
+}
+
+// markBoundary records the current output offset as a place where content was
+// removed or inserted, for cleanup.
+func (r *CleanViewRenderer) markBoundary() {
+	r.boundaries = append(r.boundaries, r.out.Len())
+}
+
+// writeGenerated writes text generated for the node being rendered.
+func (r *CleanViewRenderer) writeGenerated(w *Walker, text string) {
+	span, known := w.Span()
+	r.out.writeGenerated(text, span, known)
+}
+
+// writeFallback renders a construct that cannot be transformed according to
+// the Fallback option: its markup, the block content of its source (rendered
+// from src.Block if it parsed, or else copied literally), or nothing.
+// atSource reports whether the node being rendered is the source itself.
+func (r *CleanViewRenderer) writeFallback(w *Walker, markup, content string, src *ResolvedSource, atSource bool) {
+	switch r.opts.Fallback {
+	case FallbackOmit:
+		r.markBoundary()
+	case FallbackContent:
+		r.markBoundary()
+		switch {
+		case src != nil && src.Block != nil:
+			w.WalkBlock(src)
+		case atSource:
+			span, _ := w.Span()
+			r.out.writeCopied(content, span.Content)
+		default:
+			r.writeGenerated(w, content)
 		}
+		r.markBoundary()
+	default:
+		r.writeGenerated(w, markup)
 	}
-	return sb.String(), nil
 }
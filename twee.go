@@ -0,0 +1,124 @@
+// twee.go
+// package editml defines the public API for parsing and transforming EditML documents.
+package editml
+
+import (
+	"fmt"
+
+	"github.com/verkaro/editml-go/model"
+	"github.com/verkaro/editml-go/parser"
+)
+
+// IssueDuplicatePassage is the code of the warning reported when two
+// passages of a Twee source have the same name, and IssueCutMarkup that of
+// the warning reported when a passage header cuts off markup, which then
+// reads as text.
+const (
+	IssueDuplicatePassage IssueCode = "duplicate-passage"
+	IssueCutMarkup        IssueCode = "cut-markup"
+)
+
+// Passage is a passage of a Twee 3 story; see parser.Passage. The passages
+// returned by ProcessTwee have ranges in the input text.
+type Passage = parser.Passage
+
+// ProcessTwee is ProcessDocument for a Twee 3 story, as written by Twine and
+// Tweego. The story is split into passages at their ":: Name [tags]
+// {metadata}" headers, and each passage is parsed on its own, so that no edit
+// or structural block spans two passages. Passage headers, <<macros>> and
+// [[links]] are protected: they never read as markup, though an edit may
+// enclose a whole macro or link. The StoryData passage and passages tagged
+// script or stylesheet are protected as a whole.
+//
+// Structural moves and copies can name a passage as their target: a source
+// without any target whose tag is the name of a passage goes to the end of
+// that passage's text (see parser.ParseTweeWithSpans). Only passage names
+// that are valid tags, letters and digits, can be named this way.
+//
+// Markup left open at the end of a passage, such as an edit closed only in
+// the next one, reads as text and is reported with code IssueCutMarkup.
+// Issues carry the name of the passage they occurred in; use PassageIssues to
+// do the same for the issues of transformations.
+func ProcessTwee(inputText string) (doc *model.Document, passages []Passage, issues []Issue) {
+	textWithoutDebugComments, offsets := parser.SkipDebugCommentsWithMap(inputText)
+	parsedNodes, spans, passages, protected, err := parser.ParseTweeWithSpans(textWithoutDebugComments)
+
+	currentIssues := []Issue{}
+	if err != nil {
+		currentIssues = append(currentIssues, Issue{
+			Message:  fmt.Sprintf("Parsing error: %v", err),
+			Severity: SeverityError,
+		})
+	}
+	cuts := parser.RemapRanges(parser.TweeCutMarkup(textWithoutDebugComments, passages, protected), offsets)
+	doc = &model.Document{
+		Nodes:     parsedNodes,
+		Spans:     parser.RemapSpans(spans, offsets, inputText),
+		Source:    inputText,
+		Protected: parser.RemapRanges(protected, offsets),
+	}
+	for i := range passages {
+		ranges := parser.RemapRanges([]model.Range{passages[i].Header, passages[i].Body}, offsets)
+		passages[i].Header, passages[i].Body = ranges[0], ranges[1]
+	}
+
+	lines := parser.NewLineIndex(inputText)
+	for _, cut := range cuts {
+		start, end := lines.Position(cut.Start), lines.Position(cut.End)
+		name := "before the first passage"
+		for _, p := range passages {
+			if p.Header.Start < cut.Start {
+				name = fmt.Sprintf("in passage %q", p.Name)
+			}
+		}
+		currentIssues = append(currentIssues, Issue{
+			Message:   fmt.Sprintf("Twee warning: markup %s is cut off by the next passage header and reads as text", name),
+			Line:      start.Line,
+			Column:    start.Column,
+			Severity:  SeverityWarning,
+			Code:      IssueCutMarkup,
+			EndLine:   end.Line,
+			EndColumn: end.Column,
+		})
+	}
+	seen := make(map[string]bool)
+	for _, p := range passages {
+		if seen[p.Name] {
+			start, end := lines.Position(p.Header.Start), lines.Position(p.Header.End)
+			currentIssues = append(currentIssues, Issue{
+				Message:   fmt.Sprintf("Twee warning: duplicate passage name %q", p.Name),
+				Line:      start.Line,
+				Column:    start.Column,
+				Severity:  SeverityWarning,
+				Code:      IssueDuplicatePassage,
+				EndLine:   end.Line,
+				EndColumn: end.Column,
+			})
+		}
+		seen[p.Name] = true
+	}
+	return doc, passages, PassageIssues(doc, passages, currentIssues)
+}
+
+// PassageIssues sets the Passage of each issue with a line in doc, as
+// returned by ProcessTwee, to the name of the passage that line belongs to,
+// and returns the issues. Issues before the first passage keep an empty
+// Passage.
+func PassageIssues(doc *model.Document, passages []Passage, issues []Issue) []Issue {
+	if doc == nil || len(passages) == 0 {
+		return issues
+	}
+	lines := parser.NewLineIndex(doc.Source)
+	for i := range issues {
+		if issues[i].Line <= 0 || issues[i].Passage != "" {
+			continue
+		}
+		for _, p := range passages {
+			if lines.Position(p.Header.Start).Line > issues[i].Line {
+				break
+			}
+			issues[i].Passage = p.Name
+		}
+	}
+	return issues
+}
@@ -0,0 +1,146 @@
+// twee_test.go
+// package editml_test contains unit tests for Twee passage-aware processing.
+package editml
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestProcessTwee tests passage splitting and that headers, macros, links and code passages are not parsed as EditML.
+func TestProcessTwee(t *testing.T) {
+	input := `:: StoryData
+{"ifid": "X", "tags": {-1-}}
+
+:: Start [intro dark] {"position":"100,100"}
+You wake up {+in a cave+ws}. <<set $x to {-y-}>>
+{+[[Go north->North]]+} or [[{-south-}]].
+
+:: North \[x\]
+Dark.`
+	doc, passages, issues := ProcessTwee(input)
+	if len(issues) > 0 {
+		t.Fatalf("ProcessTwee: unexpected issues: %v", issues)
+	}
+	var names []string
+	for _, p := range passages {
+		names = append(names, p.Name)
+	}
+	if expected := []string{"StoryData", "Start", "North [x]"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("ProcessTwee: passages = %q, want %q", names, expected)
+	}
+	if start := passages[1]; !reflect.DeepEqual(start.Tags, []string{"intro", "dark"}) || start.Metadata != `{"position":"100,100"}` {
+		t.Errorf("ProcessTwee: unexpected header %+v", start)
+	}
+	if body := input[passages[1].Body.Start:passages[1].Body.End]; body != "\nYou wake up {+in a cave+ws}. <<set $x to {-y-}>>\n{+[[Go north->North]]+} or [[{-south-}]].\n\n" {
+		t.Errorf("ProcessTwee: body = %q", body)
+	}
+
+	output, _ := TransformDocument(doc, ProfileCleanView, nil)
+	expected := `:: StoryData
+{"ifid": "X", "tags": {-1-}}
+
+:: Start [intro dark] {"position":"100,100"}
+You wake up in a cave. <<set $x to {-y-}>>
+[[Go north->North]] or [[{-south-}]].
+
+:: North \[x\]
+Dark.`
+	if output != expected {
+		t.Errorf("Clean View:\nExpected: %q\nGot:      %q", expected, output)
+	}
+	if markup, _ := TransformDocument(doc, ProfileMarkup, nil); markup != input {
+		t.Errorf("markup:\nExpected: %q\nGot:      %q", input, markup)
+	}
+}
+
+// TestProcessTweePassageTarget tests moves and copies that name a passage as their target, and blocks with protected text.
+func TestProcessTweePassageTarget(t *testing.T) {
+	input := ":: Start\nHello.{move~<<set $y to {-1-}>> Bye.~End}\n{cp~Shared.~Empty}\n\n:: End\nThe end.\n\n\n:: Empty\n\n"
+	doc, _, issues := ProcessTwee(input)
+	if len(issues) > 0 {
+		t.Fatalf("ProcessTwee: unexpected issues: %v", issues)
+	}
+	output, issues := TransformDocument(doc, ProfileCleanView, nil)
+	if len(issues) > 0 {
+		t.Fatalf("TransformDocument: unexpected issues: %v", issues)
+	}
+	expected := ":: Start\nHello.\nShared.\n\n:: End\nThe end.\n<<set $y to {-1-}>> Bye.\n\n\n:: Empty\nShared."
+	if output != expected {
+		t.Errorf("Clean View:\nExpected: %q\nGot:      %q", expected, output)
+	}
+
+	markup, _ := TransformDocument(doc, ProfileMarkup, nil)
+	expected = ":: Start\nHello.{move~<<set $y to {-1-}>> Bye.~End}\n{cp~Shared.~Empty}\n\n:: End\nThe end.\n{move:End}\n\n\n:: Empty\n{copy:Empty}\n"
+	if markup != expected {
+		t.Errorf("markup:\nExpected: %q\nGot:      %q", expected, markup)
+	}
+
+	// The markup reads back with the same Clean View.
+	reparsed, _, _ := ProcessTwee(markup)
+	if output, _ := TransformDocument(reparsed, ProfileCleanView, nil); output != ":: Start\nHello.\nShared.\n\n:: End\nThe end.\n<<set $y to {-1-}>> Bye.\n\n\n:: Empty\nShared." {
+		t.Errorf("reparsed markup: Clean View = %q", output)
+	}
+
+	// A block moved after an edit at the end of a passage starts a line.
+	doc, _, _ = ProcessTwee(":: Start\n{mv~moved text~End}\n\n:: End\nBye {-now-jd}.\n")
+	if output, _ := TransformDocument(doc, ProfileCleanView, nil); output != ":: Start\n\n\n:: End\nBye .\nmoved text" {
+		t.Errorf("block after an edit: got %q", output)
+	}
+
+	// An explicit target wins over the passage.
+	doc, _, _ = ProcessTwee(":: Start\n{move~a~End}{move:End}\n:: End\nz")
+	if output, _ := TransformDocument(doc, ProfileCleanView, nil); output != ":: Start\na\n:: End\nz" {
+		t.Errorf("explicit target: got %q", output)
+	}
+}
+
+// TestProcessTweeIssues tests that edits do not span passages and that issues name their passage.
+func TestProcessTweeIssues(t *testing.T) {
+	input := "Prelude {cp:X}\n:: One\nStart {-open\n:: Two\nclose-} {move~a~X}\n:: One\nAgain."
+	doc, passages, issues := ProcessTwee(input)
+	if len(issues) != 2 || issues[0].Code != IssueCutMarkup || issues[0].Line != 3 || issues[0].Passage != "One" ||
+		issues[1].Code != IssueDuplicatePassage || issues[1].Line != 6 || issues[1].Passage != "One" {
+		t.Errorf("ProcessTwee: expected cut-markup and duplicate-passage warnings in passage One, got %+v", issues)
+	}
+	output, issues := TransformDocument(doc, ProfileCleanView, nil)
+	issues = PassageIssues(doc, passages, issues)
+	if len(issues) != 1 || issues[0].Code != IssueOperationMismatch || issues[0].Passage != "" {
+		t.Errorf("TransformDocument: expected one operation-mismatch error before the first passage, got %+v", issues)
+	}
+	if expected := "Prelude {copy:X}\n:: One\nStart {-open\n:: Two\nclose-} {move~a~X}\n:: One\nAgain."; output != expected {
+		t.Errorf("Clean View:\nExpected: %q\nGot:      %q", expected, output)
+	}
+
+	doc, passages, _ = ProcessTwee(":: A\nok\n:: B\n{move~a~T} {copy:T}")
+	_, issues = TransformDocument(doc, ProfileCleanView, nil)
+	if issues = PassageIssues(doc, passages, issues); len(issues) != 1 || issues[0].Passage != "B" {
+		t.Errorf("PassageIssues: expected one issue in passage B, got %+v", issues)
+	}
+}
+
+// TestProcessTweeCutMarkup tests that markup left open at a passage header is reported with the passage it starts in.
+func TestProcessTweeCutMarkup(t *testing.T) {
+	input := ":: Start\nHello {+there\n\n:: Next\nmore+}\n\n:: End\n{-fine-}"
+	doc, passages, issues := ProcessTwee(input)
+	if len(issues) != 1 {
+		t.Fatalf("ProcessTwee: expected one issue, got %+v", issues)
+	}
+	issue := issues[0]
+	if issue.Code != IssueCutMarkup || issue.Severity != SeverityWarning || issue.Passage != "Start" ||
+		issue.Line != 2 || issue.Column != 7 || issue.EndLine != 4 || issue.EndColumn != 1 {
+		t.Errorf("ProcessTwee: expected a cut-markup warning in passage Start at 2:7-4:1, got %+v", issue)
+	}
+	if len(passages) != 3 {
+		t.Fatalf("ProcessTwee: expected 3 passages, got %d", len(passages))
+	}
+	output, _ := TransformDocument(doc, ProfileCleanView, nil)
+	if expected := ":: Start\nHello {+there\n\n:: Next\nmore+}\n\n:: End\n"; output != expected {
+		t.Errorf("Clean View:\nExpected: %q\nGot:      %q", expected, output)
+	}
+
+	if _, _, issues := ProcessTwee("Prelude {-x\n:: A\ny-}"); len(issues) != 1 || issues[0].Passage != "" ||
+		issues[0].Message != "Twee warning: markup before the first passage is cut off by the next passage header and reads as text" {
+		t.Errorf("ProcessTwee: expected a cut-markup warning before the first passage, got %+v", issues)
+	}
+}